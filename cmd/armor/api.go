@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/user"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/client"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/handler"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+// directBaseURL is the base URL of the armor handler served in-process by direct calls.
+const directBaseURL = "http://armor.direct"
+
+// api is what the commands call, a running armor server or an armor handler in the process calling Google directly.
+type api interface {
+	ListPolicies(ctx context.Context, project string) ([]*compute.SecurityPolicy, error)
+	GetPolicy(ctx context.Context, project, policy string) (*compute.SecurityPolicy, error)
+	CreatePolicy(ctx context.Context, project string, policy *compute.SecurityPolicy) error
+	DeletePolicy(ctx context.Context, project, policy string) error
+	GetRule(ctx context.Context, project, policy string, priority int32) (*compute.SecurityPolicyRule, error)
+	CreateRule(ctx context.Context, project, policy string, rule *compute.SecurityPolicyRule) error
+	UpdateRule(ctx context.Context, project, policy string, priority int32, rule *compute.SecurityPolicyRule) error
+	DeleteRule(ctx context.Context, project, policy string, priority int32) error
+	ListPreConfiguredRules(ctx context.Context, project, ruleType, version string) ([]*compute.WafExpressionSet, error)
+	ListBackendServices(ctx context.Context, project string) ([]*compute.BackendService, error)
+	SetPolicyBackend(ctx context.Context, project, policy, backend string) error
+}
+
+var _ api = &client.Client{}
+
+// direct returns a client of an armor handler served in the process and calling Google with the given clients, so
+// changes are checked like on a server and audited by auditor. The local user is the caller, Google authorizes its credentials.
+func direct(ctx context.Context, cfg *config.Config, security cloudarmor.SecurityPolicies, service cloudarmor.BackendServices, auditor *audit.Auditor) (*client.Client, error) {
+	log := logrus.New()
+	log.Out = io.Discard
+
+	h := handler.NewHandler(ctx, cfg, security, service, log.WithField("system", "armor"),
+		handler.WithAuditor(auditor), handler.WithLocalIdentity(localIdentity()))
+	return client.New(directBaseURL, client.WithHandler(handler.SetupHttpRouter(h)))
+}
+
+// directAuditor writes the audit log configured for the server, to stderr instead of stdout where the commands
+// write their output.
+func directAuditor(cfg *config.Config, log *logrus.Entry) (*audit.Auditor, error) {
+	if cfg.AuditSink == audit.SinkStdout {
+		return audit.New(audit.NewWriterSink(os.Stderr), cfg.AuditHistory, log), nil
+	}
+	return audit.NewAuditor(cfg, log)
+}
+
+// localIdentity is the user running the command, as audited for direct calls.
+func localIdentity() *auth.Identity {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if name == "" {
+		name = "local"
+	}
+	return &auth.Identity{Subject: name, Name: name}
+}
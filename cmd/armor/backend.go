@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newBackendCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "backend",
+		Aliases: []string{"backends"},
+		Short:   "Manage the security policies of backend services",
+	}
+	o.addFlags(cmd)
+
+	var policy string
+	attach := &cobra.Command{
+		Use:               "attach BACKEND --policy POLICY",
+		Short:             "Attach a security policy to a backend service",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: o.completeBackends,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := o.api(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := a.SetPolicyBackend(cmd.Context(), o.project, policy, args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "policy %s attached to backend service %s\n", policy, args[0])
+			return nil
+		},
+	}
+	attach.Flags().StringVar(&policy, "policy", "", "Security policy to attach.")
+	_ = attach.MarkFlagRequired("policy")
+	_ = attach.RegisterFlagCompletionFunc("policy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return o.completePolicies(cmd, nil, toComplete)
+	})
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the backend services of the project and their security policies",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				a, err := o.api(cmd.Context())
+				if err != nil {
+					return err
+				}
+				backends, err := a.ListBackendServices(cmd.Context(), o.project)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd.OutOrStdout(), backends, []string{"NAME", "SECURITY POLICY"}, func(add func(columns ...string)) {
+					for _, backend := range backends {
+						add(backend.GetName(), name(backend.GetSecurityPolicy()))
+					}
+				})
+			},
+		},
+		attach,
+	)
+	return cmd
+}
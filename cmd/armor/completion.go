@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func (o *options) completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	projects, err := o.listProjects(cmd.Context(), toComplete)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return projects, cobra.ShellCompDirectiveNoFileComp
+}
+
+func (o *options) completePolicies(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return o.complete(cmd, func(ctx context.Context, a api) ([]string, error) {
+		policies, err := a.ListPolicies(ctx, o.project)
+		names := make([]string, 0, len(policies))
+		for _, policy := range policies {
+			names = append(names, policy.GetName()+"\t"+policy.GetDescription())
+		}
+		return names, err
+	})
+}
+
+// completeRules completes the policy, and then the priority of one of its rules.
+func (o *options) completeRules(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return o.completePolicies(cmd, args, toComplete)
+	case 1:
+		return o.complete(cmd, func(ctx context.Context, a api) ([]string, error) {
+			policy, err := a.GetPolicy(ctx, o.project, args[0])
+			var priorities []string
+			for _, rule := range policy.GetRules() {
+				priorities = append(priorities, strconv.Itoa(int(rule.GetPriority()))+"\t"+rule.GetDescription())
+			}
+			return priorities, err
+		})
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func (o *options) completeBackends(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return o.complete(cmd, func(ctx context.Context, a api) ([]string, error) {
+		backends, err := a.ListBackendServices(ctx, o.project)
+		names := make([]string, 0, len(backends))
+		for _, backend := range backends {
+			names = append(names, backend.GetName())
+		}
+		return names, err
+	})
+}
+
+// complete returns the candidates listed from the api the flags point at, or none when it can not be called.
+func (o *options) complete(cmd *cobra.Command, list func(ctx context.Context, a api) ([]string, error)) ([]string, cobra.ShellCompDirective) {
+	a, err := o.api(cmd.Context())
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	candidates, err := list(cmd.Context(), a)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return candidates, cobra.ShellCompDirectiveNoFileComp
+}
+
+// listProjects lists the active projects visible to the application default credentials.
+func listProjects(ctx context.Context, prefix string) ([]string, error) {
+	service, err := cloudresourcemanager.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("set up resource manager client: %w", err)
+	}
+
+	call := service.Projects.List().Filter("lifecycleState:ACTIVE")
+	if prefix != "" {
+		call = call.Filter(fmt.Sprintf("lifecycleState:ACTIVE id:%s*", prefix))
+	}
+
+	var projects []string
+	err = call.Pages(ctx, func(page *cloudresourcemanager.ListProjectsResponse) error {
+		for _, project := range page.Projects {
+			if strings.HasPrefix(project.ProjectId, prefix) {
+				projects = append(projects, project.ProjectId)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list projects: %w", err)
+	}
+	return projects, nil
+}
@@ -3,70 +3,156 @@ package main
 import (
 	"context"
 	"fmt"
-	"github.com/nais/armor/pkg/google"
-	"google.golang.org/api/option"
-	"net/http"
+	"io"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/nais/armor/config"
-	"github.com/nais/armor/pkg/handler"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/client"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/google"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 )
 
-func main() {
-	log := logrus.New()
-	log.Formatter = &logrus.JSONFormatter{}
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
 
-	cfg, err := config.SetupConfig()
-	if err != nil {
-		log.WithError(err).Fatal("setting up new config")
-	}
+	// auditFlushTimeout bounds sending the audit log of direct calls when a command has run.
+	auditFlushTimeout = 10 * time.Second
+)
 
-	logLevel, err := logrus.ParseLevel(cfg.LogLevel)
+// options are the flags shared by the commands calling armor.
+type options struct {
+	server  string
+	token   string
+	direct  bool
+	project string
+	output  string
+
+	// auditor audits direct calls, it is flushed when the command has run.
+	auditor *audit.Auditor
+	// googleClients returns the clients of direct calls, Google unless replaced in tests.
+	googleClients func(ctx context.Context, cfg *config.Config) (cloudarmor.SecurityPolicies, cloudarmor.BackendServices, error)
+	// listProjects returns the ids of the projects starting with prefix, for completion.
+	listProjects func(ctx context.Context, prefix string) ([]string, error)
+}
+
+func main() {
+	// The server handles its own signals, other commands are simply interrupted.
+	o := &options{googleClients: googleClients, listProjects: listProjects}
+	err := newRootCommand(o).Execute()
+	o.close()
 	if err != nil {
-		log.WithError(err).Fatal("setting log level")
+		os.Exit(1)
 	}
+}
 
-	log.Level = logLevel
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
-	defer stop()
-
-	var opts []option.ClientOption
-	gSecurityClient := google.NewSecurityClient(cfg, ctx, log.WithField("component", "armor-security-client"), opts...)
-	gServiceClient := google.NewServiceClient(ctx, log.WithField("component", "armor-serice-client"))
+func newRootCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "armor",
+		Short: "Manage Cloud Armor security policies through armor",
+		Long: "Manage Cloud Armor security policies through a running armor server, or directly against Google with --direct.\n" +
+			"Without a command the server is run, like armor serve.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			serve()
+		},
+	}
+	cmd.Flags().AddFlagSet(config.Flags)
+
+	cmd.AddCommand(
+		newServeCommand(),
+		newPolicyCommand(o),
+		newRuleCommand(o),
+		newBackendCommand(o),
+		newPreconfiguredCommand(o),
+	)
+	return cmd
+}
 
-	h := handler.NewHandler(ctx, cfg, gSecurityClient, gServiceClient, log.WithField("system", "armor"))
-	router := handler.SetupHttpRouter(h)
+// addFlags adds the flags of o to a command group calling armor.
+func (o *options) addFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringVarP(&o.server, "server", "s", os.Getenv("ARMOR_SERVER"), "URL of the armor server, defaults to $ARMOR_SERVER.")
+	flags.StringVar(&o.token, "token", os.Getenv("ARMOR_TOKEN"), "Bearer token sent to the armor server, defaults to $ARMOR_TOKEN.")
+	flags.BoolVar(&o.direct, "direct", false,
+		"Call Google directly with application default credentials instead of an armor server, configured by the environment and .armor.yaml of the server.")
+	flags.StringVarP(&o.project, "project", "p", os.Getenv("ARMOR_PROJECT"), "Google project, defaults to $ARMOR_PROJECT.")
+	flags.StringVarP(&o.output, "output", "o", outputTable, "Output format: table, json or yaml.")
+
+	_ = cmd.RegisterFlagCompletionFunc("project", o.completeProjects)
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(
+		[]string{outputTable, outputJSON, outputYAML}, cobra.ShellCompDirectiveNoFileComp))
+}
 
-	server := http.Server{
-		Addr:              ":8080",
-		ReadHeaderTimeout: 3 * time.Second,
-		IdleTimeout:       10 * time.Minute,
+// api returns the server or Google api the flags point at.
+func (o *options) api(ctx context.Context) (api, error) {
+	if o.project == "" {
+		return nil, fmt.Errorf("--project or $ARMOR_PROJECT is required")
+	}
+	switch o.output {
+	case outputTable, outputJSON, outputYAML:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use table, json or yaml", o.output)
 	}
 
-	log.WithField("addr", fmt.Sprintf("%s", ":8080")).Info("starting server")
-	ctx, cancel := context.WithCancel(ctx)
-	go LogError(log, cancel, func() error { return http.ListenAndServe(":8080", router) })
-
-	<-ctx.Done()
+	if o.direct {
+		cfg, err := config.Load(config.Flags)
+		if err != nil {
+			return nil, fmt.Errorf("load configuration: %w", err)
+		}
+		security, service, err := o.googleClients(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		log := logrus.New()
+		log.Out = os.Stderr
+		o.auditor, err = directAuditor(cfg, log.WithField("component", "armor-audit"))
+		if err != nil {
+			return nil, err
+		}
+		return direct(ctx, cfg, security, service, o.auditor)
+	}
 
-	stop()
-	log.Info("shutting down gracefully, press Ctrl+C again to force")
+	if o.server == "" {
+		return nil, fmt.Errorf("--server or $ARMOR_SERVER is required, or --direct to call Google")
+	}
+	var opts []client.Option
+	if o.token != "" {
+		opts = append(opts, client.WithToken(o.token))
+	}
+	return client.New(o.server, opts...)
+}
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// close flushes the audit log of direct calls.
+func (o *options) close() {
+	if o.auditor == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), auditFlushTimeout)
 	defer cancel()
-
-	if err := server.Shutdown(timeoutCtx); err != nil {
-		log.Error(err)
+	if err := o.auditor.Close(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "armor: %v\n", err)
 	}
 }
 
-func LogError(log *logrus.Logger, cancel context.CancelFunc, fn func() error) {
-	if err := fn(); err != nil {
-		cancel()
-		log.WithError(err).Error("error")
+func googleClients(ctx context.Context, cfg *config.Config) (cloudarmor.SecurityPolicies, cloudarmor.BackendServices, error) {
+	log := logrus.New()
+	log.Out = io.Discard
+
+	security, err := google.NewSecurityClient(cfg, ctx, log.WithField("component", "armor-security-client"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("set up security policies client: %w", err)
+	}
+	service, err := google.NewServiceClient(cfg, ctx, log.WithField("component", "armor-service-client"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("set up backend services client: %w", err)
 	}
+	google.ShareRetrier(security, service)
+	return security, service, nil
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/client"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/handler"
+	"github.com/nais/armor/pkg/memory"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const project = "cli-project"
+
+// newCompute returns an in-memory Cloud Armor with a policy, a rule and a backend service.
+func newCompute() *memory.Compute {
+	c := memory.New()
+	c.AddPolicy(project, &compute.SecurityPolicy{
+		Name:        proto.String("policy"),
+		Description: proto.String("Seeded policy"),
+		Rules: []*compute.SecurityPolicyRule{
+			{Priority: proto.Int32(1000), Action: proto.String("deny(403)"), Description: proto.String("Managed by terraform")},
+		},
+	})
+	c.AddBackend(project, &compute.BackendService{Name: proto.String("backend")})
+	c.SetPreconfiguredExpressionSets([]*compute.WafExpressionSet{
+		{Id: proto.String("sqli-v33-stable"), Expressions: []*compute.WafExpressionSetExpression{{Id: proto.String("owasp-crs-v030301-id942100-sqli")}}},
+		{Id: proto.String("xss-v33-stable")},
+	})
+	return c
+}
+
+// remote returns options calling an armor server in front of the in-memory Cloud Armor, given in the environment.
+func remote(t *testing.T, c *memory.Compute) *options {
+	cfg := &config.Config{
+		DevelopmentMode: true,
+		ProtectedRules:  []string{"1000", "2147483647"},
+		ReadDeadline:    5 * time.Second,
+		WriteDeadline:   5 * time.Second,
+	}
+	h := handler.NewHandler(context.Background(), cfg, c, c, log.WithField("component", "test"))
+	server := httptest.NewServer(handler.SetupHttpRouter(h))
+	t.Cleanup(server.Close)
+	t.Setenv("ARMOR_SERVER", server.URL)
+	t.Setenv("ARMOR_PROJECT", project)
+
+	return &options{listProjects: func(ctx context.Context, prefix string) ([]string, error) {
+		return []string{prefix + "-dev", prefix + "-prod"}, nil
+	}}
+}
+
+func run(o *options, args ...string) (string, error) {
+	cmd := newRootCommand(o)
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	o.close()
+	return out.String(), err
+}
+
+func Test_remote(t *testing.T) {
+	o := remote(t, newCompute())
+
+	out, err := run(o, "policy", "create", "new-policy", "--description", "Created by the CLI")
+	assert.NoError(t, err)
+	assert.Equal(t, "policy new-policy created\n", out)
+
+	out, err = run(o, "policy", "list")
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"NAME        RULES  DESCRIPTION\n"+
+		"new-policy  1      Created by the CLI\n"+
+		"policy      2      Seeded policy\n", out)
+
+	_, err = run(o, "rule", "add", "new-policy", "--priority", "20", "--action", "deny(403)", "--src-ip-ranges", "203.0.113.0/24")
+	assert.NoError(t, err)
+	_, err = run(o, "rule", "patch", "new-policy", "20", "--preview", "--description", "Previewed")
+	assert.NoError(t, err)
+
+	out, err = run(o, "rule", "get", "new-policy", "20", "-o", "json")
+	assert.NoError(t, err)
+	rule := &compute.SecurityPolicyRule{}
+	assert.NoError(t, json.Unmarshal([]byte(out), rule))
+	assert.Equal(t, "deny(403)", rule.GetAction())
+	assert.True(t, rule.GetPreview())
+	assert.Equal(t, "Previewed", rule.GetDescription())
+	assert.Equal(t, []string{"203.0.113.0/24"}, rule.GetMatch().GetConfig().GetSrcIpRanges())
+
+	out, err = run(o, "rule", "get", "new-policy", "20", "-o", "yaml")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "match:\n  config:\n    src_ip_ranges:\n      - 203.0.113.0/24\n")
+
+	_, err = run(o, "rule", "rm", "policy", "1000")
+	assert.ErrorIs(t, err, client.ErrProtectedRule)
+	_, err = run(o, "rule", "rm", "new-policy", "20")
+	assert.NoError(t, err)
+
+	_, err = run(o, "backend", "attach", "backend", "--policy", "new-policy")
+	assert.NoError(t, err)
+	out, err = run(o, "backend", "list")
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME     SECURITY POLICY\nbackend  new-policy\n", out)
+
+	out, err = run(o, "preconfigured", "list", "--type", "sqli", "--version", "v33-stable")
+	assert.NoError(t, err)
+	assert.Equal(t, "ID               ALIASES  EXPRESSIONS\nsqli-v33-stable           1\n", out)
+
+	_, err = run(o, "policy", "delete", "new-policy")
+	assert.ErrorIs(t, err, client.ErrConflict, "the policy is attached to a backend service")
+	_, err = run(o, "policy", "get", "missing")
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func Test_direct(t *testing.T) {
+	t.Setenv("ARMOR_PROJECT", project)
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("ARMOR_AUDIT_SINK", "file")
+	t.Setenv("ARMOR_AUDIT_FILE", auditFile)
+	c := newCompute()
+	o := &options{googleClients: func(ctx context.Context, cfg *config.Config) (cloudarmor.SecurityPolicies, cloudarmor.BackendServices, error) {
+		return c, c, nil
+	}}
+
+	file := filepath.Join(t.TempDir(), "rule.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(""+
+		"priority: 30\n"+
+		"action: deny(403)\n"+
+		"match:\n"+
+		"  expr:\n"+
+		"    expression: evaluatePreconfiguredExpr('sqli-v33-stable')\n"), 0o600))
+	_, err := run(o, "rule", "add", "policy", "-f", file, "--direct")
+	assert.NoError(t, err)
+
+	out, err := run(o, "policy", "get", "policy", "--direct")
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"PRIORITY    ACTION     PREVIEW  MATCH                                         DESCRIPTION\n"+
+		"30          deny(403)  false    evaluatePreconfiguredExpr('sqli-v33-stable')  \n"+
+		"1000        deny(403)  false                                                  Managed by terraform\n"+
+		"2147483647  allow      false    *                                             default rule\n", out)
+
+	_, err = run(o, "rule", "patch", "policy", "1000", "--action", "allow", "--direct")
+	assert.ErrorIs(t, err, client.ErrProtectedRule, "protected rules are checked without a server")
+	_, err = run(o, "rule", "add", "policy", "--priority", "40", "--direct")
+	assert.ErrorIs(t, err, client.ErrBadRequest, "rules are validated without a server")
+
+	_, err = run(o, "rule", "patch", "policy", "30", "--preview", "--direct")
+	assert.NoError(t, err)
+	rule, err := c.GetRule(context.Background(), proto.Int32(30), project, "policy")
+	assert.NoError(t, err)
+	assert.True(t, rule.GetPreview())
+	assert.Equal(t, "deny(403)", rule.GetAction(), "a patch keeps the fields not given")
+
+	audited, err := os.ReadFile(auditFile)
+	assert.NoError(t, err)
+	var actions []string
+	for _, line := range bytes.Split(bytes.TrimSpace(audited), []byte("\n")) {
+		entry := struct {
+			Action string `json:"action"`
+			User   string `json:"user"`
+		}{}
+		assert.NoError(t, json.Unmarshal(line, &entry))
+		assert.Equal(t, localIdentity().Name, entry.User)
+		actions = append(actions, entry.Action)
+	}
+	assert.Contains(t, actions, "CreateRule", "direct changes are audited")
+	assert.Contains(t, actions, "UpdateRule")
+}
+
+func Test_completion(t *testing.T) {
+	o := remote(t, newCompute())
+
+	out, err := run(o, "__complete", "rule", "get", "policy", "")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "1000\tManaged by terraform\n")
+
+	out, err = run(o, "__complete", "backend", "attach", "backend", "--policy", "")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "policy\tSeeded policy\n")
+
+	out, err = run(o, "__complete", "policy", "list", "--project", "nais")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "nais-dev\nnais-prod\n")
+}
+
+func Test_readinessProject(t *testing.T) {
+	cfg := &config.Config{ReadinessProject: "given-project"}
+	assert.NoError(t, readinessProject(context.Background(), cfg))
+	assert.Equal(t, "given-project", cfg.ReadinessProject)
+
+	cfg = &config.Config{DevelopmentMode: true}
+	assert.NoError(t, readinessProject(context.Background(), cfg))
+	assert.Equal(t, developmentReadinessProject, cfg.ReadinessProject)
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+	cfg = &config.Config{}
+	assert.Error(t, readinessProject(context.Background(), cfg))
+	assert.Empty(t, cfg.ReadinessProject, "the probe reports not ready without a project")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// print writes value as JSON or YAML in the fields of the REST API, or as the rows of a table.
+func (o *options) print(w io.Writer, value interface{}, header []string, rows func(add func(columns ...string))) error {
+	switch o.output {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case outputYAML:
+		data, err := toYAML(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	rows(func(columns ...string) {
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	})
+	return tw.Flush()
+}
+
+// toYAML converts value through its JSON, keeping the field names and order of the REST API.
+func toYAML(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+	clearStyle(node)
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// clearStyle drops the flow style and quotes of the parsed JSON, so the YAML is in block style.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// fromYAML decodes a resource given in YAML or JSON, with the field names of the REST API.
+func fromYAML(data []byte, out interface{}) error {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(out)
+}
+
+func policyRows(policies []*compute.SecurityPolicy) func(add func(columns ...string)) {
+	return func(add func(columns ...string)) {
+		for _, policy := range policies {
+			add(policy.GetName(), strconv.Itoa(len(policy.GetRules())), policy.GetDescription())
+		}
+	}
+}
+
+func ruleRows(rules []*compute.SecurityPolicyRule) func(add func(columns ...string)) {
+	return func(add func(columns ...string)) {
+		for _, rule := range rules {
+			add(strconv.Itoa(int(rule.GetPriority())), rule.GetAction(), strconv.FormatBool(rule.GetPreview()), match(rule), rule.GetDescription())
+		}
+	}
+}
+
+// match summarizes what a rule matches, its expression or source IP ranges.
+func match(rule *compute.SecurityPolicyRule) string {
+	if expression := rule.GetMatch().GetExpr().GetExpression(); expression != "" {
+		return expression
+	}
+	return strings.Join(rule.GetMatch().GetConfig().GetSrcIpRanges(), ",")
+}
+
+// name returns the name of a resource from its self-link.
+func name(selfLink string) string {
+	if selfLink == "" {
+		return ""
+	}
+	return path.Base(selfLink)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func newPolicyCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "policy",
+		Aliases: []string{"policies"},
+		Short:   "Manage security policies",
+	}
+	o.addFlags(cmd)
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the security policies of the project",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				a, err := o.api(cmd.Context())
+				if err != nil {
+					return err
+				}
+				policies, err := a.ListPolicies(cmd.Context(), o.project)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd.OutOrStdout(), policies, []string{"NAME", "RULES", "DESCRIPTION"}, policyRows(policies))
+			},
+		},
+		&cobra.Command{
+			Use:               "get POLICY",
+			Short:             "Show a security policy, as a table of its rules",
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: o.completePolicies,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				a, err := o.api(cmd.Context())
+				if err != nil {
+					return err
+				}
+				policy, err := a.GetPolicy(cmd.Context(), o.project, args[0])
+				if err != nil {
+					return err
+				}
+				return o.print(cmd.OutOrStdout(), policy, ruleHeader, ruleRows(policy.GetRules()))
+			},
+		},
+		newPolicyCreateCommand(o),
+		&cobra.Command{
+			Use:               "delete POLICY",
+			Short:             "Delete a security policy",
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: o.completePolicies,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				a, err := o.api(cmd.Context())
+				if err != nil {
+					return err
+				}
+				if err := a.DeletePolicy(cmd.Context(), o.project, args[0]); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "policy %s deleted\n", args[0])
+				return nil
+			},
+		},
+	)
+	return cmd
+}
+
+func newPolicyCreateCommand(o *options) *cobra.Command {
+	var file, description string
+	cmd := &cobra.Command{
+		Use:   "create [POLICY]",
+		Short: "Create a security policy, named or from a file",
+		Example: "  armor policy create my-policy --description 'Blocks bad actors'\n" +
+			"  armor policy create -f policy.yaml",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policy := &compute.SecurityPolicy{}
+			if file != "" {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				if err := fromYAML(data, policy); err != nil {
+					return fmt.Errorf("parse %s: %w", file, err)
+				}
+			}
+			if len(args) > 0 {
+				policy.Name = proto.String(args[0])
+			}
+			if cmd.Flags().Changed("description") {
+				policy.Description = proto.String(description)
+			}
+			if policy.GetName() == "" {
+				return fmt.Errorf("a policy name or a file with one is required")
+			}
+
+			a, err := o.api(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := a.CreatePolicy(cmd.Context(), o.project, policy); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "policy %s created\n", policy.GetName())
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML or JSON file with the policy, in the fields of the REST API.")
+	cmd.Flags().StringVar(&description, "description", "", "Description of the policy.")
+	return cmd
+}
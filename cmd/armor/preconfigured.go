@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newPreconfiguredCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preconfigured",
+		Short: "Show the preconfigured WAF rules of Cloud Armor",
+	}
+	o.addFlags(cmd)
+
+	var ruleType, version string
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List the preconfigured WAF expression sets",
+		Example: "  armor preconfigured list --type sqli --version v33-stable",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := o.api(cmd.Context())
+			if err != nil {
+				return err
+			}
+			sets, err := a.ListPreConfiguredRules(cmd.Context(), o.project, ruleType, version)
+			if err != nil {
+				return err
+			}
+			return o.print(cmd.OutOrStdout(), sets, []string{"ID", "ALIASES", "EXPRESSIONS"}, func(add func(columns ...string)) {
+				for _, set := range sets {
+					add(set.GetId(), strings.Join(set.GetAliases(), ","), strconv.Itoa(len(set.GetExpressions())))
+				}
+			})
+		},
+	}
+	list.Flags().StringVar(&ruleType, "type", "", "Rule type of the expression sets, e.g. sqli or xss.")
+	list.Flags().StringVar(&version, "version", "", "Version of the expression sets with a type, e.g. v33-stable.")
+
+	cmd.AddCommand(list)
+	return cmd
+}
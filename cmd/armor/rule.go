@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var ruleHeader = []string{"PRIORITY", "ACTION", "PREVIEW", "MATCH", "DESCRIPTION"}
+
+func newRuleCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rule",
+		Aliases: []string{"rules"},
+		Short:   "Manage the rules of security policies",
+	}
+	o.addFlags(cmd)
+
+	cmd.AddCommand(
+		newRuleAddCommand(o),
+		&cobra.Command{
+			Use:               "get POLICY PRIORITY",
+			Short:             "Show a rule of a security policy",
+			Args:              cobra.ExactArgs(2),
+			ValidArgsFunction: o.completeRules,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				priority, err := parsePriority(args[1])
+				if err != nil {
+					return err
+				}
+				a, err := o.api(cmd.Context())
+				if err != nil {
+					return err
+				}
+				rule, err := a.GetRule(cmd.Context(), o.project, args[0], priority)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd.OutOrStdout(), rule, ruleHeader, ruleRows([]*compute.SecurityPolicyRule{rule}))
+			},
+		},
+		newRulePatchCommand(o),
+		&cobra.Command{
+			Use:               "rm POLICY PRIORITY",
+			Aliases:           []string{"remove", "delete"},
+			Short:             "Remove a rule from a security policy",
+			Args:              cobra.ExactArgs(2),
+			ValidArgsFunction: o.completeRules,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				priority, err := parsePriority(args[1])
+				if err != nil {
+					return err
+				}
+				a, err := o.api(cmd.Context())
+				if err != nil {
+					return err
+				}
+				if err := a.DeleteRule(cmd.Context(), o.project, args[0], priority); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "rule %d removed from policy %s\n", priority, args[0])
+				return nil
+			},
+		},
+	)
+	return cmd
+}
+
+func newRuleAddCommand(o *options) *cobra.Command {
+	f := &ruleFlags{}
+	var priority int32
+	cmd := &cobra.Command{
+		Use:   "add POLICY",
+		Short: "Add a rule to a security policy",
+		Example: "  armor rule add my-policy --priority 20 --action 'deny(403)' --src-ip-ranges 203.0.113.0/24\n" +
+			"  armor rule add my-policy --priority 30 --action 'deny(403)' --expression \"evaluatePreconfiguredExpr('sqli-v33-stable')\"\n" +
+			"  armor rule add my-policy -f rule.yaml",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: o.completePolicies,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rule, err := f.rule(cmd)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("priority") {
+				rule.Priority = proto.Int32(priority)
+			}
+			// A rule is enforced unless it is explicitly previewed.
+			if rule.Preview == nil {
+				rule.Preview = proto.Bool(false)
+			}
+			if rule.Priority == nil {
+				return fmt.Errorf("--priority or a file with one is required")
+			}
+
+			a, err := o.api(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := a.CreateRule(cmd.Context(), o.project, args[0], rule); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "rule %d added to policy %s\n", rule.GetPriority(), args[0])
+			return nil
+		},
+	}
+	f.addFlags(cmd)
+	cmd.Flags().Int32Var(&priority, "priority", 0, "Priority of the rule, lower priorities are evaluated first.")
+	return cmd
+}
+
+func newRulePatchCommand(o *options) *cobra.Command {
+	f := &ruleFlags{}
+	cmd := &cobra.Command{
+		Use:               "patch POLICY PRIORITY",
+		Short:             "Change the given fields of a rule",
+		Example:           "  armor rule patch my-policy 20 --action 'deny(404)' --description 'Hide the backend'",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: o.completeRules,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			priority, err := parsePriority(args[1])
+			if err != nil {
+				return err
+			}
+			rule, err := f.rule(cmd)
+			if err != nil {
+				return err
+			}
+
+			a, err := o.api(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := a.UpdateRule(cmd.Context(), o.project, args[0], priority, rule); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "rule %d of policy %s patched\n", priority, args[0])
+			return nil
+		},
+	}
+	f.addFlags(cmd)
+	return cmd
+}
+
+// ruleFlags are the fields of a rule given as flags, or in a file.
+type ruleFlags struct {
+	file        string
+	action      string
+	description string
+	expression  string
+	srcIPRanges []string
+	preview     bool
+}
+
+func (f *ruleFlags) addFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVarP(&f.file, "file", "f", "", "YAML or JSON file with the rule, in the fields of the REST API. Flags take precedence.")
+	flags.StringVar(&f.action, "action", "", "Action of the rule, e.g. allow, deny(403) or throttle.")
+	flags.StringVar(&f.description, "description", "", "Description of the rule.")
+	flags.StringVar(&f.expression, "expression", "", "CEL expression matching the requests of the rule.")
+	flags.StringSliceVar(&f.srcIPRanges, "src-ip-ranges", nil, "Source IP ranges matching the requests of the rule.")
+	flags.BoolVar(&f.preview, "preview", false, "Only log what the rule would do.")
+	cmd.MarkFlagsMutuallyExclusive("expression", "src-ip-ranges")
+	_ = cmd.MarkFlagFilename("file", "yaml", "yml", "json")
+}
+
+// rule returns the rule of the file with the flags set on the command line applied.
+func (f *ruleFlags) rule(cmd *cobra.Command) (*compute.SecurityPolicyRule, error) {
+	rule := &compute.SecurityPolicyRule{}
+	if f.file != "" {
+		data, err := os.ReadFile(f.file)
+		if err != nil {
+			return nil, err
+		}
+		if err := fromYAML(data, rule); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", f.file, err)
+		}
+	}
+
+	changed := cmd.Flags().Changed
+	if changed("action") {
+		rule.Action = proto.String(f.action)
+	}
+	if changed("description") {
+		rule.Description = proto.String(f.description)
+	}
+	if changed("preview") {
+		rule.Preview = proto.Bool(f.preview)
+	}
+	if changed("expression") {
+		rule.Match = &compute.SecurityPolicyRuleMatcher{Expr: &compute.Expr{Expression: proto.String(f.expression)}}
+	}
+	if changed("src-ip-ranges") {
+		rule.Match = &compute.SecurityPolicyRuleMatcher{
+			VersionedExpr: proto.String(compute.SecurityPolicyRuleMatcher_SRC_IPS_V1.String()),
+			Config:        &compute.SecurityPolicyRuleMatcherConfig{SrcIpRanges: f.srcIPRanges},
+		}
+	}
+	return rule, nil
+}
+
+func parsePriority(priority string) (int32, error) {
+	p, err := strconv.ParseInt(priority, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse priority %s: %w", priority, err)
+	}
+	return int32(p), nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/fake"
+	"github.com/nais/armor/pkg/google"
+	"github.com/nais/armor/pkg/server"
+	"github.com/nais/armor/pkg/tracing"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/health"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/handler"
+	"github.com/sirupsen/logrus"
+)
+
+// developmentReadinessProject is probed in development mode, the in-memory fake has every project.
+const developmentReadinessProject = "dev-project"
+
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the armor server",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			serve()
+		},
+	}
+	cmd.Flags().AddFlagSet(config.Flags)
+	return cmd
+}
+
+// serve runs the server configured by the flags of config.Flags until it is interrupted.
+func serve() {
+	log := logrus.New()
+	log.Formatter = &logrus.JSONFormatter{}
+
+	cfg, err := config.Setup(config.Flags)
+	if err != nil {
+		log.WithError(err).Fatal("setting up new config")
+	}
+
+	logLevel, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		log.WithError(err).Fatal("setting log level")
+	}
+
+	log.Level = logLevel
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Requests and background operations run with a context that outlives the shutdown signal,
+	// so in-flight changes can be drained before it is cancelled.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	shutdownTracing, err := tracing.Setup(baseCtx, cfg)
+	if err != nil {
+		log.WithError(err).Fatal("setting up tracing")
+	}
+
+	var opts []option.ClientOption
+	if cfg.DevelopmentMode {
+		computeFake, err := developmentCompute(cfg)
+		if err != nil {
+			log.WithError(err).Fatal("setting up in-memory compute api")
+		}
+		log.Warn("development mode is enabled, google is replaced by an in-memory fake")
+		opts = computeFake.ClientOptions()
+	}
+
+	if err := readinessProject(ctx, cfg); err != nil {
+		log.WithError(err).Error("no project for the readiness probe, armor reports not ready")
+	} else {
+		log.Infof("readiness probe lists the compute api in project %s", cfg.ReadinessProject)
+	}
+
+	gSecurityClient, err := google.NewSecurityClient(cfg, baseCtx, log.WithField("component", "armor-security-client"), opts...)
+	if err != nil {
+		log.WithError(err).Fatal("setting up security policies client")
+	}
+
+	gServiceClient, err := google.NewServiceClient(cfg, baseCtx, log.WithField("component", "armor-service-client"), opts...)
+	if err != nil {
+		log.WithError(err).Fatal("setting up backend services client")
+	}
+	google.ShareRetrier(gSecurityClient, gServiceClient)
+
+	var handlerOpts []handler.Option
+	if cfg.DevelopmentMode {
+		log.Warn("development mode is enabled, authentication is not enforced")
+	} else {
+		authenticator, err := auth.NewAuthenticator(ctx, cfg, log.WithField("component", "armor-authenticator"))
+		if err != nil {
+			log.WithError(err).Fatal("setting up authentication")
+		}
+		authorizer, err := auth.NewAuthorizer(cfg.Authorization)
+		if err != nil {
+			log.WithError(err).Fatal("setting up authorization")
+		}
+		handlerOpts = append(handlerOpts, handler.WithAuthenticator(authenticator), handler.WithAuthorizer(authorizer))
+	}
+
+	auditor, err := audit.NewAuditor(cfg, log.WithField("component", "armor-audit"))
+	if err != nil {
+		log.WithError(err).Fatal("setting up audit log")
+	}
+	handlerOpts = append(handlerOpts, handler.WithAuditor(auditor))
+
+	if cfg.CallerCredentials {
+		log.Infof("calling google with caller credentials forwarded in %s", cfg.CallerTokenHeader)
+		pool := google.NewClientPool(baseCtx, cfg, log.WithField("component", "armor-client-pool"), gSecurityClient, gServiceClient, opts...)
+		defer pool.Close()
+		handlerOpts = append(handlerOpts, handler.WithClientPool(pool))
+	}
+
+	h := handler.NewHandler(baseCtx, cfg, gSecurityClient, gServiceClient, log.WithField("system", "armor"), handlerOpts...)
+	router := handler.SetupHttpRouter(h)
+	healthServer := health.NewServer()
+	grpcServer := handler.SetupGRPCServer(h, healthServer)
+
+	srv, err := server.New(baseCtx, cfg, server.Multiplex(grpcServer, router), handler.SetupInternalRouter(h), log.WithField("component", "armor-server"))
+	if err != nil {
+		log.WithError(err).Fatal("setting up server")
+	}
+	srv.Public.RegisterOnShutdown(h.CloseStreams)
+	srv.Public.RegisterOnShutdown(healthServer.Shutdown)
+
+	ctx, cancel := context.WithCancel(ctx)
+	log.WithFields(logrus.Fields{"addr": srv.Public.Addr, "tls": srv.Public.TLSConfig != nil}).Info("starting server")
+	go LogError(log, cancel, srv.ListenAndServe)
+	if srv.Internal != nil {
+		log.WithField("addr", srv.Internal.Addr).Info("starting internal server")
+		go LogError(log, cancel, srv.Internal.ListenAndServe)
+	}
+
+	<-ctx.Done()
+
+	stop()
+	log.Info("shutting down gracefully, press Ctrl+C again to force")
+
+	timeoutCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(timeoutCtx); err != nil {
+		log.WithError(err).Error("draining in-flight requests")
+	}
+
+	if err := h.Drain(timeoutCtx); err != nil {
+		log.WithError(err).Error("draining asynchronous operations")
+	}
+
+	if err := auditor.Close(timeoutCtx); err != nil {
+		log.WithError(err).Error("flushing audit log")
+	}
+
+	if err := shutdownTracing(timeoutCtx); err != nil {
+		log.WithError(err).Error("flushing traces")
+	}
+}
+
+// readinessProject defaults the project of the readiness probe to the project of the default credentials, so the probe
+// always calls Google. It fails when neither is given, and the server is then never ready instead of failing to start.
+func readinessProject(ctx context.Context, cfg *config.Config) error {
+	switch {
+	case cfg.ReadinessProject != "":
+		return nil
+	case cfg.DevelopmentMode:
+		cfg.ReadinessProject = developmentReadinessProject
+		return nil
+	}
+
+	project, err := google.DefaultProject(ctx)
+	if err != nil {
+		return fmt.Errorf("--%s is not set and there are no default credentials: %w", config.ReadinessProject, err)
+	}
+	if project == "" {
+		return fmt.Errorf("--%s is not set and the default credentials do not name a project", config.ReadinessProject)
+	}
+	cfg.ReadinessProject = project
+	return nil
+}
+
+// developmentCompute returns an in-memory Compute API seeded with the configured fixtures, or the built-in ones.
+func developmentCompute(cfg *config.Config) (*fake.Compute, error) {
+	fixtures := fake.DevelopmentFixtures
+	if cfg.DevelopmentFixtures != "" {
+		var err error
+		if fixtures, err = os.ReadFile(cfg.DevelopmentFixtures); err != nil {
+			return nil, fmt.Errorf("read fixtures: %w", err)
+		}
+	}
+
+	computeFake := fake.NewCompute()
+	if err := computeFake.LoadFixtures(fixtures); err != nil {
+		return nil, err
+	}
+	return computeFake, nil
+}
+
+func LogError(log *logrus.Logger, cancel context.CancelFunc, fn func() error) {
+	if err := fn(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		cancel()
+		log.WithError(err).Error("error")
+	}
+}
@@ -1,28 +1,115 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/mapstructure"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"log"
+	"os"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
 )
 
 const (
-	DevelopmentMode = "development-mode"
-	Port            = "port"
-	LogLevel        = "log-level"
-	ProtectedRules  = "protected-rules"
+	DevelopmentMode         = "development-mode"
+	Port                    = "port"
+	LogLevel                = "log-level"
+	ProtectedRules          = "protected-rules"
+	AuthIssuer              = "auth-issuer"
+	AuthAudience            = "auth-audience"
+	AuthJwksUrl             = "auth-jwks-url"
+	AuthJwksFile            = "auth-jwks-file"
+	Authorization           = "authorization"
+	CallerCredentials       = "caller-credentials"
+	CallerTokenHeader       = "caller-token-header"
+	AuditSink               = "audit-sink"
+	AuditFile               = "audit-file"
+	AuditWebhookUrl         = "audit-webhook-url"
+	AuditHistory            = "audit-history"
+	ReadinessProject        = "readiness-project"
+	ReadinessInterval       = "readiness-interval"
+	ReadDeadline            = "read-deadline"
+	WriteDeadline           = "write-deadline"
+	RouteDeadlines          = "route-deadlines"
+	ShutdownTimeout         = "shutdown-timeout"
+	RetryMaxAttempts        = "retry-max-attempts"
+	RetryInitialBackoff     = "retry-initial-backoff"
+	RetryMaxBackoff         = "retry-max-backoff"
+	BreakerFailureThreshold = "breaker-failure-threshold"
+	BreakerCooldown         = "breaker-cooldown"
+	TracingExporter         = "tracing-exporter"
+	TracingEndpoint         = "tracing-endpoint"
+	TracingInsecure         = "tracing-insecure"
+	InternalPort            = "internal-port"
+	ReadTimeout             = "read-timeout"
+	ReadHeaderTimeout       = "read-header-timeout"
+	WriteTimeout            = "write-timeout"
+	IdleTimeout             = "idle-timeout"
+	MaxHeaderBytes          = "max-header-bytes"
+	MaxBodyBytes            = "max-body-bytes"
+	TLSCertFile             = "tls-cert-file"
+	TLSKeyFile              = "tls-key-file"
+	TLSClientCAFile         = "tls-client-ca-file"
+	DevelopmentFixtures     = "development-fixtures"
 )
 
+// Flags are the flags of the server, parsed from the command line by NewConfig and SetupConfig.
+var Flags = flag.NewFlagSet("armor", flag.ExitOnError)
+
 type Config struct {
-	DevelopmentMode bool     `json:"development-mode"`
-	Port            string   `json:"port"`
-	LogLevel        string   `json:"log-level"`
-	ProtectedRules  []string `json:"protected-rules"`
+	DevelopmentMode         bool                     `json:"development-mode"`
+	Port                    string                   `json:"port"`
+	LogLevel                string                   `json:"log-level"`
+	ProtectedRules          []string                 `json:"protected-rules"`
+	AuthIssuer              string                   `json:"auth-issuer"`
+	AuthAudience            string                   `json:"auth-audience"`
+	AuthJwksUrl             string                   `json:"auth-jwks-url"`
+	AuthJwksFile            string                   `json:"auth-jwks-file"`
+	Authorization           []Grant                  `json:"authorization"`
+	CallerCredentials       bool                     `json:"caller-credentials"`
+	CallerTokenHeader       string                   `json:"caller-token-header"`
+	AuditSink               string                   `json:"audit-sink"`
+	AuditFile               string                   `json:"audit-file"`
+	AuditWebhookUrl         string                   `json:"audit-webhook-url"`
+	AuditHistory            int                      `json:"audit-history"`
+	ReadinessProject        string                   `json:"readiness-project"`
+	ReadinessInterval       time.Duration            `json:"readiness-interval"`
+	ReadDeadline            time.Duration            `json:"read-deadline"`
+	WriteDeadline           time.Duration            `json:"write-deadline"`
+	RouteDeadlines          map[string]time.Duration `json:"route-deadlines"`
+	ShutdownTimeout         time.Duration            `json:"shutdown-timeout"`
+	RetryMaxAttempts        int                      `json:"retry-max-attempts"`
+	RetryInitialBackoff     time.Duration            `json:"retry-initial-backoff"`
+	RetryMaxBackoff         time.Duration            `json:"retry-max-backoff"`
+	BreakerFailureThreshold int                      `json:"breaker-failure-threshold"`
+	BreakerCooldown         time.Duration            `json:"breaker-cooldown"`
+	TracingExporter         string                   `json:"tracing-exporter"`
+	TracingEndpoint         string                   `json:"tracing-endpoint"`
+	TracingInsecure         bool                     `json:"tracing-insecure"`
+	InternalPort            string                   `json:"internal-port"`
+	ReadTimeout             time.Duration            `json:"read-timeout"`
+	ReadHeaderTimeout       time.Duration            `json:"read-header-timeout"`
+	WriteTimeout            time.Duration            `json:"write-timeout"`
+	IdleTimeout             time.Duration            `json:"idle-timeout"`
+	MaxHeaderBytes          int                      `json:"max-header-bytes"`
+	MaxBodyBytes            int64                    `json:"max-body-bytes"`
+	TLSCertFile             string                   `json:"tls-cert-file"`
+	TLSKeyFile              string                   `json:"tls-key-file"`
+	TLSClientCAFile         string                   `json:"tls-client-ca-file"`
+	DevelopmentFixtures     string                   `json:"development-fixtures"`
+}
+
+// Grant allows members of a token group to perform the given verbs in the given projects.
+// A "*" in projects or verbs matches everything.
+type Grant struct {
+	Group    string   `json:"group"`
+	Projects []string `json:"projects"`
+	Verbs    []string `json:"verbs"`
 }
 
 func init() {
@@ -38,15 +125,68 @@ func init() {
 	viper.SetConfigType("yaml")
 	viper.SetConfigName("." + "armor")
 
-	flag.String(DevelopmentMode, "false",
-		"Development mode. If true, the server will not enforce authentication and will allow all requests.")
-	flag.String(Port, ":8080", "Port to listen on.")
-	flag.String(LogLevel, "debug", "The log level to use.")
-	flag.StringSlice(ProtectedRules, []string{"1000", "2147483647"},
+	Flags.String(DevelopmentMode, "false",
+		"Development mode. If true, the server will not enforce authentication and will allow all requests, "+
+			"and Google is replaced by an in-memory fake.")
+	Flags.String(DevelopmentFixtures, "", "YAML fixtures seeding the in-memory fake in development mode, defaults to built-in fixtures.")
+	Flags.String(Port, ":8080", "Port to listen on.")
+	Flags.String(LogLevel, "debug", "The log level to use.")
+	Flags.StringSlice(ProtectedRules, []string{"1000", "2147483647"},
 		"The default armor rules protected from deletion or update and managed by terraform.")
+	Flags.String(AuthIssuer, "", "The expected issuer of bearer tokens.")
+	Flags.String(AuthAudience, "", "The expected audience of bearer tokens.")
+	Flags.String(AuthJwksUrl, "", "URL of the JWKS used to verify bearer token signatures.")
+	Flags.String(AuthJwksFile, "", "Local JWKS file used to verify bearer token signatures, takes precedence over the URL.")
+	Flags.String(CallerCredentials, "false",
+		"Call Google with the forwarded OAuth access token of the caller instead of the armor service account.")
+	Flags.String(CallerTokenHeader, "X-Forwarded-Access-Token", "Header carrying the forwarded OAuth access token of the caller.")
+	Flags.String(AuditSink, "stdout", "Where to write the audit log of mutating calls: stdout, file or webhook.")
+	Flags.String(AuditFile, "", "File to append audit entries to when the audit sink is file.")
+	Flags.String(AuditWebhookUrl, "", "URL to post audit entries to when the audit sink is webhook.")
+	Flags.Int(AuditHistory, 1000, "Number of recent audit entries kept in memory for the audit endpoint.")
+	Flags.String(ReadinessProject, "",
+		"Project used by the readiness probe to verify that the Compute API is reachable, defaults to the project of the default credentials.")
+	Flags.Duration(ReadinessInterval, 30*time.Second, "Minimum interval between readiness probes against the Compute API.")
+	Flags.Duration(ReadDeadline, 30*time.Second, "Deadline for read requests against the Compute API.")
+	Flags.Duration(WriteDeadline, 2*time.Minute, "Deadline for mutations, including waiting for the Compute operation to finish.")
+	Flags.StringToString(RouteDeadlines, map[string]string{},
+		"Deadlines per route name overriding the read and write deadlines, e.g. SetPolicyBackend=5m.")
+	Flags.Duration(ShutdownTimeout, 25*time.Second, "Time allowed for in-flight requests and operations to finish on shutdown.")
+	Flags.Int(RetryMaxAttempts, 3, "Maximum attempts of idempotent Compute API calls failing with a transient error.")
+	Flags.Duration(RetryInitialBackoff, 200*time.Millisecond, "Backoff before the first retry, doubled for every following retry.")
+	Flags.Duration(RetryMaxBackoff, 5*time.Second, "Upper bound of the backoff between retries.")
+	Flags.Int(BreakerFailureThreshold, 5,
+		"Consecutive transient Compute API failures in a project before calls to it fail fast, 0 disables the breaker.")
+	Flags.Duration(BreakerCooldown, 30*time.Second, "Time calls to a project fail fast before a trial call is let through.")
+	Flags.String(TracingExporter, "none", "Where to export traces: none, stdout or otlp.")
+	Flags.String(TracingEndpoint, "", "host:port of the OTLP/HTTP collector, defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment.")
+	Flags.String(TracingInsecure, "false", "Export traces to the OTLP collector without TLS.")
+	Flags.String(InternalPort, "", "Separate port to serve the /internal probes and metrics on, by default they are served on the port.")
+	Flags.Duration(ReadTimeout, 30*time.Second, "Maximum duration for reading an entire request, including the body.")
+	Flags.Duration(ReadHeaderTimeout, 3*time.Second, "Maximum duration for reading the request headers.")
+	Flags.Duration(WriteTimeout, 3*time.Minute,
+		"Maximum duration of a response, should exceed the write deadline. Event streams extend it while they are open.")
+	Flags.Duration(IdleTimeout, 10*time.Minute, "Maximum time to wait for the next request on a keep-alive connection.")
+	Flags.Int(MaxHeaderBytes, 1<<20, "Maximum size of the request headers in bytes.")
+	Flags.Int64(MaxBodyBytes, 1<<20, "Maximum size of a request body in bytes.")
+	Flags.String(TLSCertFile, "", "Certificate to serve TLS with, reloaded when it changes on disk. Serves plain HTTP if empty.")
+	Flags.String(TLSKeyFile, "", "Private key of the TLS certificate, reloaded together with it.")
+	Flags.String(TLSClientCAFile, "", "CA bundle to require and verify client certificates against (mTLS), requires TLS.")
+
+	// Grants are structured and only configurable from the configuration file or as JSON in ARMOR_AUTHORIZATION.
+	_ = viper.BindEnv(Authorization)
 }
 
 func NewConfig() (*Config, error) {
+	if err := Flags.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+	return Load(Flags)
+}
+
+// Load reads the configuration file and environment, overridden by the flags already parsed in flags.
+// Unparsed flags give their defaults.
+func Load(flags *flag.FlagSet) (*Config, error) {
 	var err error
 	var cfg Config
 
@@ -57,9 +197,7 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
-	flag.Parse()
-
-	err = viper.BindPFlags(flag.CommandLine)
+	err = viper.BindPFlags(flags)
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +213,44 @@ func NewConfig() (*Config, error) {
 func decoderHook(dc *mapstructure.DecoderConfig) {
 	dc.TagName = "json"
 	dc.ErrorUnused = true
+	dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+		stringToGrantsHook,
+		stringToMapHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+// stringToMapHook parses key=value pairs separated by commas, as used by map flags given in the environment.
+func stringToMapHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.Map {
+		return data, nil
+	}
+
+	result := map[string]string{}
+	for _, pair := range strings.Split(data.(string), ",") {
+		if pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("parse %q: expected key=value", pair)
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
+
+func stringToGrantsHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf([]Grant{}) {
+		return data, nil
+	}
+
+	var grants []Grant
+	if err := json.Unmarshal([]byte(data.(string)), &grants); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", Authorization, err)
+	}
+	return grants, nil
 }
 
 func (c *Config) Validate(required []string) error {
@@ -109,6 +285,17 @@ func (c *Config) IsProtectedRule(priority string) bool {
 	return contains(c.ProtectedRules, priority)
 }
 
+// Deadline returns the deadline of the named route, falling back to the read or write deadline.
+func (c *Config) Deadline(route string, write bool) time.Duration {
+	if d, ok := c.RouteDeadlines[route]; ok {
+		return d
+	}
+	if write {
+		return c.WriteDeadline
+	}
+	return c.ReadDeadline
+}
+
 func contains(s []string, str string) bool {
 	for _, v := range s {
 		if v == str {
@@ -119,7 +306,15 @@ func contains(s []string, str string) bool {
 }
 
 func SetupConfig() (*Config, error) {
-	cfg, err := NewConfig()
+	if err := Flags.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+	return Setup(Flags)
+}
+
+// Setup loads the configuration like Load and validates that the required values are set.
+func Setup(flags *flag.FlagSet) (*Config, error) {
+	cfg, err := Load(flags)
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 func Test_IsProtected(t *testing.T) {
@@ -37,3 +38,26 @@ func Test_IsProtected(t *testing.T) {
 		})
 	}
 }
+
+func Test_AuthorizationFromEnv(t *testing.T) {
+	err := os.Setenv("ARMOR_AUTHORIZATION", `[{"group":"team-a","projects":["project-a"],"verbs":["read","write-rules"]}]`)
+	assert.NoError(t, err)
+	defer os.Unsetenv("ARMOR_AUTHORIZATION")
+
+	cfg, err := SetupConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, []Grant{{Group: "team-a", Projects: []string{"project-a"}, Verbs: []string{"read", "write-rules"}}}, cfg.Authorization)
+}
+
+func Test_Deadline(t *testing.T) {
+	err := os.Setenv("ARMOR_ROUTE_DEADLINES", "SetPolicyBackend=5m,GetPolicy=10s")
+	assert.NoError(t, err)
+	defer os.Unsetenv("ARMOR_ROUTE_DEADLINES")
+
+	cfg, err := SetupConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cfg.Deadline("SetPolicyBackend", true))
+	assert.Equal(t, 10*time.Second, cfg.Deadline("GetPolicy", false))
+	assert.Equal(t, 2*time.Minute, cfg.Deadline("CreateRule", true))
+	assert.Equal(t, 30*time.Second, cfg.Deadline("GetRule", false))
+}
@@ -0,0 +1,62 @@
+package armorerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an error raised by armor itself, independent of the transport it is reported over.
+type Kind string
+
+const (
+	KindParse           Kind = "parse"
+	KindValidation      Kind = "validation"
+	KindProtectedRule   Kind = "protected-rule"
+	KindNotFound        Kind = "not-found"
+	KindConflict        Kind = "conflict"
+	KindUnauthenticated Kind = "unauthenticated"
+	KindForbidden       Kind = "forbidden"
+	KindUnavailable     Kind = "unavailable"
+	KindInternal        Kind = "internal"
+)
+
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error of the given kind, the message is reported to callers while err is kept for logging.
+func Wrap(kind Kind, err error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// As returns the armor error in the chain of err, if any.
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// Is reports whether err is an armor error of the given kind.
+func Is(err error, kind Kind) bool {
+	e, ok := As(err)
+	return ok && e.Kind == kind
+}
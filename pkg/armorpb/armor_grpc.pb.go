@@ -0,0 +1,513 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: armor/v1/armor.proto
+
+package armorpb
+
+import (
+	context "context"
+	v1 "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ArmorClient is the client API for Armor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ArmorClient interface {
+	// GetPolicies lists the security policies of a project, a page of them with max_results.
+	GetPolicies(ctx context.Context, in *v1.ListSecurityPoliciesRequest, opts ...grpc.CallOption) (*v1.SecurityPolicyList, error)
+	GetPolicy(ctx context.Context, in *v1.GetSecurityPolicyRequest, opts ...grpc.CallOption) (*v1.SecurityPolicy, error)
+	CreatePolicy(ctx context.Context, in *v1.InsertSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// UpdatePolicy patches the fields given in security_policy_resource, the rules of a policy are changed by rule.
+	UpdatePolicy(ctx context.Context, in *v1.PatchSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DeletePolicy(ctx context.Context, in *v1.DeleteSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetRule(ctx context.Context, in *v1.GetRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*v1.SecurityPolicyRule, error)
+	CreateRule(ctx context.Context, in *v1.AddRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// UpdateRule patches the fields given in security_policy_rule_resource of the rule with priority.
+	UpdateRule(ctx context.Context, in *v1.PatchRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DeleteRule(ctx context.Context, in *v1.RemoveRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetPreConfiguredRules lists the preconfigured expression sets, the filter keeps the sets with ids containing it.
+	GetPreConfiguredRules(ctx context.Context, in *v1.ListPreconfiguredExpressionSetsSecurityPoliciesRequest, opts ...grpc.CallOption) (*v1.SecurityPoliciesListPreconfiguredExpressionSetsResponse, error)
+	GetBackendServices(ctx context.Context, in *v1.ListBackendServicesRequest, opts ...grpc.CallOption) (*v1.BackendServiceList, error)
+	// SetPolicyBackend attaches the policy named in security_policy_reference_resource to the backend service.
+	SetPolicyBackend(ctx context.Context, in *v1.SetSecurityPolicyBackendServiceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type armorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewArmorClient(cc grpc.ClientConnInterface) ArmorClient {
+	return &armorClient{cc}
+}
+
+func (c *armorClient) GetPolicies(ctx context.Context, in *v1.ListSecurityPoliciesRequest, opts ...grpc.CallOption) (*v1.SecurityPolicyList, error) {
+	out := new(v1.SecurityPolicyList)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/GetPolicies", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) GetPolicy(ctx context.Context, in *v1.GetSecurityPolicyRequest, opts ...grpc.CallOption) (*v1.SecurityPolicy, error) {
+	out := new(v1.SecurityPolicy)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/GetPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) CreatePolicy(ctx context.Context, in *v1.InsertSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/CreatePolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) UpdatePolicy(ctx context.Context, in *v1.PatchSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/UpdatePolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) DeletePolicy(ctx context.Context, in *v1.DeleteSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/DeletePolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) GetRule(ctx context.Context, in *v1.GetRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*v1.SecurityPolicyRule, error) {
+	out := new(v1.SecurityPolicyRule)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/GetRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) CreateRule(ctx context.Context, in *v1.AddRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/CreateRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) UpdateRule(ctx context.Context, in *v1.PatchRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/UpdateRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) DeleteRule(ctx context.Context, in *v1.RemoveRuleSecurityPolicyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/DeleteRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) GetPreConfiguredRules(ctx context.Context, in *v1.ListPreconfiguredExpressionSetsSecurityPoliciesRequest, opts ...grpc.CallOption) (*v1.SecurityPoliciesListPreconfiguredExpressionSetsResponse, error) {
+	out := new(v1.SecurityPoliciesListPreconfiguredExpressionSetsResponse)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/GetPreConfiguredRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) GetBackendServices(ctx context.Context, in *v1.ListBackendServicesRequest, opts ...grpc.CallOption) (*v1.BackendServiceList, error) {
+	out := new(v1.BackendServiceList)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/GetBackendServices", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *armorClient) SetPolicyBackend(ctx context.Context, in *v1.SetSecurityPolicyBackendServiceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/armor.v1.Armor/SetPolicyBackend", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ArmorServer is the server API for Armor service.
+// All implementations must embed UnimplementedArmorServer
+// for forward compatibility
+type ArmorServer interface {
+	// GetPolicies lists the security policies of a project, a page of them with max_results.
+	GetPolicies(context.Context, *v1.ListSecurityPoliciesRequest) (*v1.SecurityPolicyList, error)
+	GetPolicy(context.Context, *v1.GetSecurityPolicyRequest) (*v1.SecurityPolicy, error)
+	CreatePolicy(context.Context, *v1.InsertSecurityPolicyRequest) (*emptypb.Empty, error)
+	// UpdatePolicy patches the fields given in security_policy_resource, the rules of a policy are changed by rule.
+	UpdatePolicy(context.Context, *v1.PatchSecurityPolicyRequest) (*emptypb.Empty, error)
+	DeletePolicy(context.Context, *v1.DeleteSecurityPolicyRequest) (*emptypb.Empty, error)
+	GetRule(context.Context, *v1.GetRuleSecurityPolicyRequest) (*v1.SecurityPolicyRule, error)
+	CreateRule(context.Context, *v1.AddRuleSecurityPolicyRequest) (*emptypb.Empty, error)
+	// UpdateRule patches the fields given in security_policy_rule_resource of the rule with priority.
+	UpdateRule(context.Context, *v1.PatchRuleSecurityPolicyRequest) (*emptypb.Empty, error)
+	DeleteRule(context.Context, *v1.RemoveRuleSecurityPolicyRequest) (*emptypb.Empty, error)
+	// GetPreConfiguredRules lists the preconfigured expression sets, the filter keeps the sets with ids containing it.
+	GetPreConfiguredRules(context.Context, *v1.ListPreconfiguredExpressionSetsSecurityPoliciesRequest) (*v1.SecurityPoliciesListPreconfiguredExpressionSetsResponse, error)
+	GetBackendServices(context.Context, *v1.ListBackendServicesRequest) (*v1.BackendServiceList, error)
+	// SetPolicyBackend attaches the policy named in security_policy_reference_resource to the backend service.
+	SetPolicyBackend(context.Context, *v1.SetSecurityPolicyBackendServiceRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedArmorServer()
+}
+
+// UnimplementedArmorServer must be embedded to have forward compatible implementations.
+type UnimplementedArmorServer struct {
+}
+
+func (UnimplementedArmorServer) GetPolicies(context.Context, *v1.ListSecurityPoliciesRequest) (*v1.SecurityPolicyList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPolicies not implemented")
+}
+func (UnimplementedArmorServer) GetPolicy(context.Context, *v1.GetSecurityPolicyRequest) (*v1.SecurityPolicy, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPolicy not implemented")
+}
+func (UnimplementedArmorServer) CreatePolicy(context.Context, *v1.InsertSecurityPolicyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePolicy not implemented")
+}
+func (UnimplementedArmorServer) UpdatePolicy(context.Context, *v1.PatchSecurityPolicyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePolicy not implemented")
+}
+func (UnimplementedArmorServer) DeletePolicy(context.Context, *v1.DeleteSecurityPolicyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePolicy not implemented")
+}
+func (UnimplementedArmorServer) GetRule(context.Context, *v1.GetRuleSecurityPolicyRequest) (*v1.SecurityPolicyRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRule not implemented")
+}
+func (UnimplementedArmorServer) CreateRule(context.Context, *v1.AddRuleSecurityPolicyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRule not implemented")
+}
+func (UnimplementedArmorServer) UpdateRule(context.Context, *v1.PatchRuleSecurityPolicyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRule not implemented")
+}
+func (UnimplementedArmorServer) DeleteRule(context.Context, *v1.RemoveRuleSecurityPolicyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedArmorServer) GetPreConfiguredRules(context.Context, *v1.ListPreconfiguredExpressionSetsSecurityPoliciesRequest) (*v1.SecurityPoliciesListPreconfiguredExpressionSetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPreConfiguredRules not implemented")
+}
+func (UnimplementedArmorServer) GetBackendServices(context.Context, *v1.ListBackendServicesRequest) (*v1.BackendServiceList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBackendServices not implemented")
+}
+func (UnimplementedArmorServer) SetPolicyBackend(context.Context, *v1.SetSecurityPolicyBackendServiceRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPolicyBackend not implemented")
+}
+func (UnimplementedArmorServer) mustEmbedUnimplementedArmorServer() {}
+
+// UnsafeArmorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ArmorServer will
+// result in compilation errors.
+type UnsafeArmorServer interface {
+	mustEmbedUnimplementedArmorServer()
+}
+
+func RegisterArmorServer(s grpc.ServiceRegistrar, srv ArmorServer) {
+	s.RegisterService(&Armor_ServiceDesc, srv)
+}
+
+func _Armor_GetPolicies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.ListSecurityPoliciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).GetPolicies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/GetPolicies",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).GetPolicies(ctx, req.(*v1.ListSecurityPoliciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_GetPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.GetSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).GetPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/GetPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).GetPolicy(ctx, req.(*v1.GetSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_CreatePolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.InsertSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).CreatePolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/CreatePolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).CreatePolicy(ctx, req.(*v1.InsertSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_UpdatePolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.PatchSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).UpdatePolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/UpdatePolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).UpdatePolicy(ctx, req.(*v1.PatchSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_DeletePolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.DeleteSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).DeletePolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/DeletePolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).DeletePolicy(ctx, req.(*v1.DeleteSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_GetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.GetRuleSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).GetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/GetRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).GetRule(ctx, req.(*v1.GetRuleSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_CreateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.AddRuleSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).CreateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/CreateRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).CreateRule(ctx, req.(*v1.AddRuleSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_UpdateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.PatchRuleSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).UpdateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/UpdateRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).UpdateRule(ctx, req.(*v1.PatchRuleSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.RemoveRuleSecurityPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/DeleteRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).DeleteRule(ctx, req.(*v1.RemoveRuleSecurityPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_GetPreConfiguredRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.ListPreconfiguredExpressionSetsSecurityPoliciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).GetPreConfiguredRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/GetPreConfiguredRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).GetPreConfiguredRules(ctx, req.(*v1.ListPreconfiguredExpressionSetsSecurityPoliciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_GetBackendServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.ListBackendServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).GetBackendServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/GetBackendServices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).GetBackendServices(ctx, req.(*v1.ListBackendServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Armor_SetPolicyBackend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.SetSecurityPolicyBackendServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArmorServer).SetPolicyBackend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/armor.v1.Armor/SetPolicyBackend",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArmorServer).SetPolicyBackend(ctx, req.(*v1.SetSecurityPolicyBackendServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Armor_ServiceDesc is the grpc.ServiceDesc for Armor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Armor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "armor.v1.Armor",
+	HandlerType: (*ArmorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPolicies",
+			Handler:    _Armor_GetPolicies_Handler,
+		},
+		{
+			MethodName: "GetPolicy",
+			Handler:    _Armor_GetPolicy_Handler,
+		},
+		{
+			MethodName: "CreatePolicy",
+			Handler:    _Armor_CreatePolicy_Handler,
+		},
+		{
+			MethodName: "UpdatePolicy",
+			Handler:    _Armor_UpdatePolicy_Handler,
+		},
+		{
+			MethodName: "DeletePolicy",
+			Handler:    _Armor_DeletePolicy_Handler,
+		},
+		{
+			MethodName: "GetRule",
+			Handler:    _Armor_GetRule_Handler,
+		},
+		{
+			MethodName: "CreateRule",
+			Handler:    _Armor_CreateRule_Handler,
+		},
+		{
+			MethodName: "UpdateRule",
+			Handler:    _Armor_UpdateRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _Armor_DeleteRule_Handler,
+		},
+		{
+			MethodName: "GetPreConfiguredRules",
+			Handler:    _Armor_GetPreConfiguredRules_Handler,
+		},
+		{
+			MethodName: "GetBackendServices",
+			Handler:    _Armor_GetBackendServices_Handler,
+		},
+		{
+			MethodName: "SetPolicyBackend",
+			Handler:    _Armor_SetPolicyBackend_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "armor/v1/armor.proto",
+}
@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SinkStdout  = "stdout"
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeRunning = "running"
+)
+
+// Entry records a single mutating call against the Compute API.
+type Entry struct {
+	Time        time.Time   `json:"time"`
+	User        string      `json:"user"`
+	Action      string      `json:"action"`
+	Project     string      `json:"project"`
+	Policy      string      `json:"policy,omitempty"`
+	Priority    *int32      `json:"priority,omitempty"`
+	Backend     string      `json:"backend,omitempty"`
+	Before      interface{} `json:"before,omitempty"`
+	After       interface{} `json:"after,omitempty"`
+	Outcome     string      `json:"outcome"`
+	Error       string      `json:"error,omitempty"`
+	OperationID string      `json:"operation-id,omitempty"`
+}
+
+type Sink interface {
+	Write(entry *Entry) error
+}
+
+// closer is a sink holding entries until it is closed.
+type closer interface {
+	Close(ctx context.Context) error
+}
+
+// Auditor writes entries to the configured sink and keeps the most recent entries in memory.
+type Auditor struct {
+	mu      sync.RWMutex
+	log     *logrus.Entry
+	sink    Sink
+	history int
+	recent  []*Entry
+}
+
+func NewAuditor(cfg *config.Config, log *logrus.Entry) (*Auditor, error) {
+	sink, err := newSink(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	return New(sink, cfg.AuditHistory, log), nil
+}
+
+func New(sink Sink, history int, log *logrus.Entry) *Auditor {
+	return &Auditor{
+		log:     log,
+		sink:    sink,
+		history: history,
+	}
+}
+
+func newSink(cfg *config.Config, log *logrus.Entry) (Sink, error) {
+	switch cfg.AuditSink {
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case SinkFile:
+		if cfg.AuditFile == "" {
+			return nil, fmt.Errorf("%s is required for the %s audit sink", config.AuditFile, SinkFile)
+		}
+		f, err := os.OpenFile(cfg.AuditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open audit file: %w", err)
+		}
+		return NewWriterSink(f), nil
+	case SinkWebhook:
+		if cfg.AuditWebhookUrl == "" {
+			return nil, fmt.Errorf("%s is required for the %s audit sink", config.AuditWebhookUrl, SinkWebhook)
+		}
+		return NewWebhookSink(cfg.AuditWebhookUrl, log), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.AuditSink)
+	}
+}
+
+// Record writes the entry to the sink, failures are logged and never fail the audited call.
+func (a *Auditor) Record(entry *Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	if err := a.sink.Write(entry); err != nil {
+		a.log.Errorf("failed to write audit entry for %s in %s: %v", entry.Action, entry.Project, err)
+	}
+
+	if a.history <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recent = append(a.recent, entry)
+	if len(a.recent) > a.history {
+		a.recent = a.recent[len(a.recent)-a.history:]
+	}
+}
+
+// Close writes the entries the sink still holds, until ctx is done.
+func (a *Auditor) Close(ctx context.Context) error {
+	if sink, ok := a.sink.(closer); ok {
+		return sink.Close(ctx)
+	}
+	return nil
+}
+
+// Recent returns up to limit entries for the project, newest first.
+func (a *Auditor) Recent(projectID string, since time.Time, limit int) []*Entry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := []*Entry{}
+	for i := len(a.recent) - 1; i >= 0 && (limit <= 0 || len(entries) < limit); i-- {
+		entry := a.recent[i]
+		if entry.Project != projectID || entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Record(t *testing.T) {
+	buf := &bytes.Buffer{}
+	auditor := New(NewWriterSink(buf), 2, log.WithField("component", "test"))
+
+	auditor.Record(&Entry{Action: "CreatePolicy", Project: "project-a", Policy: "first", Outcome: OutcomeSuccess})
+	auditor.Record(&Entry{Action: "DeletePolicy", Project: "project-b", Policy: "other", Outcome: OutcomeSuccess})
+	auditor.Record(&Entry{Action: "UpdatePolicy", Project: "project-a", Policy: "second", Outcome: OutcomeFailure, Error: "conflict"})
+	auditor.Record(&Entry{Action: "DeleteRule", Project: "project-a", Policy: "third", Outcome: OutcomeSuccess})
+
+	decoder := json.NewDecoder(buf)
+	lines := 0
+	for decoder.More() {
+		entry := Entry{}
+		assert.NoError(t, decoder.Decode(&entry))
+		assert.False(t, entry.Time.IsZero())
+		lines++
+	}
+	assert.Equal(t, 4, lines, "every entry is written to the sink")
+
+	recent := auditor.Recent("project-a", time.Time{}, 10)
+	assert.Len(t, recent, 2, "only the configured history is kept in memory")
+	assert.Equal(t, "third", recent[0].Policy, "newest entry first")
+	assert.Equal(t, "second", recent[1].Policy)
+
+	assert.Len(t, auditor.Recent("project-a", time.Time{}, 1), 1)
+	assert.Empty(t, auditor.Recent("project-a", time.Now().Add(time.Minute), 10))
+}
+
+func Test_WebhookSink(t *testing.T) {
+	var received Entry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, log.WithField("component", "test"))
+	err := sink.Write(&Entry{Action: "CreateRule", Project: "project-a", OperationID: "operation-1"})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Close(context.Background()), "close sends the buffered entries")
+	assert.Equal(t, "operation-1", received.OperationID)
+	assert.Error(t, sink.Write(&Entry{Action: "DeleteRule"}), "a closed sink takes no entries")
+}
+
+func Test_WebhookSinkClose(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := NewWebhookSink(server.URL, log.WithField("component", "test"))
+	start := time.Now()
+	assert.NoError(t, sink.Write(&Entry{Action: "CreateRule", Project: "project-a"}))
+	assert.NoError(t, sink.Write(&Entry{Action: "DeleteRule", Project: "project-a"}))
+	assert.Less(t, time.Since(start), time.Second, "writes do not wait for the webhook")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, sink.Close(ctx), context.DeadlineExceeded, "entries left at the deadline are dropped")
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WriterSink writes entries as JSON lines.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (in *WriterSink) Write(entry *Entry) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return json.NewEncoder(in.w).Encode(entry)
+}
+
+const (
+	// webhookBuffer is how many entries wait for the webhook before writes fail.
+	webhookBuffer = 1000
+	// webhookTimeout bounds each post to the webhook.
+	webhookTimeout = 10 * time.Second
+)
+
+// WebhookSink posts every entry as JSON to an HTTP endpoint from a goroutine, so audited calls never wait for it.
+// Entries are buffered until they are sent, Close sends those left.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	log    *logrus.Entry
+
+	mu      sync.Mutex
+	closed  bool
+	entries chan []byte
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewWebhookSink(url string, log *logrus.Entry) *WebhookSink {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := &WebhookSink{
+		url:     url,
+		client:  &http.Client{},
+		log:     log,
+		entries: make(chan []byte, webhookBuffer),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go in.run()
+	return in
+}
+
+// Write encodes the entry and queues it for the webhook, it fails when the buffer is full or the sink closed.
+func (in *WebhookSink) Write(entry *Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode audit entry: %w", err)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return fmt.Errorf("post audit entry: webhook sink is closed")
+	}
+	select {
+	case in.entries <- body:
+		return nil
+	default:
+		return fmt.Errorf("post audit entry: %d entries are waiting for the webhook", webhookBuffer)
+	}
+}
+
+// Close sends the buffered entries, those left when ctx is done are dropped.
+func (in *WebhookSink) Close(ctx context.Context) error {
+	in.mu.Lock()
+	if !in.closed {
+		in.closed = true
+		close(in.entries)
+	}
+	in.mu.Unlock()
+
+	select {
+	case <-in.done:
+		return nil
+	case <-ctx.Done():
+		in.cancel()
+		<-in.done
+		return fmt.Errorf("flush audit webhook: %w", ctx.Err())
+	}
+}
+
+func (in *WebhookSink) run() {
+	defer close(in.done)
+	dropped := 0
+	for body := range in.entries {
+		if in.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		if err := in.post(body); err != nil {
+			in.log.Errorf("failed to write audit entry: %v", err)
+		}
+	}
+	if dropped > 0 {
+		in.log.Errorf("dropped %d audit entries not sent to the webhook before shutdown", dropped)
+	}
+}
+
+func (in *WebhookSink) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(in.ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post audit entry: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post audit entry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("post audit entry: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
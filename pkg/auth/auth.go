@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nais/armor/config"
+	"github.com/sirupsen/logrus"
+)
+
+type Identity struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	PreferredUsername string   `json:"preferred_username"`
+	Email             string   `json:"email"`
+	Groups            []string `json:"groups"`
+}
+
+type contextKey struct{}
+
+// DevelopmentIdentity is attached to requests when authentication is disabled by development mode.
+var DevelopmentIdentity = &Identity{Subject: "development", Name: "development"}
+
+type Authenticator struct {
+	log      *logrus.Entry
+	issuer   string
+	audience string
+	keys     *KeySet
+}
+
+func NewAuthenticator(ctx context.Context, cfg *config.Config, log *logrus.Entry) (*Authenticator, error) {
+	if cfg.AuthIssuer == "" || cfg.AuthAudience == "" {
+		return nil, fmt.Errorf("%s and %s are required when not in development mode", config.AuthIssuer, config.AuthAudience)
+	}
+
+	keys, err := NewKeySet(ctx, cfg.AuthJwksUrl, cfg.AuthJwksFile)
+	if err != nil {
+		return nil, fmt.Errorf("load jwks: %w", err)
+	}
+
+	return &Authenticator{
+		log:      log,
+		issuer:   cfg.AuthIssuer,
+		audience: cfg.AuthAudience,
+		keys:     keys,
+	}, nil
+}
+
+// Authenticate validates the bearer token of the request and returns the identity of the caller.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, err := BearerToken(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+	return a.Verify(r.Context(), token)
+}
+
+// BearerToken returns the token of an authorization header or gRPC metadata value.
+func BearerToken(authorization string) (string, error) {
+	if authorization == "" {
+		return "", fmt.Errorf("missing authorization header")
+	}
+
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", fmt.Errorf("authorization header is not a bearer token")
+	}
+	return token, nil
+}
+
+func (a *Authenticator) Verify(ctx context.Context, token string) (*Identity, error) {
+	c := &claims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	_, err := parser.ParseWithClaims(token, c, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verify token: %w", err)
+	}
+
+	// The parser only checks the time claims present, a token without expiry would be valid forever.
+	if c.ExpiresAt == nil {
+		return nil, fmt.Errorf("verify token: missing expiry")
+	}
+
+	if c.Issuer == "" || !c.VerifyIssuer(a.issuer, true) {
+		return nil, fmt.Errorf("verify token: unexpected issuer %q", c.Issuer)
+	}
+
+	if !audience(c.Audience, a.audience) {
+		return nil, fmt.Errorf("verify token: unexpected audience %v", c.Audience)
+	}
+
+	name := c.PreferredUsername
+	if name == "" {
+		name = c.Email
+	}
+
+	return &Identity{
+		Subject: c.Subject,
+		Name:    name,
+		Groups:  c.Groups,
+	}, nil
+}
+
+// audience reports whether the audience of a token names the expected, non-empty audience.
+func audience(claimed jwt.ClaimStrings, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	for _, a := range claimed {
+		if a == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nais/armor/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "armor"
+	testKeyID    = "test-key"
+)
+
+var ctx = context.Background()
+
+func Test_Authenticate(t *testing.T) {
+	key := generateKey(t)
+	otherKey := generateKey(t)
+	jwksFile := writeKeySet(t, testKeyID, &key.PublicKey)
+
+	authenticator, err := NewAuthenticator(ctx, &config.Config{
+		AuthIssuer:   testIssuer,
+		AuthAudience: testAudience,
+		AuthJwksFile: jwksFile,
+	}, log.WithField("component", "test"))
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		name    string
+		header  string
+		subject string
+		valid   bool
+	}{
+		{
+			name:    "Valid token is accepted",
+			header:  "Bearer " + signToken(t, key, testKeyID, testIssuer, testAudience, time.Hour),
+			subject: "user",
+			valid:   true,
+		},
+		{
+			name:   "Missing authorization header is rejected",
+			header: "",
+		},
+		{
+			name:   "Non bearer authorization header is rejected",
+			header: "Basic dXNlcjpwYXNz",
+		},
+		{
+			name:   "Token from another issuer is rejected",
+			header: "Bearer " + signToken(t, key, testKeyID, "https://evil.example.com", testAudience, time.Hour),
+		},
+		{
+			name:   "Token for another audience is rejected",
+			header: "Bearer " + signToken(t, key, testKeyID, testIssuer, "other", time.Hour),
+		},
+		{
+			name:   "Token without issuer is rejected",
+			header: "Bearer " + signToken(t, key, testKeyID, "", testAudience, time.Hour),
+		},
+		{
+			name:   "Token without audience is rejected",
+			header: "Bearer " + signToken(t, key, testKeyID, testIssuer, "", time.Hour),
+		},
+		{
+			name:   "Token without expiry is rejected",
+			header: "Bearer " + signToken(t, key, testKeyID, testIssuer, testAudience, 0),
+		},
+		{
+			name:   "Expired token is rejected",
+			header: "Bearer " + signToken(t, key, testKeyID, testIssuer, testAudience, -time.Hour),
+		},
+		{
+			name:   "Token signed by unknown key is rejected",
+			header: "Bearer " + signToken(t, otherKey, testKeyID, testIssuer, testAudience, time.Hour),
+		},
+		{
+			name:   "Token with unknown key id is rejected",
+			header: "Bearer " + signToken(t, key, "unknown", testIssuer, testAudience, time.Hour),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/projects/fake-project/policies", nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+
+			identity, err := authenticator.Authenticate(r)
+			if !test.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.subject, identity.Subject)
+			assert.Equal(t, []string{"team-a"}, identity.Groups)
+		})
+	}
+}
+
+func Test_KeySetFromUrl(t *testing.T) {
+	key := generateKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(keySet(t, testKeyID, &key.PublicKey))
+	}))
+	defer server.Close()
+
+	keys, err := NewKeySet(ctx, server.URL, "")
+	assert.NoError(t, err)
+
+	k, err := keys.Key(ctx, testKeyID)
+	assert.NoError(t, err)
+	assert.Equal(t, &key.PublicKey, k)
+}
+
+func Test_KeySetFailedRefresh(t *testing.T) {
+	key := generateKey(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(keySet(t, testKeyID, &key.PublicKey))
+	}))
+	defer server.Close()
+
+	keys, err := NewKeySet(ctx, server.URL, "")
+	assert.NoError(t, err)
+	keys.lastRefresh = time.Time{}
+
+	_, err = keys.Key(ctx, "unknown")
+	assert.Error(t, err)
+	_, err = keys.Key(ctx, "unknown")
+	assert.Error(t, err)
+	assert.Equal(t, 2, requests, "a failed refresh is not retried within the refresh interval")
+
+	k, err := keys.Key(ctx, testKeyID)
+	assert.NoError(t, err, "known keys are kept when a refresh fails")
+	assert.Equal(t, &key.PublicKey, k)
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return key
+}
+
+func keySet(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	data, err := json.Marshal(jsonWebKeySet{Keys: []jsonWebKey{
+		{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		},
+	}})
+	assert.NoError(t, err)
+	return data
+}
+
+func writeKeySet(t *testing.T, kid string, key *rsa.PublicKey) string {
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	err := os.WriteFile(file, keySet(t, kid, key), 0o600)
+	assert.NoError(t, err)
+	return file
+}
+
+// signToken signs a token for the audience expiring in expiresIn, empty values and a zero expiresIn leave the claim out.
+func signToken(t *testing.T, key *rsa.PrivateKey, kid, issuer, audience string, expiresIn time.Duration) string {
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   issuer,
+			Subject:  "user",
+			IssuedAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+		Groups: []string{"team-a"},
+	}
+	if audience != "" {
+		c.Audience = jwt.ClaimStrings{audience}
+	}
+	if expiresIn != 0 {
+		c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(expiresIn))
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
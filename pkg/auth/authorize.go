@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/nais/armor/config"
+)
+
+type Verb string
+
+const (
+	VerbRead           Verb = "read"
+	VerbWriteRules     Verb = "write-rules"
+	VerbWritePolicies  Verb = "write-policies"
+	VerbAttachBackends Verb = "attach-backends"
+
+	wildcard = "*"
+)
+
+var verbs = []Verb{VerbRead, VerbWriteRules, VerbWritePolicies, VerbAttachBackends}
+
+type Authorizer struct {
+	grants []config.Grant
+}
+
+func NewAuthorizer(grants []config.Grant) (*Authorizer, error) {
+	for _, grant := range grants {
+		if grant.Group == "" {
+			return nil, fmt.Errorf("authorization grant without group")
+		}
+		for _, verb := range grant.Verbs {
+			if verb != wildcard && !knownVerb(Verb(verb)) {
+				return nil, fmt.Errorf("authorization grant for group %s: unknown verb %q", grant.Group, verb)
+			}
+		}
+	}
+	return &Authorizer{grants: grants}, nil
+}
+
+// Authorize returns an error describing why the identity is not allowed to perform verb in project.
+func (a *Authorizer) Authorize(identity *Identity, projectID string, verb Verb) error {
+	if identity == nil {
+		return fmt.Errorf("no identity attached to request")
+	}
+
+	for _, grant := range a.grants {
+		if contains(identity.Groups, grant.Group) && matches(grant.Projects, projectID) && matches(grant.Verbs, string(verb)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is not allowed to %s in project %s: no grant for any of the groups %v", identity.String(), verb, projectID, identity.Groups)
+}
+
+func (in *Identity) String() string {
+	if in.Name != "" {
+		return in.Name
+	}
+	return in.Subject
+}
+
+func knownVerb(verb Verb) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(allowed []string, value string) bool {
+	return contains(allowed, wildcard) || contains(allowed, value)
+}
+
+func contains(s []string, str string) bool {
+	for _, v := range s {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
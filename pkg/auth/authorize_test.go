@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/nais/armor/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Authorize(t *testing.T) {
+	authorizer, err := NewAuthorizer([]config.Grant{
+		{Group: "team-a", Projects: []string{"project-a"}, Verbs: []string{"read", "write-rules"}},
+		{Group: "platform", Projects: []string{"*"}, Verbs: []string{"*"}},
+	})
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		name     string
+		groups   []string
+		project  string
+		verb     Verb
+		expected bool
+	}{
+		{
+			name:     "Team can read its own project",
+			groups:   []string{"team-a"},
+			project:  "project-a",
+			verb:     VerbRead,
+			expected: true,
+		},
+		{
+			name:     "Team can write rules in its own project",
+			groups:   []string{"team-a"},
+			project:  "project-a",
+			verb:     VerbWriteRules,
+			expected: true,
+		},
+		{
+			name:     "Team can not write policies without grant",
+			groups:   []string{"team-a"},
+			project:  "project-a",
+			verb:     VerbWritePolicies,
+			expected: false,
+		},
+		{
+			name:     "Team can not read another project",
+			groups:   []string{"team-a"},
+			project:  "project-b",
+			verb:     VerbRead,
+			expected: false,
+		},
+		{
+			name:     "Wildcard grant allows everything",
+			groups:   []string{"team-a", "platform"},
+			project:  "project-b",
+			verb:     VerbAttachBackends,
+			expected: true,
+		},
+		{
+			name:     "Caller without groups is denied",
+			project:  "project-a",
+			verb:     VerbRead,
+			expected: false,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := authorizer.Authorize(&Identity{Subject: "user", Groups: test.groups}, test.project, test.verb)
+			assert.Equal(t, test.expected, err == nil)
+		})
+	}
+}
+
+func Test_NewAuthorizerUnknownVerb(t *testing.T) {
+	_, err := NewAuthorizer([]config.Grant{{Group: "team-a", Projects: []string{"*"}, Verbs: []string{"delete-everything"}}})
+	assert.Error(t, err)
+}
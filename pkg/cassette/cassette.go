@@ -0,0 +1,272 @@
+// Package cassette records interactions with an HTTP API into cassette files and replays them, so tests run against
+// what the API actually returned without calling it.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/option"
+)
+
+type Mode string
+
+const (
+	ModeReplay Mode = "replay"
+	ModeRecord Mode = "record"
+)
+
+// tokens matches OAuth access tokens, which are scrubbed wherever they appear.
+var tokens = regexp.MustCompile(`ya29\.[A-Za-z0-9._-]+`)
+
+// Cassette is the recorded interactions, in the order they were made.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status      int             `json:"status"`
+	ContentType string          `json:"content-type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	// Text is the body of responses that are not JSON.
+	Text string `json:"text,omitempty"`
+}
+
+// Replacement scrubs a value from recorded interactions, e.g. a project id, replacing it with a placeholder.
+// Requests are matched against replayed interactions after the same replacements.
+type Replacement struct {
+	Old string
+	New string
+}
+
+// Recorder is an http.RoundTripper recording interactions through a transport, or replaying them from a cassette.
+// Request headers are never recorded, so credentials do not end up in cassettes.
+type Recorder struct {
+	path         string
+	mode         Mode
+	transport    http.RoundTripper
+	replacements []Replacement
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New returns a recorder of the cassette at path. When recording, requests are made with transport,
+// when replaying, the cassette must exist.
+func New(path string, mode Mode, transport http.RoundTripper, replacements ...Replacement) (*Recorder, error) {
+	r := &Recorder{
+		path:         path,
+		mode:         mode,
+		transport:    transport,
+		replacements: replacements,
+		cassette:     &Cassette{},
+	}
+
+	switch mode {
+	case ModeRecord:
+		if transport == nil {
+			return nil, fmt.Errorf("recording %s requires a transport", path)
+		}
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, r.cassette); err != nil {
+			return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q", mode)
+	}
+	return r, nil
+}
+
+// ClientOptions make Google API clients send their requests through the recorder.
+func (in *Recorder) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: in})}
+}
+
+func (in *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	request := Request{
+		Method: req.Method,
+		URL:    in.scrub(req.URL.String()),
+		Body:   in.jsonBody(body),
+	}
+
+	if in.mode == ModeReplay {
+		return in.replay(req, request)
+	}
+	return in.record(req, request)
+}
+
+func (in *Recorder) record(req *http.Request, request Request) (*http.Response, error) {
+	response, err := in.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorded := Response{
+		Status:      response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        in.jsonBody(body),
+	}
+	if recorded.Body == nil && len(body) > 0 {
+		recorded.Text = in.scrub(string(body))
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.cassette.Interactions = append(in.cassette.Interactions, &Interaction{Request: request, Response: recorded})
+	return response, nil
+}
+
+// replay answers the first interaction not replayed yet with the same method, url and body as the request.
+func (in *Recorder) replay(req *http.Request, request Request) (*http.Response, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for i, interaction := range in.cassette.Interactions {
+		if in.used[i] || !matches(interaction.Request, request) {
+			continue
+		}
+		in.used[i] = true
+
+		recorded := interaction.Response
+		body := []byte(recorded.Text)
+		if recorded.Body != nil {
+			body = recorded.Body
+		}
+		header := http.Header{}
+		if recorded.ContentType != "" {
+			header.Set("Content-Type", recorded.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+			StatusCode:    recorded.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction in %s matches %s %s %s", in.path, request.Method, request.URL, request.Body)
+}
+
+// Unused returns the interactions that have not been replayed, a replay should have made every recorded request.
+func (in *Recorder) Unused() []*Interaction {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	var unused []*Interaction
+	for i, interaction := range in.cassette.Interactions {
+		if !in.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the cassette, replaying recorders have nothing to save.
+func (in *Recorder) Save() error {
+	if in.mode != ModeRecord {
+		return nil
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	data, err := json.MarshalIndent(in.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(in.path), 0o755); err != nil {
+		return fmt.Errorf("create cassette directory: %w", err)
+	}
+	return os.WriteFile(in.path, append(data, '\n'), 0o644)
+}
+
+func (in *Recorder) scrub(s string) string {
+	s = tokens.ReplaceAllString(s, "REDACTED")
+	for _, replacement := range in.replacements {
+		if replacement.Old != "" {
+			s = strings.ReplaceAll(s, replacement.Old, replacement.New)
+		}
+	}
+	return s
+}
+
+// jsonBody returns a scrubbed and compacted JSON body, or nil if body is empty or not JSON.
+func (in *Recorder) jsonBody(body []byte) json.RawMessage {
+	scrubbed := []byte(in.scrub(string(body)))
+	if len(bytes.TrimSpace(scrubbed)) == 0 || !json.Valid(scrubbed) {
+		return nil
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, scrubbed); err != nil {
+		return nil
+	}
+	return compacted.Bytes()
+}
+
+func matches(recorded, request Request) bool {
+	if recorded.Method != request.Method || recorded.URL != request.URL {
+		return false
+	}
+	if recorded.Body == nil || request.Body == nil {
+		return recorded.Body == nil && request.Body == nil
+	}
+
+	// Bodies are compared as JSON values, so the order of fields does not matter.
+	var want, got interface{}
+	if json.Unmarshal(recorded.Body, &want) != nil || json.Unmarshal(request.Body, &got) != nil {
+		return bytes.Equal(recorded.Body, request.Body)
+	}
+	return equalJSON(want, got)
+}
+
+func equalJSON(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
@@ -0,0 +1,80 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const token = "ya29.a0AfH6SMBsecret-token_value"
+
+func Test_recordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 {
+			body = []byte("null")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusConflict)
+		}
+		_, _ = w.Write([]byte(`{"selfLink":"projects/real-project/` + r.URL.Path[1:] + `","token":"` + token + `","request":` + string(body) + `}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+	recorder, err := New(path, ModeRecord, http.DefaultTransport, Replacement{Old: "real-project", New: "fake-project"})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: recorder}
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/real-project/policies", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := client.Do(request)
+	assert.NoError(t, err)
+	recordedGet, _ := io.ReadAll(response.Body)
+	assert.Contains(t, string(recordedGet), "real-project", "the caller gets the real response while recording")
+
+	response, err = client.Post(server.URL+"/real-project/policies", "application/json", strings.NewReader(`{"name":"a","project":"real-project"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+	assert.NoError(t, recorder.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "real-project")
+	assert.NotContains(t, string(data), token)
+	assert.NotContains(t, string(data), "Authorization")
+
+	replayer, err := New(path, ModeReplay, nil)
+	assert.NoError(t, err)
+	client = &http.Client{Transport: replayer}
+	assert.Len(t, replayer.Unused(), 2)
+
+	response, err = client.Post(server.URL+"/fake-project/policies", "application/json", strings.NewReader(`{"project":"fake-project","name":"a"}`))
+	assert.NoError(t, err, "bodies match regardless of field order")
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, err = client.Get(server.URL + "/fake-project/policies")
+	assert.NoError(t, err)
+	replayed, _ := io.ReadAll(response.Body)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"selfLink":"projects/fake-project/fake-project/policies","token":"REDACTED","request":null}`, string(replayed))
+	assert.Empty(t, replayer.Unused())
+
+	_, err = client.Get(server.URL + "/fake-project/policies")
+	assert.ErrorContains(t, err, "no recorded interaction", "every interaction is replayed once")
+}
+
+func Test_replayRequiresCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil)
+	assert.Error(t, err)
+
+	_, err = New(filepath.Join(t.TempDir(), "missing.json"), ModeRecord, nil)
+	assert.Error(t, err, "recording needs a transport")
+}
@@ -0,0 +1,271 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nais/armor/pkg/model"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+// Operation is a change armor runs in the background.
+type Operation struct {
+	ID              string          `json:"id"`
+	Project         string          `json:"project"`
+	Action          string          `json:"action"`
+	Status          string          `json:"status"`
+	GoogleOperation string          `json:"google-operation,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Resource        json.RawMessage `json:"resource,omitempty"`
+	Created         time.Time       `json:"created"`
+	Finished        *time.Time      `json:"finished,omitempty"`
+}
+
+// AuditEntry is a change recorded in the audit log of a project.
+type AuditEntry struct {
+	Time        time.Time       `json:"time"`
+	User        string          `json:"user"`
+	Action      string          `json:"action"`
+	Project     string          `json:"project"`
+	Policy      string          `json:"policy,omitempty"`
+	Priority    *int32          `json:"priority,omitempty"`
+	Backend     string          `json:"backend,omitempty"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	Outcome     string          `json:"outcome"`
+	Error       string          `json:"error,omitempty"`
+	OperationID string          `json:"operation-id,omitempty"`
+}
+
+// ListOptions select the items of a list of policies or backend services, zero values list every item with every
+// field.
+type ListOptions struct {
+	// Filter is a Compute API filter expression, e.g. name = "my-policy".
+	Filter string
+	// Fields are the fields of each item to return, nested fields separated by dots, e.g. rules.priority.
+	Fields []string
+	// PageSize limits the list to a page of at most 500 items, the next page is listed with the returned token as
+	// PageToken.
+	PageSize  int
+	PageToken string
+}
+
+func (o *ListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Filter != "" {
+		query.Set("filter", o.Filter)
+	}
+	if len(o.Fields) > 0 {
+		query.Set("fields", strings.Join(o.Fields, ","))
+	}
+	if o.PageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(o.PageSize))
+	}
+	if o.PageToken != "" {
+		query.Set("pageToken", o.PageToken)
+	}
+	return query
+}
+
+// RuleQuery selects, orders and pages the rules of a policy, zero values are not filtered on.
+type RuleQuery struct {
+	// Action is e.g. allow, deny(403) or deny for any deny status.
+	Action      string
+	Preview     *bool
+	MinPriority *int32
+	MaxPriority *int32
+	// SrcIP is an address or CIDR range contained in a source IP range of the rules.
+	SrcIP string
+	// ExpressionSet is a preconfigured WAF expression set evaluated by the rules, e.g. sqli-v33-stable or sqli.
+	ExpressionSet string
+	Description   string
+	// OrderBy is priority, action or description, optionally followed by asc or desc.
+	OrderBy   string
+	PageSize  int
+	PageToken string
+}
+
+func (q *RuleQuery) values() url.Values {
+	query := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	set("action", q.Action)
+	if q.Preview != nil {
+		set("preview", strconv.FormatBool(*q.Preview))
+	}
+	if q.MinPriority != nil {
+		set("minPriority", strconv.Itoa(int(*q.MinPriority)))
+	}
+	if q.MaxPriority != nil {
+		set("maxPriority", strconv.Itoa(int(*q.MaxPriority)))
+	}
+	set("srcIp", q.SrcIP)
+	set("expressionSet", q.ExpressionSet)
+	set("description", q.Description)
+	set("orderBy", q.OrderBy)
+	if q.PageSize > 0 {
+		set("pageSize", strconv.Itoa(q.PageSize))
+	}
+	set("pageToken", q.PageToken)
+	return query
+}
+
+func (c *Client) ListPolicies(ctx context.Context, project string) ([]*computepb.SecurityPolicy, error) {
+	var policies []*computepb.SecurityPolicy
+	err := c.do(ctx, http.MethodGet, policiesPath(project), nil, nil, &policies)
+	return policies, err
+}
+
+// ListPoliciesPage returns the policies selected by opts, and the token of the next page if there are more.
+func (c *Client) ListPoliciesPage(ctx context.Context, project string, opts *ListOptions) ([]*computepb.SecurityPolicy, string, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	var policies []*computepb.SecurityPolicy
+	header, err := c.exchange(ctx, http.MethodGet, policiesPath(project), opts.values(), nil, &policies)
+	if err != nil {
+		return nil, "", err
+	}
+	return policies, header.Get(headerNextPageToken), nil
+}
+
+func (c *Client) GetPolicy(ctx context.Context, project, policy string) (*computepb.SecurityPolicy, error) {
+	result := &computepb.SecurityPolicy{}
+	if err := c.do(ctx, http.MethodGet, policyPath(project, policy), nil, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Client) CreatePolicy(ctx context.Context, project string, policy *computepb.SecurityPolicy) error {
+	return c.do(ctx, http.MethodPost, policiesPath(project), nil, &model.ArmorRequestPolicy{SecurityPolicy: policy}, nil)
+}
+
+// UpdatePolicy changes the fields set in policy, rules are changed with the rule methods.
+func (c *Client) UpdatePolicy(ctx context.Context, project, name string, policy *computepb.SecurityPolicy) error {
+	return c.do(ctx, http.MethodPatch, policyPath(project, name), nil, &model.ArmorRequestPolicy{SecurityPolicy: policy}, nil)
+}
+
+func (c *Client) DeletePolicy(ctx context.Context, project, policy string) error {
+	return c.do(ctx, http.MethodDelete, policyPath(project, policy), nil, nil, nil)
+}
+
+func (c *Client) GetRule(ctx context.Context, project, policy string, priority int32) (*computepb.SecurityPolicyRule, error) {
+	result := &computepb.SecurityPolicyRule{}
+	if err := c.do(ctx, http.MethodGet, rulePath(project, policy, priority), nil, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListRules returns the rules of the policy matching the query, and the token of the next page if there are more.
+// The next page is listed with the same query and the token as PageToken.
+func (c *Client) ListRules(ctx context.Context, project, policy string, query *RuleQuery) ([]*computepb.SecurityPolicyRule, string, error) {
+	if query == nil {
+		query = &RuleQuery{}
+	}
+	var rules []*computepb.SecurityPolicyRule
+	header, err := c.exchange(ctx, http.MethodGet, policyPath(project, policy)+"/rules", query.values(), nil, &rules)
+	if err != nil {
+		return nil, "", err
+	}
+	return rules, header.Get(headerNextPageToken), nil
+}
+
+func (c *Client) CreateRule(ctx context.Context, project, policy string, rule *computepb.SecurityPolicyRule) error {
+	return c.do(ctx, http.MethodPost, policyPath(project, policy)+"/rules", nil, &model.ArmorRequestRule{SecurityPolicyRule: rule}, nil)
+}
+
+// UpdateRule changes the fields set in rule of the rule with the given priority.
+func (c *Client) UpdateRule(ctx context.Context, project, policy string, priority int32, rule *computepb.SecurityPolicyRule) error {
+	return c.do(ctx, http.MethodPatch, rulePath(project, policy, priority), nil, &model.ArmorRequestRule{SecurityPolicyRule: rule}, nil)
+}
+
+func (c *Client) DeleteRule(ctx context.Context, project, policy string, priority int32) error {
+	return c.do(ctx, http.MethodDelete, rulePath(project, policy, priority), nil, nil, nil)
+}
+
+// ListPreConfiguredRules returns the preconfigured WAF expression sets, of the given rule type and version if set,
+// e.g. sqli and v33-stable.
+func (c *Client) ListPreConfiguredRules(ctx context.Context, project, ruleType, version string) ([]*computepb.WafExpressionSet, error) {
+	query := url.Values{}
+	if ruleType != "" {
+		query.Set("rule-type", ruleType)
+	}
+	if version != "" {
+		query.Set("version", version)
+	}
+
+	var sets []*computepb.WafExpressionSet
+	err := c.do(ctx, http.MethodGet, "/projects/"+project+"/preConfiguredRules", query, nil, &sets)
+	return sets, err
+}
+
+func (c *Client) ListBackendServices(ctx context.Context, project string) ([]*computepb.BackendService, error) {
+	var backends []*computepb.BackendService
+	err := c.do(ctx, http.MethodGet, "/projects/"+project+"/backendServices", nil, nil, &backends)
+	return backends, err
+}
+
+// ListBackendServicesPage returns the backend services selected by opts, and the token of the next page if there are
+// more.
+func (c *Client) ListBackendServicesPage(ctx context.Context, project string, opts *ListOptions) ([]*computepb.BackendService, string, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	var backends []*computepb.BackendService
+	header, err := c.exchange(ctx, http.MethodGet, "/projects/"+project+"/backendServices", opts.values(), nil, &backends)
+	if err != nil {
+		return nil, "", err
+	}
+	return backends, header.Get(headerNextPageToken), nil
+}
+
+// SetPolicyBackend attaches the policy to the backend service.
+func (c *Client) SetPolicyBackend(ctx context.Context, project, policy, backend string) error {
+	return c.do(ctx, http.MethodPost, policyPath(project, policy)+"/backendServices/"+backend, nil, nil, nil)
+}
+
+func (c *Client) GetOperation(ctx context.Context, id string) (*Operation, error) {
+	result := &Operation{}
+	if err := c.do(ctx, http.MethodGet, "/operations/"+id, nil, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAudit returns at most limit of the most recent changes in the project since the given time,
+// armor defaults are used for zero values.
+func (c *Client) GetAudit(ctx context.Context, project string, since time.Time, limit int) ([]*AuditEntry, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.Format(time.RFC3339))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var entries []*AuditEntry
+	err := c.do(ctx, http.MethodGet, "/projects/"+project+"/audit", query, nil, &entries)
+	return entries, err
+}
+
+func policiesPath(project string) string {
+	return "/projects/" + project + "/policies"
+}
+
+func policyPath(project, policy string) string {
+	return policiesPath(project) + "/" + policy
+}
+
+func rulePath(project, policy string, priority int32) string {
+	return policyPath(project, policy) + "/rules/" + strconv.Itoa(int(priority))
+}
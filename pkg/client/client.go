@@ -0,0 +1,283 @@
+// Package client is a Go client of the armor REST API.
+//
+// Errors answered by armor are returned as *Problem, and can be matched with errors.Is against ErrNotFound,
+// ErrConflict and the other sentinel errors of the package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeProblem = "application/problem+json"
+
+	headerNextPageToken = "X-Next-Page-Token"
+
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 200 * time.Millisecond
+	maxBackoff            = 5 * time.Second
+)
+
+// Client calls armor at a base URL, e.g. https://armor.example.com.
+type Client struct {
+	baseURL        *url.URL
+	httpClient     *http.Client
+	tokenSource    oauth2.TokenSource
+	header         http.Header
+	maxAttempts    int
+	initialBackoff time.Duration
+}
+
+type Option func(c *Client)
+
+// WithHTTPClient sends requests with the given client instead of http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHandler serves every request in-process with handler, e.g. the router of an armor handler in the same process.
+func WithHandler(handler http.Handler) Option {
+	return WithHTTPClient(&http.Client{Transport: &handlerTransport{handler: handler}})
+}
+
+// WithToken authenticates every request with a static bearer token.
+func WithToken(token string) Option {
+	return WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+}
+
+// WithTokenSource authenticates every request with a bearer token from the token source, e.g. a Google ID token source.
+func WithTokenSource(tokenSource oauth2.TokenSource) Option {
+	return func(c *Client) {
+		c.tokenSource = tokenSource
+	}
+}
+
+// WithHeader sets a header on every request, e.g. the forwarded access token of the caller.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithRetries sets how many times reads are attempted when armor is unavailable, and the backoff before the first retry.
+// The backoff doubles for every attempt, a Retry-After from armor takes precedence.
+func WithRetries(maxAttempts int, initialBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+		c.initialBackoff = initialBackoff
+	}
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base url must be absolute: %s", baseURL)
+	}
+
+	c := &Client{
+		baseURL:        u,
+		httpClient:     http.DefaultClient,
+		header:         http.Header{},
+		maxAttempts:    defaultMaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// handlerTransport round trips requests through a handler in-process.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t *handlerTransport) RoundTrip(r *http.Request) (response *http.Response, err error) {
+	defer func() {
+		// A handler aborts a response it already started, like a list failing after its first page.
+		if recovered := recover(); recovered != nil {
+			if recovered != http.ErrAbortHandler {
+				panic(recovered)
+			}
+			response, err = nil, fmt.Errorf("%s %s: response aborted by armor", r.Method, r.URL.Path)
+		}
+	}()
+
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	defer r.Body.Close()
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, r)
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+	response = w.Result()
+	response.Request = r
+	return response, nil
+}
+
+// do sends the request and decodes a JSON response into out, if given. Reads are retried when armor is unavailable,
+// changes are not, armor retries its own calls to Google.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	_, err := c.exchange(ctx, method, path, query, body, out)
+	return err
+}
+
+// exchange is do returning the headers of the response as well.
+func (c *Client) exchange(ctx context.Context, method, path string, query url.Values, body, out interface{}) (http.Header, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	attempts := 1
+	if method == http.MethodGet && c.maxAttempts > 1 {
+		attempts = c.maxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		response, err := c.send(ctx, method, path, query, data)
+		if err == nil && (attempt >= attempts || !retryable(response.StatusCode)) {
+			defer response.Body.Close()
+			return response.Header, decode(response, out)
+		}
+		if err != nil && (attempt >= attempts || ctx.Err() != nil) {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt)
+		if err == nil {
+			if after, ok := retryAfter(response); ok {
+				wait = after
+			}
+			_, _ = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, data []byte) (*http.Response, error) {
+	request, err := c.newRequest(ctx, method, path, query, data)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return response, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, data []byte) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for key, values := range c.header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Accept", contentTypeJSON+", "+contentTypeProblem)
+	if data != nil {
+		request.Header.Set("Content-Type", contentTypeJSON)
+	}
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token()
+		if err != nil {
+			return nil, fmt.Errorf("get token: %w", err)
+		}
+		token.SetAuthHeader(request)
+	}
+	return request, nil
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.initialBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// decode returns the problem in an error response, or in a response answered while a change is still running.
+func decode(response *http.Response, out interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == contentTypeProblem || response.StatusCode >= http.StatusBadRequest {
+		return decodeProblem(response)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil && err != io.EOF {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func decodeProblem(response *http.Response) error {
+	problem := &Problem{}
+	data, err := io.ReadAll(response.Body)
+	if err != nil || json.Unmarshal(data, problem) != nil || problem.Status == 0 {
+		// Not a problem from armor, e.g. from a proxy in front of it.
+		problem = &Problem{Status: response.StatusCode, Title: http.StatusText(response.StatusCode), Detail: strings.TrimSpace(string(data))}
+	}
+	return problem
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryAfter(response *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
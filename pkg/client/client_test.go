@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/events"
+	"github.com/nais/armor/pkg/handler"
+	"github.com/nais/armor/pkg/memory"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const project = "client-project"
+
+var ctx = context.Background()
+
+// armor returns the router of armor calling an in-memory Cloud Armor, seeded with a policy and a backend service.
+func armor(t *testing.T) (*memory.Compute, http.Handler) {
+	compute := memory.New()
+	compute.AddPolicy(project, &computepb.SecurityPolicy{Name: proto.String("policy")})
+	compute.AddBackend(project, &computepb.BackendService{Name: proto.String("backend")})
+	compute.SetPreconfiguredExpressionSets([]*computepb.WafExpressionSet{
+		{Id: proto.String("sqli-v33-stable")},
+		{Id: proto.String("xss-v33-stable")},
+	})
+
+	cfg := &config.Config{
+		DevelopmentMode: true,
+		ProtectedRules:  []string{"1000", "2147483647"},
+		ReadDeadline:    5 * time.Second,
+		WriteDeadline:   5 * time.Second,
+	}
+	entry := log.WithField("component", "test")
+	auditor := audit.New(audit.NewWriterSink(io.Discard), 100, entry)
+	h := handler.NewHandler(ctx, cfg, compute, compute, entry, handler.WithAuditor(auditor))
+	return compute, handler.SetupHttpRouter(h)
+}
+
+func newClient(t *testing.T, h http.Handler, opts ...Option) *Client {
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, append([]Option{WithRetries(3, time.Millisecond)}, opts...)...)
+	assert.NoError(t, err)
+	return c
+}
+
+func Test_endpoints(t *testing.T) {
+	_, h := armor(t)
+	c := newClient(t, h)
+
+	assert.NoError(t, c.CreatePolicy(ctx, project, &computepb.SecurityPolicy{Name: proto.String("new-policy"), Description: proto.String("created")}))
+	policies, err := c.ListPolicies(ctx, project)
+	assert.NoError(t, err)
+	assert.Len(t, policies, 2)
+
+	assert.NoError(t, c.UpdatePolicy(ctx, project, "new-policy", &computepb.SecurityPolicy{Description: proto.String("updated")}))
+	policy, err := c.GetPolicy(ctx, project, "new-policy")
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", policy.GetDescription())
+	assert.Len(t, policy.GetRules(), 1)
+
+	rule := &computepb.SecurityPolicyRule{
+		Priority: proto.Int32(20),
+		Action:   proto.String("deny(403)"),
+		Preview:  proto.Bool(false),
+		Match: &computepb.SecurityPolicyRuleMatcher{
+			VersionedExpr: proto.String("SRC_IPS_V1"),
+			Config:        &computepb.SecurityPolicyRuleMatcherConfig{SrcIpRanges: []string{"203.0.113.0/24"}},
+		},
+	}
+	assert.NoError(t, c.CreateRule(ctx, project, "new-policy", rule))
+	assert.NoError(t, c.UpdateRule(ctx, project, "new-policy", 20, &computepb.SecurityPolicyRule{Description: proto.String("block")}))
+	got, err := c.GetRule(ctx, project, "new-policy", 20)
+	assert.NoError(t, err)
+	assert.Equal(t, "deny(403)", got.GetAction())
+	assert.Equal(t, "block", got.GetDescription())
+	rules, next, err := c.ListRules(ctx, project, "new-policy", &RuleQuery{Action: "deny", PageSize: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, int32(20), rules[0].GetPriority())
+	}
+	assert.Empty(t, next, "the only deny rule")
+	assert.NoError(t, c.DeleteRule(ctx, project, "new-policy", 20))
+
+	sets, err := c.ListPreConfiguredRules(ctx, project, "sqli", "v33-stable")
+	assert.NoError(t, err)
+	if assert.Len(t, sets, 1) {
+		assert.Equal(t, "sqli-v33-stable", sets[0].GetId())
+	}
+
+	assert.NoError(t, c.SetPolicyBackend(ctx, project, "new-policy", "backend"))
+	backends, err := c.ListBackendServices(ctx, project)
+	assert.NoError(t, err)
+	if assert.Len(t, backends, 1) {
+		assert.Equal(t, policy.GetSelfLink(), backends[0].GetSecurityPolicy())
+	}
+	backends, next, err = c.ListBackendServicesPage(ctx, project, &ListOptions{Filter: "securityPolicy:*", Fields: []string{"name"}, PageSize: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, backends, 1) {
+		assert.Equal(t, "backend", backends[0].GetName())
+		assert.Empty(t, backends[0].GetSecurityPolicy(), "only the fields asked for are returned")
+	}
+	assert.Empty(t, next)
+	policies, next, err = c.ListPoliciesPage(ctx, project, &ListOptions{PageSize: 1})
+	assert.NoError(t, err)
+	assert.Len(t, policies, 1)
+	assert.NotEmpty(t, next)
+
+	entries, err := c.GetAudit(ctx, project, time.Time{}, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "SetPolicyBackend", entries[0].Action)
+	}
+
+	err = c.DeletePolicy(ctx, project, "new-policy")
+	assert.ErrorIs(t, err, ErrConflict, "the policy is attached to a backend service")
+	assert.NoError(t, c.DeletePolicy(ctx, project, "policy"))
+}
+
+func Test_problems(t *testing.T) {
+	_, h := armor(t)
+	c := newClient(t, h)
+
+	_, err := c.GetPolicy(ctx, project, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	var problem *Problem
+	if assert.ErrorAs(t, err, &problem) {
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+		assert.Equal(t, "not-found", problem.Kind())
+		assert.NotEmpty(t, problem.RequestID)
+	}
+
+	err = c.DeleteRule(ctx, project, "policy", 1000)
+	assert.ErrorIs(t, err, ErrProtectedRule)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = c.GetOperation(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = c.CreateRule(ctx, project, "policy", &computepb.SecurityPolicyRule{Priority: proto.Int32(20), Action: proto.String("allow")})
+	assert.ErrorIs(t, err, ErrBadRequest)
+	if assert.ErrorAs(t, err, &problem) {
+		assert.Contains(t, problem.InvalidParams, InvalidParam{Name: "rule.match", Reason: `property "match" is missing`})
+	}
+}
+
+func Test_authentication(t *testing.T) {
+	_, h := armor(t)
+	var authorization, forwarded string
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, forwarded = r.Header.Get("Authorization"), r.Header.Get("X-Forwarded-Access-Token")
+		h.ServeHTTP(w, r)
+	}), WithToken("id-token"), WithHeader("X-Forwarded-Access-Token", "access-token"))
+
+	_, err := c.ListPolicies(ctx, project)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer id-token", authorization)
+	assert.Equal(t, "access-token", forwarded)
+}
+
+func Test_retries(t *testing.T) {
+	_, h := armor(t)
+	var requests, failures int32
+	failures = 2
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+
+	_, err := c.ListPolicies(ctx, project)
+	assert.NoError(t, err, "reads are retried")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&failures, 1)
+	err = c.CreatePolicy(ctx, project, &computepb.SecurityPolicy{Name: proto.String("not-retried")})
+	assert.ErrorIs(t, err, ErrUnavailable, "changes are not retried")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	var problem *Problem
+	if errors.As(err, &problem) {
+		assert.Equal(t, "upstream unavailable", problem.Detail, "errors not from armor keep their body")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.ListPolicies(canceled, project)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_events(t *testing.T) {
+	_, h := armor(t)
+	c := newClient(t, h)
+	assert.NoError(t, c.CreatePolicy(ctx, project, &computepb.SecurityPolicy{Name: proto.String("new-policy")}))
+
+	// The stream only has events published once it is subscribed, the policy is updated until one arrives.
+	stop := errors.New("stop")
+	first := make(chan *events.Event, 1)
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- c.Events(ctx, project, "", func(event *events.Event) error {
+			first <- event
+			return stop
+		})
+	}()
+	var patched *events.Event
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, c.UpdatePolicy(ctx, project, "new-policy", &computepb.SecurityPolicy{Description: proto.String("updated")}))
+		select {
+		case patched = <-first:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+	assert.ErrorIs(t, <-streamed, stop)
+	assert.Equal(t, events.PolicyPatched, patched.Type)
+
+	assert.NoError(t, c.DeletePolicy(ctx, project, "new-policy"))
+	var received []*events.Event
+	err := c.Events(ctx, project, patched.ID, func(event *events.Event) error {
+		received = append(received, event)
+		if event.Type == events.PolicyDeleted {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop, "events after the last event are replayed")
+	for _, event := range received {
+		assert.NotEqual(t, patched.ID, event.ID, "the last event is not replayed")
+	}
+
+	err = c.Events(ctx, "Invalid_Project", "", func(event *events.Event) error { return nil })
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const problemTypePrefix = "urn:armor:problem:"
+
+// Sentinel errors matching a *Problem with errors.Is.
+var (
+	ErrBadRequest       = errors.New("bad request")
+	ErrProtectedRule    = errors.New("protected rule")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrForbidden        = errors.New("forbidden")
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrUnavailable      = errors.New("unavailable")
+	ErrOperationRunning = errors.New("operation still running")
+)
+
+// Problem is the RFC 7807 problem details armor answers errors with.
+type Problem struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Status    int      `json:"status"`
+	Detail    string   `json:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	RequestID string   `json:"request-id,omitempty"`
+	Reasons   []string `json:"reasons,omitempty"`
+	// GoogleOperation is the Google operation of a change that is still running.
+	GoogleOperation string `json:"google-operation,omitempty"`
+	// InvalidParams are the fields of a request body not matching the OpenAPI document of armor.
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam is a field of a request body by its path, e.g. rule.match.config.src_ip_ranges[0].
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (p *Problem) Error() string {
+	message := fmt.Sprintf("armor: %d %s", p.Status, p.Title)
+	if p.Detail != "" {
+		message += ": " + p.Detail
+	}
+	if p.RequestID != "" {
+		message += " (request " + p.RequestID + ")"
+	}
+	return message
+}
+
+// Kind returns the type of the problem without its prefix, e.g. not-found or google-api.
+func (p *Problem) Kind() string {
+	return strings.TrimPrefix(p.Type, problemTypePrefix)
+}
+
+func (p *Problem) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return p.Status == http.StatusBadRequest
+	case ErrProtectedRule:
+		return p.Kind() == "protected-rule"
+	case ErrUnauthenticated:
+		return p.Status == http.StatusUnauthorized
+	case ErrForbidden:
+		return p.Status == http.StatusForbidden
+	case ErrNotFound:
+		return p.Status == http.StatusNotFound
+	case ErrConflict:
+		return p.Status == http.StatusConflict || p.Status == http.StatusPreconditionFailed
+	case ErrUnavailable:
+		return p.Status == http.StatusTooManyRequests || p.Status == http.StatusBadGateway ||
+			p.Status == http.StatusServiceUnavailable || p.Status == http.StatusGatewayTimeout
+	case ErrOperationRunning:
+		return p.Kind() == "operation-running"
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nais/armor/pkg/events"
+)
+
+// Events streams the changes in the project to fn until ctx is done, fn returns an error or armor ends the stream.
+// Events after lastEventID are replayed first when armor still has them, an empty id or one from before armor
+// restarted streams only new events. A stream ended by armor returns nil, and is resumed by calling Events with
+// the ID of the last received event.
+func (c *Client) Events(ctx context.Context, project, lastEventID string, fn func(*events.Event) error) error {
+	path := "/projects/" + project + "/events"
+	request, err := c.newRequest(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "text/event-stream, "+contentTypeProblem)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", http.MethodGet, path, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return decodeProblem(response)
+	}
+
+	// Only data lines are read, every event carries its id and type in its JSON as well.
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		event := &events.Event{}
+		if err := json.Unmarshal([]byte(data), event); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,59 @@
+// Package cloudarmor defines the Cloud Armor operations armor is built on, implemented against Google by package
+// google and in memory by package memory.
+//
+// Lists are returned whole, with pagination handled by the implementation, or a page at a time selected by
+// ListOptions. Errors are armor errors, see package
+// armorerr, except a *google.OperationRunningError when a change was accepted but is still running.
+package cloudarmor
+
+import (
+	"context"
+
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+// ListOptions select a page of the resources of a list, like the fields of the Compute API list requests.
+type ListOptions struct {
+	// Filter is a Compute API filter expression, e.g. name = "my-policy".
+	Filter string
+	// PageSize is the maximum number of resources on the page, 500 when 0.
+	PageSize int
+	// PageToken is the next page token of the previous page, the first page when empty.
+	PageToken string
+}
+
+// SecurityPolicies manages security policies and their rules in a project.
+type SecurityPolicies interface {
+	ListPolicies(ctx context.Context, projectID string) ([]*computepb.SecurityPolicy, error)
+	// ListPoliciesPage returns a page of the policies in the project, and the token of the next page if there are more.
+	ListPoliciesPage(ctx context.Context, projectID string, opts ListOptions) ([]*computepb.SecurityPolicy, string, error)
+	GetPolicy(ctx context.Context, projectID, policyName string) (*computepb.SecurityPolicy, error)
+	CreatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID string) (*computepb.Operation, error)
+	UpdatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID, policyName string) (*computepb.Operation, error)
+	DeletePolicy(ctx context.Context, projectID, policyName string) (*computepb.Operation, error)
+
+	GetRule(ctx context.Context, priority *int32, projectID, policyName string) (*computepb.SecurityPolicyRule, error)
+	AddRule(ctx context.Context, rule *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error)
+	UpdateRule(ctx context.Context, rule *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error)
+	RemoveRule(ctx context.Context, priority *int32, projectID, policyName string) (*computepb.Operation, error)
+
+	// ListPreConfiguredRules returns the preconfigured WAF expression sets rules can refer to.
+	ListPreConfiguredRules(ctx context.Context, projectID string) ([]*computepb.WafExpressionSet, error)
+
+	// Probe verifies that security policies in the project can be read.
+	Probe(ctx context.Context, projectID string) error
+}
+
+// BackendServices reads backend services in a project and attaches security policies to them.
+type BackendServices interface {
+	ListBackendServices(ctx context.Context, projectID string) ([]*computepb.BackendService, error)
+	// ListBackendServicesPage returns a page of the backend services in the project, and the token of the next page if
+	// there are more.
+	ListBackendServicesPage(ctx context.Context, projectID string, opts ListOptions) ([]*computepb.BackendService, string, error)
+	GetBackendService(ctx context.Context, projectID, backendService string) (*computepb.BackendService, error)
+	// SetSecurityPolicy attaches the policy with the given self link to the backend service.
+	SetSecurityPolicy(ctx context.Context, projectID string, policy *string, backendService string) (*computepb.Operation, error)
+
+	// Probe verifies that backend services in the project can be read.
+	Probe(ctx context.Context, projectID string) error
+}
@@ -0,0 +1,151 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PolicyCreated     = "policy.created"
+	PolicyPatched     = "policy.patched"
+	PolicyDeleted     = "policy.deleted"
+	RuleAdded         = "rule.added"
+	RulePatched       = "rule.patched"
+	RuleRemoved       = "rule.removed"
+	BackendAttached   = "backend.attached"
+	OperationStarted  = "operation.started"
+	OperationFinished = "operation.finished"
+	OperationFailed   = "operation.failed"
+	OperationRunning  = "operation.running"
+
+	// subscriberBuffer is how many events a subscriber may lag behind before it is disconnected.
+	subscriberBuffer = 64
+)
+
+// Event is a change in a project. Its ID is the epoch of the broker and a sequence, <epoch>-<sequence>, so IDs
+// published before armor restarted are not mistaken for those published since.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Project   string    `json:"project"`
+	Policy    string    `json:"policy,omitempty"`
+	Priority  *int32    `json:"priority,omitempty"`
+	Backend   string    `json:"backend,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Error     string    `json:"error,omitempty"`
+
+	sequence uint64
+}
+
+// Broker fans out events to subscribers of a project and keeps a history for resuming streams.
+type Broker struct {
+	mu          sync.Mutex
+	epoch       string
+	nextID      uint64
+	size        int
+	history     []*Event
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	project string
+	events  chan *Event
+	once    sync.Once
+}
+
+func NewBroker(history int) *Broker {
+	return &Broker{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:        history,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Publish(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event.sequence = b.nextID
+	event.ID = b.epoch + "-" + strconv.FormatUint(b.nextID, 10)
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+
+	for s := range b.subscribers {
+		if s.project != event.Project {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			// The subscriber is too slow, disconnect it so it resumes from its last event.
+			b.unsubscribe(s)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events of the project, replaying the events in the history published
+// after lastEventID. An empty lastEventID, or one published by another broker, replays nothing.
+func (b *Broker) Subscribe(projectID, lastEventID string) (*Subscription, []*Event, error) {
+	var after uint64
+	if lastEventID != "" {
+		epoch, sequence, found := strings.Cut(lastEventID, "-")
+		parsed, err := strconv.ParseUint(sequence, 10, 64)
+		if !found || epoch == "" || err != nil {
+			return nil, nil, fmt.Errorf("event id %q is not <epoch>-<sequence>", lastEventID)
+		}
+		if epoch == b.epoch {
+			after = parsed
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []*Event
+	if after > 0 {
+		for _, event := range b.history {
+			if event.sequence > after && event.Project == projectID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	s := &Subscription{
+		project: projectID,
+		events:  make(chan *Event, subscriberBuffer),
+	}
+	b.subscribers[s] = struct{}{}
+	return s, missed, nil
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unsubscribe(s)
+}
+
+// unsubscribe closes the subscription, the lock must be held.
+func (b *Broker) unsubscribe(s *Subscription) {
+	delete(b.subscribers, s)
+	s.once.Do(func() {
+		close(s.events)
+	})
+}
+
+// Events is closed when the subscription is ended by the broker.
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
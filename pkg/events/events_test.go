@@ -0,0 +1,67 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Broker(t *testing.T) {
+	broker := NewBroker(10)
+
+	subscription, missed, err := broker.Subscribe("project-a", "")
+	assert.NoError(t, err)
+	defer broker.Unsubscribe(subscription)
+	assert.Empty(t, missed)
+
+	broker.Publish(&Event{Type: PolicyCreated, Project: "project-b", Policy: "other"})
+	broker.Publish(&Event{Type: RuleAdded, Project: "project-a", Policy: "policy"})
+
+	event := <-subscription.Events()
+	assert.Equal(t, RuleAdded, event.Type, "events of other projects are filtered")
+	assert.Equal(t, broker.epoch+"-2", event.ID)
+	assert.False(t, event.Time.IsZero())
+}
+
+func Test_BrokerResume(t *testing.T) {
+	broker := NewBroker(2)
+	for _, eventType := range []string{PolicyCreated, RuleAdded, RulePatched, RuleRemoved} {
+		broker.Publish(&Event{Type: eventType, Project: "project-a"})
+	}
+
+	subscription, missed, err := broker.Subscribe("project-a", broker.epoch+"-2")
+	assert.NoError(t, err)
+	defer broker.Unsubscribe(subscription)
+
+	assert.Len(t, missed, 2, "events after Last-Event-ID within the history are replayed")
+	assert.Equal(t, RulePatched, missed[0].Type)
+	assert.Equal(t, RuleRemoved, missed[1].Type)
+
+	restarted := NewBroker(2)
+	restarted.epoch = "restarted"
+	restarted.Publish(&Event{Type: RuleAdded, Project: "project-a"})
+	other, missed, err := restarted.Subscribe("project-a", broker.epoch+"-0")
+	assert.NoError(t, err)
+	defer restarted.Unsubscribe(other)
+	assert.Empty(t, missed, "event ids of another epoch are ignored")
+
+	_, _, err = broker.Subscribe("project-a", "2")
+	assert.Error(t, err, "event ids without an epoch are rejected")
+}
+
+func Test_BrokerDisconnectsSlowSubscriber(t *testing.T) {
+	broker := NewBroker(subscriberBuffer * 2)
+	subscription, _, err := broker.Subscribe("project-a", "")
+	assert.NoError(t, err)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(&Event{Type: OperationStarted, Project: "project-a"})
+	}
+
+	received := 0
+	for range subscription.Events() {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received, "the stream is closed once the buffer overflows")
+	broker.Unsubscribe(subscription)
+}
@@ -0,0 +1,512 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/memory"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Methods of the fake, as named in the Compute API, to inject faults into and count requests of.
+const (
+	MethodListPolicies      = "securityPolicies.list"
+	MethodInsertPolicy      = "securityPolicies.insert"
+	MethodGetPolicy         = "securityPolicies.get"
+	MethodPatchPolicy       = "securityPolicies.patch"
+	MethodDeletePolicy      = "securityPolicies.delete"
+	MethodGetRule           = "securityPolicies.getRule"
+	MethodAddRule           = "securityPolicies.addRule"
+	MethodPatchRule         = "securityPolicies.patchRule"
+	MethodRemoveRule        = "securityPolicies.removeRule"
+	MethodListPreconfigured = "securityPolicies.listPreconfiguredExpressionSets"
+	MethodListBackends      = "backendServices.list"
+	MethodGetBackend        = "backendServices.get"
+	MethodSetSecurityPolicy = "backendServices.setSecurityPolicy"
+	MethodGetOperation      = "globalOperations.get"
+)
+
+const (
+	reasonNotFound     = "notFound"
+	reasonInvalid      = "invalid"
+	reasonBackendError = "backendError"
+)
+
+// Compute is a stateful fake of the securityPolicies, backendServices and globalOperations REST APIs of Compute
+// Engine, serving the in-memory store of package memory. Changes are applied immediately, the operation of a change
+// is running until the operation latency has passed.
+type Compute struct {
+	store  *memory.Compute
+	router *mux.Router
+
+	mu       sync.Mutex
+	faults   []*fault
+	latency  time.Duration
+	requests map[string]int
+}
+
+// Fault fails calls to a method of the fake with an error in the format of Google APIs.
+type Fault struct {
+	// Method is the failing method, e.g. MethodGetPolicy, every method fails when empty.
+	Method string
+	Code   int
+	Reason string
+	// Times is the number of calls failing, every call fails when 0.
+	Times int
+}
+
+type fault struct {
+	Fault
+	failed int
+}
+
+// apiError is a Compute API error, answered in the error format of Google APIs.
+type apiError struct {
+	code    int
+	reason  string
+	message string
+}
+
+func NewCompute() *Compute {
+	c := &Compute{
+		store:    memory.New(),
+		requests: make(map[string]int),
+	}
+
+	r := mux.NewRouter()
+	r.Use(c.middleware)
+	policies := r.PathPrefix("/compute/v1/projects/{project}/global/securityPolicies").Subrouter()
+	policies.HandleFunc("", c.listPolicies).Methods(http.MethodGet).Name(MethodListPolicies)
+	policies.HandleFunc("", c.insertPolicy).Methods(http.MethodPost).Name(MethodInsertPolicy)
+	policies.HandleFunc("/listPreconfiguredExpressionSets", c.listPreconfigured).Methods(http.MethodGet).Name(MethodListPreconfigured)
+	policies.HandleFunc("/{policy}", c.getPolicy).Methods(http.MethodGet).Name(MethodGetPolicy)
+	policies.HandleFunc("/{policy}", c.patchPolicy).Methods(http.MethodPatch).Name(MethodPatchPolicy)
+	policies.HandleFunc("/{policy}", c.deletePolicy).Methods(http.MethodDelete).Name(MethodDeletePolicy)
+	policies.HandleFunc("/{policy}/getRule", c.getRule).Methods(http.MethodGet).Name(MethodGetRule)
+	policies.HandleFunc("/{policy}/addRule", c.addRule).Methods(http.MethodPost).Name(MethodAddRule)
+	policies.HandleFunc("/{policy}/patchRule", c.patchRule).Methods(http.MethodPost).Name(MethodPatchRule)
+	policies.HandleFunc("/{policy}/removeRule", c.removeRule).Methods(http.MethodPost).Name(MethodRemoveRule)
+
+	backends := r.PathPrefix("/compute/v1/projects/{project}/global/backendServices").Subrouter()
+	backends.HandleFunc("", c.listBackends).Methods(http.MethodGet).Name(MethodListBackends)
+	backends.HandleFunc("/{backend}", c.getBackend).Methods(http.MethodGet).Name(MethodGetBackend)
+	backends.HandleFunc("/{backend}/setSecurityPolicy", c.setSecurityPolicy).Methods(http.MethodPost).Name(MethodSetSecurityPolicy)
+
+	r.HandleFunc("/compute/v1/projects/{project}/global/operations/{operation}", c.getOperation).Methods(http.MethodGet).Name(MethodGetOperation)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &apiError{http.StatusNotFound, reasonNotFound, fmt.Sprintf("%s %s is not implemented by the fake", r.Method, r.URL.Path)})
+	})
+	r.MethodNotAllowedHandler = r.NotFoundHandler
+	c.router = r
+	return c
+}
+
+func (c *Compute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.router.ServeHTTP(w, r)
+}
+
+// middleware counts calls, and delays and fails them as injected.
+func (c *Compute) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := mux.CurrentRoute(r).GetName()
+
+		c.mu.Lock()
+		c.requests[method]++
+		latency := c.latency
+		injected := c.fault(method)
+		c.mu.Unlock()
+
+		if latency > 0 {
+			timer := time.NewTimer(latency)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		if injected != nil {
+			writeError(w, injected)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fault returns the error of the first injected fault matching the method, if any.
+func (c *Compute) fault(method string) *apiError {
+	for _, f := range c.faults {
+		if (f.Method != "" && f.Method != method) || (f.Times > 0 && f.failed >= f.Times) {
+			continue
+		}
+		f.failed++
+		reason := f.Reason
+		if reason == "" {
+			reason = "injectedFault"
+		}
+		return &apiError{f.Code, reason, fmt.Sprintf("injected fault in %s", method)}
+	}
+	return nil
+}
+
+// Inject fails calls as given by the fault, in addition to faults injected before.
+func (c *Compute) Inject(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all injected faults.
+func (c *Compute) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// SetLatency delays every call by d.
+func (c *Compute) SetLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = d
+}
+
+// SetOperationLatency keeps the operations of later changes running for d.
+func (c *Compute) SetOperationLatency(d time.Duration) {
+	c.store.SetOperationLatency(d)
+}
+
+// Requests returns the number of calls of a method, or of every method when empty, including failed calls.
+func (c *Compute) Requests(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if method != "" {
+		return c.requests[method]
+	}
+	total := 0
+	for _, n := range c.requests {
+		total += n
+	}
+	return total
+}
+
+// Listen serves the fake on a local port until the returned server is closed, for clients not taking options
+// from ClientOptions.
+func (c *Compute) Listen() (*httptest.Server, []option.ClientOption) {
+	server := httptest.NewServer(c)
+	return server, []option.ClientOption{option.WithEndpoint(server.URL), option.WithoutAuthentication()}
+}
+
+// ClientOptions points Compute clients to the fake, served in-process without a listener.
+func (c *Compute) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint("http://compute.fake"),
+		option.WithHTTPClient(&http.Client{Transport: &handlerTransport{handler: c}}),
+	}
+}
+
+// handlerTransport round trips requests through a handler in-process.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t *handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, r)
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+	response := w.Result()
+	response.Request = r
+	return response, nil
+}
+
+// AddPolicy stores a policy in the project as is, adding the default rule and output only fields when missing.
+func (c *Compute) AddPolicy(projectID string, policy *compute.SecurityPolicy) {
+	c.store.AddPolicy(projectID, policy)
+}
+
+// AddBackend stores a backend service in the project as is.
+func (c *Compute) AddBackend(projectID string, backend *compute.BackendService) {
+	c.store.AddBackend(projectID, backend)
+}
+
+// SetPreconfiguredExpressionSets sets the answer of listPreconfiguredExpressionSets for every project.
+func (c *Compute) SetPreconfiguredExpressionSets(sets *compute.SecurityPoliciesListPreconfiguredExpressionSetsResponse) {
+	c.store.SetPreconfiguredExpressionSets(sets.GetPreconfiguredExpressionSets().GetWafRules().GetExpressionSets())
+}
+
+// Policy returns a copy of a stored policy, or nil if it does not exist.
+func (c *Compute) Policy(projectID, name string) *compute.SecurityPolicy {
+	policy, err := c.store.GetPolicy(context.Background(), projectID, name)
+	if err != nil {
+		return nil
+	}
+	return policy
+}
+
+// Backend returns a copy of a stored backend service, or nil if it does not exist.
+func (c *Compute) Backend(projectID, name string) *compute.BackendService {
+	backend, err := c.store.GetBackendService(context.Background(), projectID, name)
+	if err != nil {
+		return nil
+	}
+	return backend
+}
+
+func (c *Compute) listPolicies(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	policies, next, storeErr := c.store.ListPoliciesPage(r.Context(), mux.Vars(r)["project"], opts)
+	if storeErr != nil {
+		writeError(w, apiErrorOf(storeErr))
+		return
+	}
+	write(w, &compute.SecurityPolicyList{Kind: proto.String("compute#securityPolicyList"), Items: policies, NextPageToken: nextPageToken(next)})
+}
+
+func (c *Compute) insertPolicy(w http.ResponseWriter, r *http.Request) {
+	policy := &compute.SecurityPolicy{}
+	if err := readBody(r, policy); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeOperation(w)(c.store.CreatePolicy(r.Context(), policy, mux.Vars(r)["project"]))
+}
+
+func (c *Compute) getPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := c.store.GetPolicy(r.Context(), mux.Vars(r)["project"], mux.Vars(r)["policy"])
+	if err != nil {
+		writeError(w, apiErrorOf(err))
+		return
+	}
+	write(w, policy)
+}
+
+// patchPolicy updates the fields given in the body, except the rules which are changed with the rule methods.
+func (c *Compute) patchPolicy(w http.ResponseWriter, r *http.Request) {
+	patch := &compute.SecurityPolicy{}
+	if err := readBody(r, patch); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeOperation(w)(c.store.UpdatePolicy(r.Context(), patch, mux.Vars(r)["project"], mux.Vars(r)["policy"]))
+}
+
+func (c *Compute) deletePolicy(w http.ResponseWriter, r *http.Request) {
+	writeOperation(w)(c.store.DeletePolicy(r.Context(), mux.Vars(r)["project"], mux.Vars(r)["policy"]))
+}
+
+func (c *Compute) getRule(w http.ResponseWriter, r *http.Request) {
+	priority, err := priorityParam(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, storeErr := c.store.GetRule(r.Context(), proto.Int32(priority), mux.Vars(r)["project"], mux.Vars(r)["policy"])
+	if storeErr != nil {
+		writeError(w, apiErrorOf(storeErr))
+		return
+	}
+	write(w, rule)
+}
+
+func (c *Compute) addRule(w http.ResponseWriter, r *http.Request) {
+	rule := &compute.SecurityPolicyRule{}
+	if err := readBody(r, rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeOperation(w)(c.store.AddRule(r.Context(), rule, mux.Vars(r)["project"], mux.Vars(r)["policy"]))
+}
+
+// patchRule replaces the rule with the priority given as parameter, or in the body when the parameter is missing.
+func (c *Compute) patchRule(w http.ResponseWriter, r *http.Request) {
+	rule := &compute.SecurityPolicyRule{}
+	if err := readBody(r, rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	priority, err := priorityParam(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !r.URL.Query().Has("priority") && rule.Priority != nil {
+		// armor patches the rule given by the priority in the body.
+		priority = rule.GetPriority()
+	}
+	writeOperation(w)(c.store.PatchRule(r.Context(), priority, rule, mux.Vars(r)["project"], mux.Vars(r)["policy"]))
+}
+
+func (c *Compute) removeRule(w http.ResponseWriter, r *http.Request) {
+	priority, err := priorityParam(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeOperation(w)(c.store.RemoveRule(r.Context(), proto.Int32(priority), mux.Vars(r)["project"], mux.Vars(r)["policy"]))
+}
+
+func (c *Compute) listPreconfigured(w http.ResponseWriter, r *http.Request) {
+	sets, err := c.store.ListPreConfiguredRules(r.Context(), mux.Vars(r)["project"])
+	if err != nil {
+		writeError(w, apiErrorOf(err))
+		return
+	}
+	write(w, &compute.SecurityPoliciesListPreconfiguredExpressionSetsResponse{
+		PreconfiguredExpressionSets: &compute.SecurityPoliciesWafConfig{
+			WafRules: &compute.PreconfiguredWafSet{ExpressionSets: sets},
+		},
+	})
+}
+
+func (c *Compute) listBackends(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	backends, next, storeErr := c.store.ListBackendServicesPage(r.Context(), mux.Vars(r)["project"], opts)
+	if storeErr != nil {
+		writeError(w, apiErrorOf(storeErr))
+		return
+	}
+	write(w, &compute.BackendServiceList{Kind: proto.String("compute#backendServiceList"), Items: backends, NextPageToken: nextPageToken(next)})
+}
+
+func (c *Compute) getBackend(w http.ResponseWriter, r *http.Request) {
+	backend, err := c.store.GetBackendService(r.Context(), mux.Vars(r)["project"], mux.Vars(r)["backend"])
+	if err != nil {
+		writeError(w, apiErrorOf(err))
+		return
+	}
+	write(w, backend)
+}
+
+// setSecurityPolicy attaches the referenced policy to the backend service, an empty reference detaches it.
+func (c *Compute) setSecurityPolicy(w http.ResponseWriter, r *http.Request) {
+	reference := &compute.SecurityPolicyReference{}
+	if err := readBody(r, reference); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeOperation(w)(c.store.SetSecurityPolicy(r.Context(), mux.Vars(r)["project"], reference.SecurityPolicy, mux.Vars(r)["backend"]))
+}
+
+func (c *Compute) getOperation(w http.ResponseWriter, r *http.Request) {
+	op, err := c.store.GetOperation(r.Context(), mux.Vars(r)["project"], mux.Vars(r)["operation"])
+	if err != nil {
+		writeError(w, apiErrorOf(err))
+		return
+	}
+	write(w, op)
+}
+
+// writeOperation returns a function writing the operation of a change, or its error.
+func writeOperation(w http.ResponseWriter) func(*compute.Operation, error) {
+	return func(op *compute.Operation, err error) {
+		if err != nil {
+			writeError(w, apiErrorOf(err))
+			return
+		}
+		write(w, op)
+	}
+}
+
+// apiErrorOf answers an error of the store as the Compute API does.
+func apiErrorOf(err error) *apiError {
+	var e *memory.Error
+	if errors.As(err, &e) {
+		return &apiError{e.Code, e.Reason, e.Message}
+	}
+	return &apiError{http.StatusServiceUnavailable, reasonBackendError, err.Error()}
+}
+
+func priorityParam(r *http.Request) (int32, *apiError) {
+	value := r.URL.Query().Get("priority")
+	if value == "" {
+		return 0, nil
+	}
+	priority, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, &apiError{http.StatusBadRequest, reasonInvalid, fmt.Sprintf("Invalid value for field 'priority': '%s'", value)}
+	}
+	return int32(priority), nil
+}
+
+// listOptions selects the page of a list given by filter, maxResults and pageToken, see package filter for the
+// expressions supported.
+func listOptions(r *http.Request) (cloudarmor.ListOptions, *apiError) {
+	query := r.URL.Query()
+	opts := cloudarmor.ListOptions{Filter: query.Get("filter"), PageToken: query.Get("pageToken")}
+	if value := query.Get("maxResults"); value != "" {
+		maxResults, err := strconv.Atoi(value)
+		if err != nil || maxResults < 0 {
+			return opts, &apiError{http.StatusBadRequest, reasonInvalid, fmt.Sprintf("Invalid value for field 'maxResults': '%s'", value)}
+		}
+		opts.PageSize = maxResults
+	}
+	return opts, nil
+}
+
+func nextPageToken(next string) *string {
+	if next == "" {
+		return nil
+	}
+	return proto.String(next)
+}
+
+func readBody(r *http.Request, message proto.Message) *apiError {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return &apiError{http.StatusBadRequest, reasonInvalid, fmt.Sprintf("read request body: %v", err)}
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, message); err != nil {
+		return &apiError{http.StatusBadRequest, reasonInvalid, fmt.Sprintf("Invalid JSON payload received. %v", err)}
+	}
+	return nil
+}
+
+func write(w http.ResponseWriter, message proto.Message) {
+	body, err := protojson.Marshal(message)
+	if err != nil {
+		writeError(w, &apiError{http.StatusInternalServerError, reasonBackendError, err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, e *apiError) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    e.code,
+			"message": e.message,
+			"errors": []map[string]string{
+				{"domain": "global", "reason": e.reason, "message": e.message},
+			},
+		},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.code)
+	_, _ = w.Write(body)
+}
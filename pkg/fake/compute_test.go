@@ -0,0 +1,210 @@
+package fake
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/google"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const devProject = "dev-project"
+
+func clients(t *testing.T) (*Compute, *google.SecurityClient, *google.ServiceClient) {
+	c := NewCompute()
+	assert.NoError(t, c.LoadFixtures(DevelopmentFixtures))
+
+	cfg := &config.Config{DevelopmentMode: true}
+	entry := log.WithField("component", "test")
+	securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, c.ClientOptions()...)
+	assert.NoError(t, err)
+	serviceClient, err := google.NewServiceClient(cfg, context.Background(), entry, c.ClientOptions()...)
+	assert.NoError(t, err)
+	return c, securityClient, serviceClient
+}
+
+func Test_developmentFixtures(t *testing.T) {
+	_, securityClient, serviceClient := clients(t)
+	ctx := context.Background()
+
+	var names []string
+	policies, err := securityClient.ListPolicies(ctx, devProject)
+	assert.NoError(t, err)
+	for _, policy := range policies {
+		names = append(names, policy.GetName())
+	}
+	assert.Equal(t, []string{"dev-policy", "dev-policy-unused"}, names)
+
+	policy, err := securityClient.GetPolicy(ctx, devProject, "dev-policy-unused")
+	assert.NoError(t, err)
+	assert.Len(t, policy.Rules, 1, "every policy has a default rule")
+
+	backend, err := serviceClient.GetBackendService(ctx, devProject, "dev-backend")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.googleapis.com/compute/v1/projects/dev-project/global/securityPolicies/dev-policy", backend.GetSecurityPolicy())
+
+	sets, err := securityClient.ListPreConfiguredRules(ctx, devProject)
+	assert.NoError(t, err)
+	assert.Len(t, sets, 2)
+}
+
+func Test_stateful(t *testing.T) {
+	c, securityClient, serviceClient := clients(t)
+	ctx := context.Background()
+
+	_, err := securityClient.CreatePolicy(ctx, &compute.SecurityPolicy{Name: proto.String("new-policy")}, devProject)
+	assert.NoError(t, err)
+	_, err = securityClient.CreatePolicy(ctx, &compute.SecurityPolicy{Name: proto.String("new-policy")}, devProject)
+	assertStatus(t, http.StatusConflict, err)
+
+	rule := &compute.SecurityPolicyRule{
+		Action:   proto.String("deny(403)"),
+		Priority: proto.Int32(10),
+		Match: &compute.SecurityPolicyRuleMatcher{
+			VersionedExpr: proto.String("SRC_IPS_V1"),
+			Config:        &compute.SecurityPolicyRuleMatcherConfig{SrcIpRanges: []string{"10.0.0.0/8"}},
+		},
+	}
+	_, err = securityClient.AddRule(ctx, rule, devProject, "new-policy")
+	assert.NoError(t, err)
+	_, err = securityClient.AddRule(ctx, rule, devProject, "new-policy")
+	assertStatus(t, http.StatusBadRequest, err)
+
+	rule.Description = proto.String("patched")
+	_, err = securityClient.UpdateRule(ctx, rule, devProject, "new-policy")
+	assert.NoError(t, err)
+	got, err := securityClient.GetRule(ctx, proto.Int32(10), devProject, "new-policy")
+	assert.NoError(t, err)
+	assert.Equal(t, "patched", got.GetDescription())
+
+	before := c.Policy(devProject, "new-policy")
+	_, err = securityClient.UpdatePolicy(ctx, &compute.SecurityPolicy{Description: proto.String("changed"), Fingerprint: before.Fingerprint}, devProject, "new-policy")
+	assert.NoError(t, err)
+	after := c.Policy(devProject, "new-policy")
+	assert.Equal(t, "changed", after.GetDescription())
+	assert.Len(t, after.Rules, 2, "patching a policy keeps its rules")
+	_, err = securityClient.UpdatePolicy(ctx, &compute.SecurityPolicy{Description: proto.String("stale"), Fingerprint: before.Fingerprint}, devProject, "new-policy")
+	assertStatus(t, http.StatusPreconditionFailed, err)
+
+	_, err = serviceClient.SetSecurityPolicy(ctx, devProject, after.SelfLink, "dev-backend-unprotected")
+	assert.NoError(t, err)
+	assert.Equal(t, after.GetSelfLink(), c.Backend(devProject, "dev-backend-unprotected").GetSecurityPolicy())
+	_, err = securityClient.DeletePolicy(ctx, devProject, "new-policy")
+	assertStatus(t, http.StatusBadRequest, err)
+
+	_, err = serviceClient.SetSecurityPolicy(ctx, devProject, nil, "dev-backend-unprotected")
+	assert.NoError(t, err)
+	_, err = securityClient.RemoveRule(ctx, proto.Int32(10), devProject, "new-policy")
+	assert.NoError(t, err)
+	_, err = securityClient.DeletePolicy(ctx, devProject, "new-policy")
+	assert.NoError(t, err)
+	_, err = securityClient.GetPolicy(ctx, devProject, "new-policy")
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func Test_lists(t *testing.T) {
+	_, securityClient, serviceClient := clients(t)
+	ctx := context.Background()
+
+	policies, next, err := securityClient.ListPoliciesPage(ctx, devProject, cloudarmor.ListOptions{PageSize: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "dev-policy", policies[0].GetName())
+	}
+	policies, next, err = securityClient.ListPoliciesPage(ctx, devProject, cloudarmor.ListOptions{PageSize: 1, PageToken: next})
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "dev-policy-unused", policies[0].GetName())
+	}
+	assert.Empty(t, next)
+
+	backends, _, err := serviceClient.ListBackendServicesPage(ctx, devProject, cloudarmor.ListOptions{Filter: `securityPolicy:*`})
+	assert.NoError(t, err)
+	if assert.Len(t, backends, 1) {
+		assert.Equal(t, "dev-backend", backends[0].GetName())
+	}
+
+	_, _, err = serviceClient.ListBackendServicesPage(ctx, devProject, cloudarmor.ListOptions{Filter: `name = "dev-backend`})
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+func Test_faults(t *testing.T) {
+	c, securityClient, _ := clients(t)
+	ctx := context.Background()
+
+	c.Inject(Fault{Method: MethodGetPolicy, Code: http.StatusServiceUnavailable, Reason: "backendError", Times: 1})
+	_, err := securityClient.GetPolicy(ctx, devProject, "dev-policy")
+	assertStatus(t, http.StatusServiceUnavailable, err)
+	_, err = securityClient.GetRule(ctx, proto.Int32(1000), devProject, "dev-policy")
+	assert.NoError(t, err, "other methods do not fail")
+	_, err = securityClient.GetPolicy(ctx, devProject, "dev-policy")
+	assert.NoError(t, err, "the fault is injected once")
+	assert.Equal(t, 2, c.Requests(MethodGetPolicy))
+
+	c.Inject(Fault{Code: http.StatusForbidden})
+	_, err = securityClient.GetRule(ctx, proto.Int32(1000), devProject, "dev-policy")
+	assertStatus(t, http.StatusForbidden, err)
+	c.ClearFaults()
+	_, err = securityClient.GetRule(ctx, proto.Int32(1000), devProject, "dev-policy")
+	assert.NoError(t, err)
+
+	c.SetLatency(time.Hour)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = securityClient.GetPolicy(timeout, devProject, "dev-policy")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_operationLatency(t *testing.T) {
+	c := NewCompute()
+	assert.NoError(t, c.LoadFixtures(DevelopmentFixtures))
+	server, _ := c.Listen()
+	defer server.Close()
+	c.SetOperationLatency(50 * time.Millisecond)
+
+	response, err := http.Post(server.URL+"/compute/v1/projects/dev-project/global/securityPolicies", "application/json", strings.NewReader(`{"name":"new-policy"}`))
+	assert.NoError(t, err)
+	op := &compute.Operation{}
+	assert.NoError(t, read(response, op))
+	assert.Equal(t, compute.Operation_RUNNING, op.GetStatus())
+
+	get := func() *compute.Operation {
+		response, err := http.Get(server.URL + "/compute/v1/projects/dev-project/global/operations/" + op.GetName())
+		assert.NoError(t, err)
+		polled := &compute.Operation{}
+		assert.NoError(t, read(response, polled))
+		return polled
+	}
+	assert.Equal(t, compute.Operation_RUNNING, get().GetStatus())
+	assert.Eventually(t, func() bool {
+		return get().GetStatus() == compute.Operation_DONE
+	}, time.Second, 10*time.Millisecond)
+}
+
+func read(response *http.Response, message proto.Message) error {
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(body, message)
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	var apiErr *googleapi.Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, status, apiErr.Code)
+	}
+}
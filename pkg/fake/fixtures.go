@@ -0,0 +1,68 @@
+package fake
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// DevelopmentFixtures seeds the fake used in development mode when no fixtures are configured.
+//
+//go:embed fixtures/development.yaml
+var DevelopmentFixtures []byte
+
+// fixtures are Compute resources in their REST representation, keyed by project.
+type fixtures struct {
+	Projects map[string]struct {
+		SecurityPolicies []json.RawMessage `json:"securityPolicies"`
+		BackendServices  []json.RawMessage `json:"backendServices"`
+	} `json:"projects"`
+	PreconfiguredExpressionSets json.RawMessage `json:"preconfiguredExpressionSets"`
+}
+
+// LoadFixtures adds the projects, policies and backend services of YAML fixtures to the fake.
+func (c *Compute) LoadFixtures(data []byte) error {
+	// YAML is converted to JSON, so resources are given in the same representation as the Compute REST API.
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("parse fixtures: %w", err)
+	}
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("convert fixtures: %w", err)
+	}
+	var f fixtures
+	if err := json.Unmarshal(converted, &f); err != nil {
+		return fmt.Errorf("parse fixtures: %w", err)
+	}
+
+	for projectID, p := range f.Projects {
+		for i, raw := range p.SecurityPolicies {
+			policy := &compute.SecurityPolicy{}
+			if err := protojson.Unmarshal(raw, policy); err != nil {
+				return fmt.Errorf("parse security policy %d of project %s: %w", i, projectID, err)
+			}
+			c.AddPolicy(projectID, policy)
+		}
+		for i, raw := range p.BackendServices {
+			backend := &compute.BackendService{}
+			if err := protojson.Unmarshal(raw, backend); err != nil {
+				return fmt.Errorf("parse backend service %d of project %s: %w", i, projectID, err)
+			}
+			c.AddBackend(projectID, backend)
+		}
+	}
+
+	if len(f.PreconfiguredExpressionSets) > 0 {
+		sets := &compute.SecurityPoliciesListPreconfiguredExpressionSetsResponse{}
+		if err := protojson.Unmarshal(f.PreconfiguredExpressionSets, sets); err != nil {
+			return fmt.Errorf("parse preconfigured expression sets: %w", err)
+		}
+		c.SetPreconfiguredExpressionSets(sets)
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// Package filter evaluates the filter expressions of Compute API list requests, for the fakes of the Compute API in
+// packages fake and memory.
+//
+// Comparisons are written as field, operator and value, e.g. name = "my-policy", with fields named like in the REST
+// API and nested fields separated by dots. The operators are =, !=, <, <=, >, >=, : matching a substring or with *
+// any set value, and eq and ne matching the whole value with an RE2 regular expression. Comparisons in parentheses
+// are joined with AND, OR or implicitly AND, where OR binds tighter than AND. A * in the value of = and != matches
+// any text.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Filter matches messages, the zero value matches every message.
+type Filter struct {
+	root node
+}
+
+type node interface {
+	match(message protoreflect.Message) bool
+}
+
+type and []node
+
+func (n and) match(message protoreflect.Message) bool {
+	for _, child := range n {
+		if !child.match(message) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []node
+
+func (n or) match(message protoreflect.Message) bool {
+	for _, child := range n {
+		if child.match(message) {
+			return true
+		}
+	}
+	return false
+}
+
+type comparison struct {
+	path     []string
+	operator string
+	value    string
+	pattern  *regexp.Regexp
+}
+
+// Parse parses a filter expression, an empty expression matches every message.
+func Parse(expression string) (*Filter, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &Filter{}, nil
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return &Filter{root: root}, nil
+}
+
+// Match reports whether the message matches the filter.
+func (f *Filter) Match(message proto.Message) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+	return f.root.match(message.ProtoReflect())
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == ':' || c == '=':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			if i+1 < len(expression) && expression[i+1] == '=' {
+				tokens = append(tokens, token{text: expression[i : i+2]})
+				i += 2
+			} else if c == '!' {
+				return nil, fmt.Errorf("unexpected ! at %d", i)
+			} else {
+				tokens = append(tokens, token{text: string(c)})
+				i++
+			}
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expression[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{text: expression[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(expression) && !strings.ContainsRune(" \t\n()=!<>:\"'", rune(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, token{text: expression[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) done() bool {
+	return p.next >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.next]
+}
+
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); !t.quoted && t.text == word {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// expression is sequences joined with AND.
+func (p *parser) expression() (node, error) {
+	var nodes and
+	for {
+		n, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if !p.keyword("AND") {
+			return nodes, nil
+		}
+	}
+}
+
+// sequence is factors implicitly joined with AND, up to an AND, a closing parenthesis or the end.
+func (p *parser) sequence() (node, error) {
+	var nodes and
+	for {
+		n, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if t := p.peek(); p.done() || (!t.quoted && (t.text == "AND" || t.text == ")")) {
+			return nodes, nil
+		}
+	}
+}
+
+// factor is terms joined with OR.
+func (p *parser) factor() (node, error) {
+	var nodes or
+	for {
+		n, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if !p.keyword("OR") {
+			return nodes, nil
+		}
+	}
+}
+
+func (p *parser) term() (node, error) {
+	if p.keyword("(") {
+		n, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return n, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	field := p.peek()
+	if p.done() || field.quoted || strings.ContainsAny(field.text, "()") || field.text == "" {
+		return nil, fmt.Errorf("expected a field, got %q", field.text)
+	}
+	p.next++
+
+	operator := p.peek()
+	switch {
+	case operator.quoted, p.done():
+		return nil, fmt.Errorf("expected an operator after %s", field.text)
+	case operator.text == "=", operator.text == "!=", operator.text == "<", operator.text == "<=",
+		operator.text == ">", operator.text == ">=", operator.text == ":", operator.text == "eq", operator.text == "ne":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", operator.text)
+	}
+	p.next++
+
+	value := p.peek()
+	if p.done() || (!value.quoted && (value.text == "(" || value.text == ")")) {
+		return nil, fmt.Errorf("expected a value after %s %s", field.text, operator.text)
+	}
+	p.next++
+
+	c := &comparison{path: strings.Split(field.text, "."), operator: operator.text, value: value.text}
+	var err error
+	switch c.operator {
+	case "eq", "ne":
+		c.pattern, err = regexp.Compile("^(?:" + c.value + ")$")
+	case "=", "!=":
+		if strings.Contains(c.value, "*") {
+			c.pattern, err = regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(c.value), `\*`, ".*") + "$")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", c.value, err)
+	}
+	return c, nil
+}
+
+func (c *comparison) match(message protoreflect.Message) bool {
+	values := fieldValues(message, c.path)
+	switch c.operator {
+	case "!=", "ne":
+		for _, value := range values {
+			if c.equal(value) {
+				return false
+			}
+		}
+		return true
+	case ":":
+		if c.value == "*" {
+			return len(values) > 0
+		}
+	}
+
+	for _, value := range values {
+		if c.compare(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *comparison) equal(value string) bool {
+	if c.pattern != nil {
+		return c.pattern.MatchString(value)
+	}
+	return value == c.value
+}
+
+func (c *comparison) compare(value string) bool {
+	switch c.operator {
+	case "=", "eq":
+		return c.equal(value)
+	case ":":
+		return strings.Contains(value, c.value)
+	}
+
+	order := strings.Compare(value, c.value)
+	if a, err := strconv.ParseFloat(value, 64); err == nil {
+		if b, err := strconv.ParseFloat(c.value, 64); err == nil {
+			order = 0
+			if a < b {
+				order = -1
+			} else if a > b {
+				order = 1
+			}
+		}
+	}
+	switch c.operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// fieldValues returns the set values of the field at the path as text, every element of repeated fields.
+func fieldValues(message protoreflect.Message, path []string) []string {
+	fields := message.Descriptor().Fields()
+	field := fields.ByJSONName(path[0])
+	if field == nil {
+		field = fields.ByName(protoreflect.Name(path[0]))
+	}
+	if field == nil || field.IsMap() || !message.Has(field) {
+		return nil
+	}
+
+	var elements []protoreflect.Value
+	if field.IsList() {
+		list := message.Get(field).List()
+		for i := 0; i < list.Len(); i++ {
+			elements = append(elements, list.Get(i))
+		}
+	} else {
+		elements = append(elements, message.Get(field))
+	}
+
+	var values []string
+	for _, element := range elements {
+		switch {
+		case field.Message() != nil:
+			if len(path) > 1 {
+				values = append(values, fieldValues(element.Message(), path[1:])...)
+			}
+		case len(path) > 1:
+		case field.Enum() != nil:
+			if value := field.Enum().Values().ByNumber(element.Enum()); value != nil {
+				values = append(values, string(value.Name()))
+			}
+		default:
+			values = append(values, element.String())
+		}
+	}
+	return values
+}
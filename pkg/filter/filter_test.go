@@ -0,0 +1,74 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_Match(t *testing.T) {
+	policy := &compute.SecurityPolicy{
+		Name:        proto.String("team-policy"),
+		Description: proto.String("Blocks scanners"),
+		Fingerprint: proto.String("AAAAAAAAAAI="),
+		Id:          proto.Uint64(42),
+		Type:        proto.String("CLOUD_ARMOR"),
+		Rules: []*compute.SecurityPolicyRule{
+			{Priority: proto.Int32(10), Action: proto.String("allow"), Preview: proto.Bool(false)},
+			{Priority: proto.Int32(2147483647), Action: proto.String("deny(403)"), Preview: proto.Bool(true)},
+		},
+	}
+
+	for _, test := range []struct {
+		filter string
+		match  bool
+		reason string
+	}{
+		{filter: "", match: true},
+		{filter: `name = "team-policy"`, match: true},
+		{filter: `name = team-policy`, match: true},
+		{filter: `name = "other"`, match: false},
+		{filter: `name != "other"`, match: true},
+		{filter: `name = "team-*"`, match: true},
+		{filter: `name eq team-.*`, match: true},
+		{filter: `name eq team`, match: false, reason: "eq matches the whole value"},
+		{filter: `name ne "other-.*"`, match: true},
+		{filter: `description:scanners`, match: true},
+		{filter: `labelFingerprint:*`, match: false},
+		{filter: `id > 41`, match: true},
+		{filter: `id < 5`, match: false, reason: "numbers are compared as numbers"},
+		{filter: `rules.action = "deny(403)"`, match: true},
+		{filter: `rules.preview = true`, match: true},
+		{filter: `rules.priority >= 1000 AND rules.priority < 100`, match: true},
+		{filter: `(name = "team-policy") (type = "CLOUD_ARMOR_EDGE")`, match: false},
+		{filter: `(name = "team-policy") AND (type = "CLOUD_ARMOR_EDGE" OR type = "CLOUD_ARMOR")`, match: true},
+		{filter: `name = "team-policy" OR id = 1 AND id = 2`, match: false, reason: "OR binds tighter than AND"},
+		{filter: `unknown = "value"`, match: false},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			f, err := Parse(test.filter)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.match, f.Match(policy), test.reason)
+			}
+		})
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	for _, filter := range []string{
+		`name`,
+		`name = `,
+		`name ~ "policy"`,
+		`(name = "policy"`,
+		`name = "policy`,
+		`name eq "("`,
+		`name = "policy")`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := Parse(filter)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package google
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/cassette"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+const (
+	// envRecordProject records the cassettes against the Compute API in this project, instead of replaying them.
+	envRecordProject = "ARMOR_RECORD_PROJECT"
+	// cassetteProject replaces the recorded project in cassettes.
+	cassetteProject = "fake-project"
+)
+
+// recorded returns the project and client options of the named cassette. The cassette is replayed, or recorded
+// with application default credentials when ARMOR_RECORD_PROJECT is set.
+func recorded(t *testing.T, name string) (string, []option.ClientOption) {
+	path := filepath.Join("testdata", "cassettes", name+".json")
+
+	project := os.Getenv(envRecordProject)
+	if project == "" {
+		recorder, err := cassette.New(path, cassette.ModeReplay, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() {
+			for _, interaction := range recorder.Unused() {
+				t.Errorf("recorded request not made: %s %s", interaction.Request.Method, interaction.Request.URL)
+			}
+		})
+		return cassetteProject, recorder.ClientOptions()
+	}
+
+	client, err := google.DefaultClient(ctx, compute.DefaultAuthScopes()...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	recorder, err := cassette.New(path, cassette.ModeRecord, client.Transport, cassette.Replacement{Old: project, New: cassetteProject})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, recorder.Save())
+	})
+	return project, recorder.ClientOptions()
+}
+
+// The contract tests only read from the project, so recording them is safe against any project with a security
+// policy and a backend service.
+
+func Test_contractSecurityPolicies(t *testing.T) {
+	project, opts := recorded(t, "security-policies")
+	client, err := FakeSecurityClient(ctx, opts)
+	assert.NoError(t, err)
+
+	policies, err := client.ListPolicies(ctx, project)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, policies) {
+		return
+	}
+
+	policy, err := client.GetPolicy(ctx, project, policies[0].GetName())
+	assert.NoError(t, err)
+	assert.Equal(t, policies[0].GetName(), policy.GetName())
+	assert.Equal(t, "compute#securityPolicy", policy.GetKind())
+	assert.NotZero(t, policy.GetId())
+	assert.NotEmpty(t, policy.GetFingerprint())
+	assert.Contains(t, policy.GetSelfLink(), "/projects/"+project+"/global/securityPolicies/"+policy.GetName())
+	if !assert.NotEmpty(t, policy.GetRules(), "a policy has at least the default rule") {
+		return
+	}
+
+	first := policy.GetRules()[0]
+	rule, err := client.GetRule(ctx, first.Priority, project, policy.GetName())
+	assert.NoError(t, err)
+	assert.Equal(t, first.GetPriority(), rule.GetPriority())
+	assert.Equal(t, first.GetAction(), rule.GetAction())
+	assert.Equal(t, "compute#securityPolicyRule", rule.GetKind())
+	assert.NotNil(t, rule.GetMatch())
+
+	_, err = client.GetPolicy(ctx, project, "armor-contract-missing")
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func Test_contractPreconfiguredExpressionSets(t *testing.T) {
+	project, opts := recorded(t, "preconfigured-expression-sets")
+	client, err := FakeSecurityClient(ctx, opts)
+	assert.NoError(t, err)
+
+	sets, err := client.ListPreConfiguredRules(ctx, project)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, sets) {
+		return
+	}
+	for _, set := range sets {
+		assert.NotEmpty(t, set.GetId())
+	}
+}
+
+func Test_contractBackendServices(t *testing.T) {
+	project, opts := recorded(t, "backend-services")
+	cfg, err := config.NewConfig()
+	assert.NoError(t, err)
+	client, err := NewServiceClient(cfg, ctx, log.WithField("component", "fake-client"), opts...)
+	assert.NoError(t, err)
+
+	backends, err := client.ListBackendServices(ctx, project)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, backends) {
+		return
+	}
+
+	backend, err := client.GetBackendService(ctx, project, backends[0].GetName())
+	assert.NoError(t, err)
+	assert.Equal(t, backends[0].GetName(), backend.GetName())
+	assert.Equal(t, "compute#backendService", backend.GetKind())
+	assert.Contains(t, backend.GetSelfLink(), "/projects/"+project+"/global/backendServices/"+backend.GetName())
+}
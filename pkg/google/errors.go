@@ -0,0 +1,48 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"google.golang.org/api/googleapi"
+)
+
+// armorError wraps an error from the Compute API in an armor error of the matching kind, the original error is
+// kept in the chain. Operations still running are returned as is, the change was accepted.
+func armorError(err error, format string, args ...interface{}) error {
+	var running *OperationRunningError
+	if errors.As(err, &running) {
+		return err
+	}
+	return armorerr.Wrap(kindOf(err), err, format, args...)
+}
+
+func kindOf(err error) armorerr.Kind {
+	var circuitOpen *CircuitOpenError
+	if errors.As(err, &circuitOpen) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return armorerr.KindUnavailable
+	}
+
+	var e *googleapi.Error
+	if !errors.As(err, &e) {
+		return armorerr.KindInternal
+	}
+	switch {
+	case e.Code == http.StatusBadRequest:
+		return armorerr.KindValidation
+	case e.Code == http.StatusUnauthorized:
+		return armorerr.KindUnauthenticated
+	case e.Code == http.StatusForbidden:
+		return armorerr.KindForbidden
+	case e.Code == http.StatusNotFound:
+		return armorerr.KindNotFound
+	case e.Code == http.StatusConflict || e.Code == http.StatusPreconditionFailed:
+		return armorerr.KindConflict
+	case e.Code == http.StatusTooManyRequests || e.Code >= http.StatusInternalServerError:
+		return armorerr.KindUnavailable
+	default:
+		return armorerr.KindInternal
+	}
+}
@@ -0,0 +1,108 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/nais/armor/pkg/logging"
+	"github.com/nais/armor/pkg/metrics"
+	"github.com/nais/armor/pkg/tracing"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+// OperationEvent describes a change in the lifecycle of a long-running Compute operation.
+type OperationEvent struct {
+	Project string
+	Method  string
+	Name    string
+	Done    bool
+	// Running is set instead of Done when waiting ended before the operation finished, Err is then an
+	// *OperationRunningError.
+	Running bool
+	Err     error
+}
+
+// OperationHook is called when Google has accepted a long-running operation, and when it has finished or waiting
+// for it ended.
+type OperationHook func(event OperationEvent)
+
+type operationHooksKey struct{}
+
+// WithOperationHook adds a hook to the context, hooks already in the context are still called.
+func WithOperationHook(ctx context.Context, hook OperationHook) context.Context {
+	hooks, _ := ctx.Value(operationHooksKey{}).([]OperationHook)
+	hooks = append(append([]OperationHook{}, hooks...), hook)
+	return context.WithValue(ctx, operationHooksKey{}, hooks)
+}
+
+// NotifyOperation logs the event with the request log and calls the hooks in the context, if any.
+func NotifyOperation(ctx context.Context, event OperationEvent) {
+	log := logging.LoggerFromContext(ctx, nil).WithField("operation", event.Name)
+	switch {
+	case event.Running:
+		log.Infof("%s operation still running, stopped waiting: %v", event.Method, event.Err)
+	case !event.Done:
+		log.Debugf("%s accepted by google, waiting for the operation", event.Method)
+	case event.Err != nil:
+		log.Warnf("%s operation failed: %v", event.Method, event.Err)
+	default:
+		log.Debugf("%s operation done", event.Method)
+	}
+
+	hooks, _ := ctx.Value(operationHooksKey{}).([]OperationHook)
+	for _, hook := range hooks {
+		hook(event)
+	}
+}
+
+// OperationRunningError is returned when the context ended while waiting for an operation Google has accepted,
+// the operation itself is still running.
+type OperationRunningError struct {
+	Name string
+	Err  error
+}
+
+func (e *OperationRunningError) Error() string {
+	return fmt.Sprintf("operation %s still running: %v", e.Name, e.Err)
+}
+
+func (e *OperationRunningError) Unwrap() error {
+	return e.Err
+}
+
+// wait waits for an operation Google has accepted, and reports its lifecycle to the hooks in the context.
+func wait(ctx context.Context, client, method, projectID, action string, op *compute.Operation) (*computepb.Operation, error) {
+	NotifyOperation(ctx, OperationEvent{Project: projectID, Method: method, Name: op.Name()})
+
+	waitCtx, span := tracing.Start(ctx, "op.Wait", tracing.AttributeProject.String(projectID), tracing.AttributeGoogleOp.String(op.Name()))
+	start := time.Now()
+	err := op.Wait(waitCtx)
+	metrics.OperationWait(client, method, start, err)
+	tracing.End(span, err)
+
+	if err != nil {
+		err = waitError(op.Name(), action, err)
+	}
+	var running *OperationRunningError
+	if errors.As(err, &running) {
+		NotifyOperation(ctx, OperationEvent{Project: projectID, Method: method, Name: op.Name(), Running: true, Err: err})
+	} else {
+		NotifyOperation(ctx, OperationEvent{Project: projectID, Method: method, Name: op.Name(), Done: true, Err: err})
+	}
+	if err != nil {
+		tracing.RecordError(ctx, err)
+		return nil, err
+	}
+
+	return op.Proto(), nil
+}
+
+func waitError(name, action string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return &OperationRunningError{Name: name, Err: err}
+	}
+	return armorError(err, action)
+}
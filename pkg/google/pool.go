@@ -0,0 +1,176 @@
+package google
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+const (
+	// callerClientTTL is how long an unused caller client is kept, short enough to not outlive most access tokens.
+	callerClientTTL = 5 * time.Minute
+	// maxCallerClients bounds the number of pooled caller clients, the least recently used is evicted first.
+	maxCallerClients = 100
+)
+
+type callerClients struct {
+	security *SecurityClient
+	service  *ServiceClient
+	lastUsed time.Time
+	// leases counts the callers still using the clients, evicted clients are closed when the last one releases them.
+	leases  int
+	evicted bool
+}
+
+// ClientPool hands out Google clients authenticated with the forwarded access token of the caller,
+// so changes are attributed to the caller and authorized by Google IAM.
+type ClientPool struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	log      *logrus.Entry
+	cfg      *config.Config
+	opts     []option.ClientOption
+	security *SecurityClient
+	service  *ServiceClient
+	clients  map[string]*callerClients
+}
+
+func NewClientPool(ctx context.Context, cfg *config.Config, log *logrus.Entry, security *SecurityClient, service *ServiceClient, opts ...option.ClientOption) *ClientPool {
+	return &ClientPool{
+		ctx:      ctx,
+		log:      log,
+		cfg:      cfg,
+		opts:     opts,
+		security: security,
+		service:  service,
+		clients:  make(map[string]*callerClients),
+	}
+}
+
+// Clients returns clients authenticated with accessToken, or the shared clients when no token is given. The clients
+// are leased until release is called, and are not closed before.
+func (in *ClientPool) Clients(accessToken string) (cloudarmor.SecurityPolicies, cloudarmor.BackendServices, func(), error) {
+	if accessToken == "" {
+		return in.security, in.service, func() {}, nil
+	}
+
+	key := tokenKey(accessToken)
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.evict()
+
+	if c, ok := in.clients[key]; ok {
+		c.lastUsed = time.Now()
+		return c.security, c.service, in.lease(c), nil
+	}
+
+	opts := append([]option.ClientOption{
+		option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})),
+	}, in.opts...)
+
+	security, err := NewSecurityClient(in.cfg, in.ctx, in.log, opts...)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("create caller security client: %w", err)
+	}
+
+	service, err := NewServiceClient(in.cfg, in.ctx, in.log, opts...)
+	if err != nil {
+		_ = security.Client.Close()
+		return nil, nil, nil, fmt.Errorf("create caller service client: %w", err)
+	}
+
+	// Caller clients share retries and circuit breakers with the shared clients, the Compute API is the same.
+	if in.security != nil {
+		security.retrier = in.security.retrier
+	}
+	if in.service != nil {
+		service.retrier = in.service.retrier
+	}
+
+	c := &callerClients{
+		security: security,
+		service:  service,
+		lastUsed: time.Now(),
+	}
+	in.clients[key] = c
+	return security, service, in.lease(c), nil
+}
+
+// lease marks the clients as used until the returned release is called, must be called with the lock held.
+func (in *ClientPool) lease(c *callerClients) func() {
+	c.leases++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			in.mu.Lock()
+			defer in.mu.Unlock()
+			c.leases--
+			if c.evicted && c.leases == 0 {
+				in.close(c)
+			}
+		})
+	}
+}
+
+// evict removes clients unused for longer than the ttl, and the least recently used when the pool is full.
+func (in *ClientPool) evict() {
+	for key, c := range in.clients {
+		if time.Since(c.lastUsed) > callerClientTTL {
+			in.remove(key)
+		}
+	}
+
+	for len(in.clients) >= maxCallerClients {
+		var oldest string
+		for key, c := range in.clients {
+			if oldest == "" || c.lastUsed.Before(in.clients[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		in.remove(oldest)
+	}
+}
+
+// remove drops the clients from the pool, and closes them unless they are still leased.
+func (in *ClientPool) remove(key string) {
+	c := in.clients[key]
+	delete(in.clients, key)
+	c.evicted = true
+	if c.leases == 0 {
+		in.close(c)
+	}
+}
+
+func (in *ClientPool) close(c *callerClients) {
+	if err := c.security.Client.Close(); err != nil {
+		in.log.Warnf("close caller security client: %v", err)
+	}
+	if err := c.service.Client.Close(); err != nil {
+		in.log.Warnf("close caller service client: %v", err)
+	}
+}
+
+// Close closes all pooled caller clients that are not leased, leased clients are closed when released.
+func (in *ClientPool) Close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for key := range in.clients {
+		in.remove(key)
+	}
+}
+
+func tokenKey(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])
+}
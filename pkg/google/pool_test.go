@@ -0,0 +1,80 @@
+package google
+
+import (
+	"github.com/nais/armor/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_ClientPool(t *testing.T) {
+	// Caller clients are created with their own options, so the fake is served on a port.
+	compute, _ := fakeCompute(t)
+	server, opts := compute.Listen()
+	defer server.Close()
+	shared, err := FakeSecurityClient(ctx, opts)
+	assert.NoError(t, err)
+
+	// Caller clients carry their own token source, so only the endpoint of the fake is reused.
+	pool := NewClientPool(ctx, &config.Config{}, log.WithField("component", "fake-pool"), shared, nil, opts[0])
+	defer pool.Close()
+
+	security, _, _, err := pool.Clients("")
+	assert.NoError(t, err)
+	assert.Same(t, shared, security, "no token falls back to the shared client")
+
+	first, _, _, err := pool.Clients("token-a")
+	assert.NoError(t, err)
+	assert.NotSame(t, shared, first)
+
+	again, _, _, err := pool.Clients("token-a")
+	assert.NoError(t, err)
+	assert.Same(t, first, again, "same token reuses the pooled client")
+
+	other, _, _, err := pool.Clients("token-b")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other)
+
+	res, err := first.GetPolicy(ctx, "fake-project", "test-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-2", *res.Name)
+}
+
+func Test_ClientPoolEvictLeased(t *testing.T) {
+	compute, _ := fakeCompute(t)
+	server, opts := compute.Listen()
+	defer server.Close()
+	shared, err := FakeSecurityClient(ctx, opts)
+	assert.NoError(t, err)
+
+	pool := NewClientPool(ctx, &config.Config{}, log.WithField("component", "fake-pool"), shared, nil, opts[0])
+	defer pool.Close()
+
+	borrowed, _, release, err := pool.Clients("token-a")
+	assert.NoError(t, err)
+
+	pool.mu.Lock()
+	pool.clients[tokenKey("token-a")].lastUsed = time.Now().Add(-2 * callerClientTTL)
+	pool.mu.Unlock()
+
+	_, _, releaseOther, err := pool.Clients("token-b")
+	assert.NoError(t, err)
+	defer releaseOther()
+	assert.NotContains(t, pool.clients, tokenKey("token-a"), "expired clients are evicted")
+
+	res, err := borrowed.GetPolicy(ctx, "fake-project", "test-2")
+	assert.NoError(t, err, "evicted clients stay usable while leased")
+	assert.Equal(t, "test-2", res.GetName())
+
+	again, _, releaseAgain, err := pool.Clients("token-a")
+	assert.NoError(t, err)
+	defer releaseAgain()
+	assert.NotSame(t, borrowed, again, "evicted clients are not handed out again")
+
+	release()
+	release()
+	assert.Panics(t, func() {
+		_, _ = borrowed.GetPolicy(ctx, "fake-project", "test-2")
+	}, "evicted clients are closed once released")
+}
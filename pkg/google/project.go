@@ -0,0 +1,19 @@
+package google
+
+import (
+	"context"
+	"fmt"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	googleoauth "golang.org/x/oauth2/google"
+)
+
+// DefaultProject returns the project of the application default credentials, from the credentials file or the
+// metadata server, or an empty string when they do not name one.
+func DefaultProject(ctx context.Context) (string, error) {
+	credentials, err := googleoauth.FindDefaultCredentials(ctx, compute.DefaultAuthScopes()...)
+	if err != nil {
+		return "", fmt.Errorf("find default credentials: %w", err)
+	}
+	return credentials.ProjectID, nil
+}
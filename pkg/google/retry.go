@@ -0,0 +1,233 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/logging"
+	"github.com/nais/armor/pkg/metrics"
+	"github.com/nais/armor/pkg/tracing"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/googleapi"
+)
+
+// reasonResourceNotReady is returned by the Compute API when a resource is still being changed by another operation.
+const reasonResourceNotReady = "resourceNotReady"
+
+// CircuitOpenError is returned without calling the Compute API while calls for the project fail fast.
+type CircuitOpenError struct {
+	Project string
+	Until   time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("compute api calls for project %s fail fast until %s after repeated errors", e.Project, e.Until.Format(time.RFC3339))
+}
+
+// Retrier retries idempotent Compute API calls failing with a transient error, with jittered exponential backoff,
+// and fails calls for a project fast when it keeps failing.
+type Retrier struct {
+	mu             sync.Mutex
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	threshold      int
+	cooldown       time.Duration
+	breakers       map[string]*breaker
+}
+
+// breaker is open while failures has reached the threshold, after the cooldown a single trial call is let through.
+type breaker struct {
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func NewRetrier(cfg *config.Config) *Retrier {
+	return &Retrier{
+		maxAttempts:    cfg.RetryMaxAttempts,
+		initialBackoff: cfg.RetryInitialBackoff,
+		maxBackoff:     cfg.RetryMaxBackoff,
+		threshold:      cfg.BreakerFailureThreshold,
+		cooldown:       cfg.BreakerCooldown,
+		breakers:       make(map[string]*breaker),
+	}
+}
+
+// ShareRetrier makes the service client retry and fail fast with the retrier of the security client, so a project
+// failing for one of them fails fast for both.
+func ShareRetrier(security *SecurityClient, service *ServiceClient) {
+	service.retrier = security.retrier
+}
+
+// Do calls the Compute API through call, retrying it when idempotent and observing every attempt.
+func (in *Retrier) Do(ctx context.Context, projectID, client, method string, idempotent bool, call func() error) error {
+	log := logging.LoggerFromContext(ctx, nil)
+
+	if err := in.allow(projectID); err != nil {
+		metrics.CircuitBreakerRejection(projectID, client, method)
+		tracing.RecordError(ctx, err)
+		log.Debugf("%s rejected: %v", method, err)
+		return err
+	}
+
+	attempts := 1
+	if idempotent && in.maxAttempts > 1 {
+		attempts = in.maxAttempts
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err = call()
+		metrics.GoogleCall(client, method, start, err)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			break
+		}
+
+		metrics.GoogleRetry(client, method)
+		backoff := in.backoff(attempt)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("backoff", backoff.String()),
+			attribute.String("error", err.Error()),
+		))
+		log.Warnf("%s failed on attempt %d, retrying in %s: %v", method, attempt, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			in.done(ctx, log, projectID, err)
+			return err
+		}
+	}
+
+	in.done(ctx, log, projectID, err)
+	return err
+}
+
+func (in *Retrier) done(ctx context.Context, log *logrus.Entry, projectID string, err error) {
+	tracing.RecordError(ctx, err)
+	if in.record(projectID, err) {
+		log.Warnf("compute api calls for project %s fail fast for %s after %d consecutive errors: %v", projectID, in.cooldown, in.threshold, err)
+	}
+}
+
+// backoff doubles the initial backoff for every attempt, and picks a random duration in the upper half of it.
+func (in *Retrier) backoff(attempt int) time.Duration {
+	d := in.initialBackoff
+	for i := 1; i < attempt && d < in.maxBackoff; i++ {
+		d *= 2
+	}
+	if in.maxBackoff > 0 && d > in.maxBackoff {
+		d = in.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (in *Retrier) allow(projectID string) error {
+	if in.threshold <= 0 {
+		return nil
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	b, ok := in.breakers[projectID]
+	if !ok || b.failures < in.threshold {
+		return nil
+	}
+	if b.trial || time.Now().Before(b.openUntil) {
+		return &CircuitOpenError{Project: projectID, Until: b.openUntil}
+	}
+	b.trial = true
+	return nil
+}
+
+// record updates the breaker of the project with the result of a call, and reports whether the breaker opened.
+func (in *Retrier) record(projectID string, err error) bool {
+	if in.threshold <= 0 {
+		return false
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	b, ok := in.breakers[projectID]
+	if !ok {
+		b = &breaker{}
+		in.breakers[projectID] = b
+	}
+	b.trial = false
+
+	switch {
+	case transient(err):
+		b.failures++
+		if b.failures >= in.threshold {
+			b.openUntil = time.Now().Add(in.cooldown)
+			metrics.CircuitBreaker(projectID, true)
+			return true
+		}
+	case err == nil:
+		if b.failures >= in.threshold {
+			metrics.CircuitBreaker(projectID, false)
+		}
+		b.failures = 0
+	default:
+		// The caller gave up, or was refused by the Compute API, e.g. with 403 or 404, which says nothing about its health.
+	}
+	return false
+}
+
+func retryable(err error) bool {
+	if transient(err) {
+		return true
+	}
+
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		for _, item := range e.Errors {
+			if item.Reason == reasonResourceNotReady {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// transient reports whether err is caused by the Compute API being unavailable or overloaded.
+func transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		switch e.Code {
+		case http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,177 @@
+package google
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/fake"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func retryConfig() *config.Config {
+	return &config.Config{
+		RetryMaxAttempts:        3,
+		RetryInitialBackoff:     time.Millisecond,
+		RetryMaxBackoff:         5 * time.Millisecond,
+		BreakerFailureThreshold: 2,
+		BreakerCooldown:         time.Hour,
+	}
+}
+
+func Test_Retries(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fault    fake.Fault
+		call     func(client *SecurityClient) error
+		requests int
+		fails    bool
+	}{
+		{
+			name:   "Get is retried after transient errors",
+			fault:  fake.Fault{Times: 2, Code: http.StatusServiceUnavailable},
+			call: func(client *SecurityClient) error {
+				_, err := client.GetPolicy(ctx, "fake-project", "test-2")
+				return err
+			},
+			requests: 3,
+		},
+		{
+			name:   "Get gives up after the maximum attempts",
+			fault:  fake.Fault{Times: 3, Code: http.StatusTooManyRequests},
+			call: func(client *SecurityClient) error {
+				_, err := client.GetPolicy(ctx, "fake-project", "test-2")
+				return err
+			},
+			requests: 3,
+			fails:    true,
+		},
+		{
+			name:   "Client errors are not retried",
+			fault:  fake.Fault{Times: 1, Code: http.StatusNotFound},
+			call: func(client *SecurityClient) error {
+				_, err := client.GetPolicy(ctx, "fake-project", "test-2")
+				return err
+			},
+			requests: 1,
+			fails:    true,
+		},
+		{
+			name:   "Patch with a fingerprint is retried",
+			fault:  fake.Fault{Times: 1, Code: http.StatusBadGateway},
+			call: func(client *SecurityClient) error {
+				_, err := client.UpdatePolicy(ctx, &computepb.SecurityPolicy{Fingerprint: proto.String("PmCPeyUTcuA=")}, "fake-project", "test-2")
+				return err
+			},
+			// the retried patch and waiting for the operation
+			requests: 3,
+		},
+		{
+			name:   "Patch without a fingerprint is not retried",
+			fault:  fake.Fault{Times: 1, Code: http.StatusBadGateway},
+			call: func(client *SecurityClient) error {
+				_, err := client.UpdatePolicy(ctx, &computepb.SecurityPolicy{}, "fake-project", "test-2")
+				return err
+			},
+			requests: 1,
+			fails:    true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			compute, opts := fakeCompute(t)
+			compute.Inject(test.fault)
+			client, err := NewSecurityClient(retryConfig(), ctx, log.WithField("component", "fake-client"), opts...)
+			assert.NoError(t, err)
+
+			err = test.call(client)
+			assert.Equal(t, test.fails, err != nil, "unexpected error: %v", err)
+			assert.Equal(t, test.requests, compute.Requests(""))
+		})
+	}
+}
+
+func Test_CircuitBreaker(t *testing.T) {
+	compute, opts := fakeCompute(t)
+	compute.Inject(fake.Fault{Code: http.StatusServiceUnavailable})
+	cfg := retryConfig()
+	cfg.RetryMaxAttempts = 1
+	client, err := NewSecurityClient(cfg, ctx, log.WithField("component", "fake-client"), opts...)
+	assert.NoError(t, err)
+
+	for i := 0; i < cfg.BreakerFailureThreshold; i++ {
+		_, err = client.GetPolicy(ctx, "fake-project", "test-2")
+		assert.Error(t, err)
+	}
+
+	_, err = client.GetPolicy(ctx, "fake-project", "test-2")
+	var circuitOpen *CircuitOpenError
+	assert.True(t, errors.As(err, &circuitOpen), "expected circuit open, got %v", err)
+	assert.Equal(t, cfg.BreakerFailureThreshold, compute.Requests(""))
+
+	// Other projects are not affected.
+	_, err = client.GetPolicy(ctx, "other-project", "test-2")
+	assert.False(t, errors.As(err, &circuitOpen))
+	assert.Equal(t, cfg.BreakerFailureThreshold+1, compute.Requests(""))
+}
+
+func Test_CircuitBreakerTrialCall(t *testing.T) {
+	retrier := NewRetrier(&config.Config{BreakerFailureThreshold: 1, BreakerCooldown: time.Millisecond})
+	notFound := errors.New("not found")
+
+	retrier.record("fake-project", &fakeTransientError{})
+	assert.Error(t, retrier.allow("fake-project"))
+
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, retrier.allow("fake-project"), "a trial call is let through after the cooldown")
+	assert.Error(t, retrier.allow("fake-project"), "only a single trial call is let through")
+
+	retrier.record("fake-project", notFound)
+	assert.NoError(t, retrier.allow("fake-project"), "another trial call is let through after a client error")
+	assert.Error(t, retrier.allow("fake-project"), "the breaker stays half-open after a client error")
+
+	retrier.record("fake-project", nil)
+	assert.NoError(t, retrier.allow("fake-project"), "the breaker closes when the trial call succeeds")
+	assert.NoError(t, retrier.allow("fake-project"))
+}
+
+func Test_CircuitBreakerClientErrors(t *testing.T) {
+	retrier := NewRetrier(&config.Config{BreakerFailureThreshold: 2, BreakerCooldown: time.Hour})
+
+	retrier.record("fake-project", &fakeTransientError{})
+	retrier.record("fake-project", errors.New("forbidden"))
+	retrier.record("fake-project", &fakeTransientError{})
+	assert.Error(t, retrier.allow("fake-project"), "client errors do not reset the consecutive failures")
+}
+
+func Test_CircuitBreakerShared(t *testing.T) {
+	compute, opts := fakeCompute(t)
+	compute.Inject(fake.Fault{Code: http.StatusServiceUnavailable})
+	cfg := retryConfig()
+	cfg.RetryMaxAttempts = 1
+	security, err := NewSecurityClient(cfg, ctx, log.WithField("component", "fake-client"), opts...)
+	assert.NoError(t, err)
+	service, err := NewServiceClient(cfg, ctx, log.WithField("component", "fake-client"), opts...)
+	assert.NoError(t, err)
+	ShareRetrier(security, service)
+
+	for i := 0; i < cfg.BreakerFailureThreshold; i++ {
+		_, err = security.GetPolicy(ctx, "fake-project", "test-2")
+		assert.Error(t, err)
+	}
+
+	_, err = service.ListBackendServices(ctx, "fake-project")
+	var circuitOpen *CircuitOpenError
+	assert.ErrorAs(t, err, &circuitOpen, "a project failing for policies fails fast for backend services")
+	assert.Equal(t, cfg.BreakerFailureThreshold, compute.Requests(""))
+}
+
+type fakeTransientError struct{}
+
+func (e *fakeTransientError) Error() string   { return "timeout" }
+func (e *fakeTransientError) Timeout() bool   { return true }
+func (e *fakeTransientError) Temporary() bool { return true }
@@ -5,199 +5,318 @@ import (
 	"context"
 	"fmt"
 	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/metrics"
+	"github.com/nais/armor/pkg/tracing"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
 )
 
+const securityClientName = "security"
+
+var _ cloudarmor.SecurityPolicies = &SecurityClient{}
+
 type SecurityClient struct {
-	log    *logrus.Entry
-	Client *compute.SecurityPoliciesClient
-	Config *config.Config
+	log     *logrus.Entry
+	Client  *compute.SecurityPoliciesClient
+	Config  *config.Config
+	retrier *Retrier
 }
 
-func NewSecurityClient(cfg *config.Config, ctx context.Context, log *logrus.Entry, opts ...option.ClientOption) *SecurityClient {
-	c, err := compute.NewSecurityPoliciesRESTClient(ctx)
-	if opts != nil && len(opts) > 0 {
-		c, err = compute.NewSecurityPoliciesRESTClient(ctx, opts...)
-	}
-
+func NewSecurityClient(cfg *config.Config, ctx context.Context, log *logrus.Entry, opts ...option.ClientOption) (*SecurityClient, error) {
+	c, err := compute.NewSecurityPoliciesRESTClient(ctx, opts...)
 	if err != nil {
-		fmt.Printf("NewInstancesRESTClient: %v", err)
+		return nil, fmt.Errorf("create security policies client: %w", err)
 	}
-	log.Info("created NewInstancesRESTClient")
 
 	return &SecurityClient{
-		log:    log,
-		Client: c,
-		Config: cfg,
+		log:     log,
+		Client:  c,
+		Config:  cfg,
+		retrier: NewRetrier(cfg),
+	}, nil
+}
+
+// ListPolicies returns all policies in the project, fetching every page.
+func (in *SecurityClient) ListPolicies(ctx context.Context, projectID string) ([]*computepb.SecurityPolicy, error) {
+	req := &computepb.ListSecurityPoliciesRequest{
+		Project: projectID,
+	}
+
+	it := in.Client.List(ctx, req)
+	fetch := it.InternalFetch
+	it.InternalFetch = func(pageSize int, pageToken string) ([]*computepb.SecurityPolicy, string, error) {
+		ctx, span := tracing.Start(ctx, "SecurityClient.ListPolicies", tracing.AttributeProject.String(projectID))
+		defer span.End()
+
+		var policies []*computepb.SecurityPolicy
+		var next string
+		err := in.retrier.Do(ctx, projectID, securityClientName, "ListPolicies", true, func() (err error) {
+			policies, next, err = fetch(pageSize, pageToken)
+			return err
+		})
+		for _, policy := range policies {
+			metrics.PolicyRules(projectID, policy.GetName(), len(policy.GetRules()))
+		}
+		return policies, next, err
+	}
+
+	policies := []*computepb.SecurityPolicy{}
+	for {
+		policy, err := it.Next()
+		if err == iterator.Done {
+			return policies, nil
+		}
+		if err != nil {
+			return nil, armorError(err, "list policies")
+		}
+		policies = append(policies, policy)
 	}
 }
 
-func (in *SecurityClient) ListPolicies(ctx context.Context, projectID string) *compute.SecurityPolicyIterator {
+func (in *SecurityClient) ListPoliciesPage(ctx context.Context, projectID string, opts cloudarmor.ListOptions) ([]*computepb.SecurityPolicy, string, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.ListPoliciesPage", tracing.AttributeProject.String(projectID))
+	defer span.End()
+
 	req := &computepb.ListSecurityPoliciesRequest{
 		Project: projectID,
 	}
+	if opts.Filter != "" {
+		req.Filter = proto.String(opts.Filter)
+	}
 
-	return in.Client.List(ctx, req)
+	var policies []*computepb.SecurityPolicy
+	var next string
+	err := in.retrier.Do(ctx, projectID, securityClientName, "ListPolicies", true, func() (err error) {
+		policies, next, err = in.Client.List(ctx, req).InternalFetch(opts.PageSize, opts.PageToken)
+		return err
+	})
+	if err != nil {
+		return nil, "", armorError(err, "list policies")
+	}
+	for _, policy := range policies {
+		metrics.PolicyRules(projectID, policy.GetName(), len(policy.GetRules()))
+	}
+	return policies, next, nil
 }
 
 func (in *SecurityClient) GetPolicy(ctx context.Context, projectID, policyName string) (*computepb.SecurityPolicy, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.GetPolicy", tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName))
+	defer span.End()
+
 	req := &computepb.GetSecurityPolicyRequest{
 		Project:        projectID,
 		SecurityPolicy: policyName,
 	}
 
-	result, err := in.Client.Get(ctx, req)
+	var result *computepb.SecurityPolicy
+	err := in.retrier.Do(ctx, projectID, securityClientName, "GetPolicy", true, func() (err error) {
+		result, err = in.Client.Get(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("get policy: %w", err)
+		return nil, armorError(err, "get policy")
 	}
+	metrics.PolicyRules(projectID, policyName, len(result.GetRules()))
 
 	return result, nil
 }
 
-func (in *SecurityClient) CreatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID string) (bool, error) {
+func (in *SecurityClient) CreatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.CreatePolicy",
+		tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policy.GetName()))
+	defer span.End()
+
 	req := &computepb.InsertSecurityPolicyRequest{
 		Project:                projectID,
 		SecurityPolicyResource: policy,
 	}
 
-	op, err := in.Client.Insert(ctx, req)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, securityClientName, "CreatePolicy", false, func() (err error) {
+		op, err = in.Client.Insert(ctx, req)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("insert policy: %w", err)
+		return nil, armorError(err, "insert policy")
 	}
 
-	err = op.Wait(ctx)
-	if err != nil {
-		return false, fmt.Errorf("wait policy: %w", err)
-	}
-
-	return op.Done(), nil
+	return wait(ctx, securityClientName, "CreatePolicy", projectID, "wait policy", op)
 }
 
-func (in *SecurityClient) UpdatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID, policyName string) (bool, error) {
+func (in *SecurityClient) UpdatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID, policyName string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.UpdatePolicy", tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName))
+	defer span.End()
+
 	req := &computepb.PatchSecurityPolicyRequest{
 		SecurityPolicy:         policyName,
 		Project:                projectID,
 		SecurityPolicyResource: policy,
 	}
 
-	op, err := in.Client.Patch(ctx, req)
-	if err != nil {
-		return false, fmt.Errorf("update policy: %w", err)
-	}
-
-	err = op.Wait(ctx)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, securityClientName, "UpdatePolicy", policy.Fingerprint != nil, func() (err error) {
+		op, err = in.Client.Patch(ctx, req)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("wait policy: %w", err)
+		return nil, armorError(err, "update policy")
 	}
 
-	return op.Done(), nil
+	return wait(ctx, securityClientName, "UpdatePolicy", projectID, "wait policy", op)
 }
 
-func (in *SecurityClient) DeletePolicy(ctx context.Context, projectID, policyName string) (bool, error) {
+func (in *SecurityClient) DeletePolicy(ctx context.Context, projectID, policyName string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.DeletePolicy", tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName))
+	defer span.End()
+
 	req := &computepb.DeleteSecurityPolicyRequest{
 		SecurityPolicy: policyName,
 		Project:        projectID,
 	}
 
-	op, err := in.Client.Delete(ctx, req)
-	if err != nil {
-		return false, fmt.Errorf("delete policy: %w", err)
-	}
-
-	err = op.Wait(ctx)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, securityClientName, "DeletePolicy", false, func() (err error) {
+		op, err = in.Client.Delete(ctx, req)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("wait policy: %w", err)
+		return nil, armorError(err, "delete policy")
 	}
 
-	return op.Done(), nil
+	return wait(ctx, securityClientName, "DeletePolicy", projectID, "wait policy", op)
 }
 
 func (in *SecurityClient) GetRule(ctx context.Context, priority *int32, projectID, policyName string) (*computepb.SecurityPolicyRule, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.GetRule",
+		tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName), tracing.AttributePriority.Int64(int64(*priority)))
+	defer span.End()
+
 	req := &computepb.GetRuleSecurityPolicyRequest{
 		SecurityPolicy: policyName,
 		Project:        projectID,
 		Priority:       priority,
 	}
 
-	rule, err := in.Client.GetRule(ctx, req)
+	var rule *computepb.SecurityPolicyRule
+	err := in.retrier.Do(ctx, projectID, securityClientName, "GetRule", true, func() (err error) {
+		rule, err = in.Client.GetRule(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("add rule: %w", err)
+		return nil, armorError(err, "get rule")
 	}
 
 	return rule, nil
 }
 
-func (in *SecurityClient) AddRule(ctx context.Context, resource *computepb.SecurityPolicyRule, projectID, policyName string) (bool, error) {
+func (in *SecurityClient) AddRule(ctx context.Context, resource *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.AddRule",
+		tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName), tracing.AttributePriority.Int64(int64(resource.GetPriority())))
+	defer span.End()
+
 	req := &computepb.AddRuleSecurityPolicyRequest{
 		SecurityPolicy:             policyName,
 		Project:                    projectID,
 		SecurityPolicyRuleResource: resource,
 	}
 
-	op, err := in.Client.AddRule(ctx, req)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, securityClientName, "AddRule", false, func() (err error) {
+		op, err = in.Client.AddRule(ctx, req)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("add rule: %w", err)
+		return nil, armorError(err, "add rule")
 	}
 
-	err = op.Wait(ctx)
-	if err != nil {
-		return false, fmt.Errorf("wait rule: %w", err)
-	}
-
-	return op.Done(), nil
+	return wait(ctx, securityClientName, "AddRule", projectID, "wait rule", op)
 }
 
-func (in *SecurityClient) UpdateRule(ctx context.Context, resource *computepb.SecurityPolicyRule, projectID, policyName string) (bool, error) {
+func (in *SecurityClient) UpdateRule(ctx context.Context, resource *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.UpdateRule",
+		tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName), tracing.AttributePriority.Int64(int64(resource.GetPriority())))
+	defer span.End()
+
 	req := &computepb.PatchRuleSecurityPolicyRequest{
 		SecurityPolicy:             policyName,
 		Project:                    projectID,
 		SecurityPolicyRuleResource: resource,
 	}
 
-	op, err := in.Client.PatchRule(ctx, req)
-	if err != nil {
-		return false, fmt.Errorf("patch rule: %w", err)
-	}
-
-	err = op.Wait(ctx)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, securityClientName, "UpdateRule", false, func() (err error) {
+		op, err = in.Client.PatchRule(ctx, req)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("wait rule: %w", err)
+		return nil, armorError(err, "patch rule")
 	}
 
-	return op.Done(), nil
+	return wait(ctx, securityClientName, "UpdateRule", projectID, "wait rule", op)
 }
 
-func (in *SecurityClient) RemoveRule(ctx context.Context, priority *int32, projectID, policyName string) (bool, error) {
+func (in *SecurityClient) RemoveRule(ctx context.Context, priority *int32, projectID, policyName string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.RemoveRule",
+		tracing.AttributeProject.String(projectID), tracing.AttributePolicy.String(policyName), tracing.AttributePriority.Int64(int64(*priority)))
+	defer span.End()
+
 	req := &computepb.RemoveRuleSecurityPolicyRequest{
 		SecurityPolicy: policyName,
 		Project:        projectID,
 		Priority:       priority,
 	}
 
-	op, err := in.Client.RemoveRule(ctx, req)
-	if err != nil {
-		return false, fmt.Errorf("remove rule: %w", err)
-	}
-
-	err = op.Wait(ctx)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, securityClientName, "RemoveRule", false, func() (err error) {
+		op, err = in.Client.RemoveRule(ctx, req)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("wait rule: %w", err)
+		return nil, armorError(err, "remove rule")
 	}
 
-	return op.Done(), nil
+	return wait(ctx, securityClientName, "RemoveRule", projectID, "wait rule", op)
 }
 
-func (in *SecurityClient) ListPreConfiguredRules(ctx context.Context, projectID string) (*computepb.SecurityPoliciesListPreconfiguredExpressionSetsResponse, error) {
+// ListPreConfiguredRules returns the preconfigured WAF expression sets.
+func (in *SecurityClient) ListPreConfiguredRules(ctx context.Context, projectID string) ([]*computepb.WafExpressionSet, error) {
+	ctx, span := tracing.Start(ctx, "SecurityClient.ListPreConfiguredRules", tracing.AttributeProject.String(projectID))
+	defer span.End()
+
 	req := &computepb.ListPreconfiguredExpressionSetsSecurityPoliciesRequest{
 		Project: projectID,
 	}
 
-	resp, err := in.Client.ListPreconfiguredExpressionSets(ctx, req)
+	var resp *computepb.SecurityPoliciesListPreconfiguredExpressionSetsResponse
+	err := in.retrier.Do(ctx, projectID, securityClientName, "ListPreConfiguredRules", true, func() (err error) {
+		resp, err = in.Client.ListPreconfiguredExpressionSets(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("get preconfigured: %w", err)
+		return nil, armorError(err, "get preconfigured")
+	}
+
+	return resp.GetPreconfiguredExpressionSets().GetWafRules().GetExpressionSets(), nil
+}
+
+// Probe lists at most one policy in the project to verify that the Compute API is reachable with the current credentials.
+func (in *SecurityClient) Probe(ctx context.Context, projectID string) error {
+	ctx, span := tracing.Start(ctx, "SecurityClient.Probe", tracing.AttributeProject.String(projectID))
+	defer span.End()
+
+	req := &computepb.ListSecurityPoliciesRequest{
+		Project:    projectID,
+		MaxResults: proto.Uint32(1),
 	}
 
-	return resp, nil
+	_, err := in.Client.List(ctx, req).Next()
+	if err != nil && err != iterator.Done {
+		tracing.RecordError(ctx, err)
+		return armorError(err, "probe security policies")
+	}
+	return nil
 }
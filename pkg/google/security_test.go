@@ -3,13 +3,18 @@ package google
 import (
 	"context"
 	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/armorerr"
 	"github.com/nais/armor/pkg/fake"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
-	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/api/googleapi"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"os"
 	"testing"
+	"time"
 )
 
 var ctx = context.Background()
@@ -32,20 +37,12 @@ func Test_ListPolicies(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
-			opts, err := fake.SecurityApiServer(test.policyName, test.exists)
-			assert.NoError(t, err)
+			_, opts := fakeCompute(t)
 			fakeClient, err := FakeSecurityClient(ctx, opts)
 			assert.NoError(t, err)
 
-			it := fakeClient.ListPolicies(ctx, test.project)
-			var policies []*compute.SecurityPolicy
-			for {
-				resp, err := it.Next()
-				if err == iterator.Done {
-					break
-				}
-				policies = append(policies, resp)
-			}
+			policies, err := fakeClient.ListPolicies(ctx, test.project)
+			assert.NoError(t, err)
 			assert.Equal(t, test.policies, len(policies))
 		})
 	}
@@ -53,8 +50,7 @@ func Test_ListPolicies(t *testing.T) {
 
 func Test_GetPolicy(t *testing.T) {
 	existingPolicy := "test-2"
-	opts, err := fake.SecurityApiServer(existingPolicy, true)
-	assert.NoError(t, err)
+	_, opts := fakeCompute(t)
 	fakeClient, err := FakeSecurityClient(ctx, opts)
 	assert.NoError(t, err)
 
@@ -73,8 +69,7 @@ func Test_GetPolicy(t *testing.T) {
 
 func Test_GetRule(t *testing.T) {
 	existingRulePriority := int32(0)
-	opts, err := fake.SecurityApiServer("test-2", true)
-	assert.NoError(t, err)
+	_, opts := fakeCompute(t)
 	fakeClient, err := FakeSecurityClient(ctx, opts)
 	assert.NoError(t, err)
 
@@ -85,34 +80,32 @@ func Test_GetRule(t *testing.T) {
 
 func Test_UpdatePolicy(t *testing.T) {
 	description := "test policy YOLO"
-	securityPolicy := &compute.SecurityPolicy{
+	securityPolicy := &computepb.SecurityPolicy{
 		Description: &description,
 	}
 
-	opts, err := fake.SecurityApiServer("test-2", true)
-	assert.NoError(t, err)
+	_, opts := fakeCompute(t)
 	fakeClient, err := FakeSecurityClient(ctx, opts)
 	assert.NoError(t, err)
 
 	res, err := fakeClient.UpdatePolicy(ctx, securityPolicy, "fake-project", "test-2")
 	assert.NoError(t, err)
-	assert.Equal(t, true, res)
+	assert.Equal(t, computepb.Operation_DONE, res.GetStatus())
 }
 
 func Test_UpdateRule(t *testing.T) {
 	description := "test policy YOLO"
-	securityPolicyRule := &compute.SecurityPolicyRule{
+	securityPolicyRule := &computepb.SecurityPolicyRule{
 		Description: &description,
 	}
 
-	opts, err := fake.SecurityApiServer("test-2", true)
-	assert.NoError(t, err)
+	_, opts := fakeCompute(t)
 	fakeClient, err := FakeSecurityClient(ctx, opts)
 	assert.NoError(t, err)
 
 	res, err := fakeClient.UpdateRule(ctx, securityPolicyRule, "fake-project", "test-2")
 	assert.NoError(t, err)
-	assert.Equal(t, true, res)
+	assert.Equal(t, computepb.Operation_DONE, res.GetStatus())
 }
 
 func FakeSecurityClient(ctx context.Context, opts []option.ClientOption) (*SecurityClient, error) {
@@ -120,6 +113,84 @@ func FakeSecurityClient(ctx context.Context, opts []option.ClientOption) (*Secur
 	if err != nil {
 		return nil, err
 	}
-	client := NewSecurityClient(cfg, ctx, log.WithField("component", "fake-client"), opts...)
-	return client, nil
+	return NewSecurityClient(cfg, ctx, log.WithField("component", "fake-client"), opts...)
+}
+
+func Test_UpdatePolicyStillRunningAfterDeadline(t *testing.T) {
+	compute, opts := fakeCompute(t)
+	compute.SetOperationLatency(time.Hour)
+
+	fakeClient, err := FakeSecurityClient(ctx, opts)
+	assert.NoError(t, err)
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	description := "still running"
+	_, err = fakeClient.UpdatePolicy(deadlineCtx, &computepb.SecurityPolicy{Description: &description}, "fake-project", "test-2")
+
+	var running *OperationRunningError
+	assert.ErrorAs(t, err, &running)
+	assert.NotEmpty(t, running.Name)
+	assert.Equal(t, "still running", compute.Policy("fake-project", "test-2").GetDescription(), "the change is applied while the operation runs")
+}
+
+func Test_PolicyLifecycle(t *testing.T) {
+	compute, opts := fakeCompute(t)
+	fakeClient, err := FakeSecurityClient(ctx, opts)
+	assert.NoError(t, err)
+
+	name := "new-policy"
+	_, err = fakeClient.GetPolicy(ctx, "fake-project", name)
+	assertStatus(t, http.StatusNotFound, err)
+	assert.True(t, armorerr.Is(err, armorerr.KindNotFound), "google errors are returned as armor errors")
+
+	op, err := fakeClient.CreatePolicy(ctx, &computepb.SecurityPolicy{Name: &name}, "fake-project")
+	assert.NoError(t, err)
+	assert.Equal(t, computepb.Operation_DONE, op.GetStatus())
+	_, err = fakeClient.CreatePolicy(ctx, &computepb.SecurityPolicy{Name: &name}, "fake-project")
+	assertStatus(t, http.StatusConflict, err)
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict))
+
+	policy, err := fakeClient.GetPolicy(ctx, "fake-project", name)
+	assert.NoError(t, err)
+	assert.Len(t, policy.Rules, 1, "a new policy has the default rule")
+
+	priority := int32(1)
+	rule := &computepb.SecurityPolicyRule{Priority: &priority, Action: proto.String("deny(403)")}
+	_, err = fakeClient.AddRule(ctx, rule, "fake-project", name)
+	assert.NoError(t, err)
+	_, err = fakeClient.AddRule(ctx, rule, "fake-project", name)
+	assertStatus(t, http.StatusBadRequest, err)
+
+	stale := policy.GetFingerprint()
+	_, err = fakeClient.UpdatePolicy(ctx, &computepb.SecurityPolicy{Description: proto.String("stale"), Fingerprint: &stale}, "fake-project", name)
+	assertStatus(t, http.StatusPreconditionFailed, err)
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict))
+
+	_, err = fakeClient.RemoveRule(ctx, &priority, "fake-project", name)
+	assert.NoError(t, err)
+	_, err = fakeClient.GetRule(ctx, &priority, "fake-project", name)
+	assertStatus(t, http.StatusBadRequest, err)
+
+	_, err = fakeClient.DeletePolicy(ctx, "fake-project", name)
+	assert.NoError(t, err)
+	assert.Nil(t, compute.Policy("fake-project", name))
+}
+
+// fakeCompute returns the fake Compute API seeded with the test fixtures, and options pointing clients to it.
+func fakeCompute(t *testing.T) (*fake.Compute, []option.ClientOption) {
+	compute := fake.NewCompute()
+	fixtures, err := os.ReadFile("testdata/fixtures.yaml")
+	assert.NoError(t, err)
+	assert.NoError(t, compute.LoadFixtures(fixtures))
+	return compute, compute.ClientOptions()
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	var apiErr *googleapi.Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, status, apiErr.Code)
+	}
 }
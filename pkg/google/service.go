@@ -4,28 +4,43 @@ import (
 	compute "cloud.google.com/go/compute/apiv1"
 	"context"
 	"fmt"
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/tracing"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
 )
 
+const serviceClientName = "service"
+
+var _ cloudarmor.BackendServices = &ServiceClient{}
+
 type ServiceClient struct {
-	log    *logrus.Entry
-	Client *compute.BackendServicesClient
+	log     *logrus.Entry
+	Client  *compute.BackendServicesClient
+	retrier *Retrier
 }
 
-func NewServiceClient(ctx context.Context, log *logrus.Entry) *ServiceClient {
-	c, err := compute.NewBackendServicesRESTClient(ctx)
+func NewServiceClient(cfg *config.Config, ctx context.Context, log *logrus.Entry, opts ...option.ClientOption) (*ServiceClient, error) {
+	c, err := compute.NewBackendServicesRESTClient(ctx, opts...)
 	if err != nil {
-		fmt.Printf("NewBackendServicesRESTClient: %v", err)
+		return nil, fmt.Errorf("create backend services client: %w", err)
 	}
 
 	return &ServiceClient{
-		log:    log,
-		Client: c,
-	}
+		log:     log,
+		Client:  c,
+		retrier: NewRetrier(cfg),
+	}, nil
 }
 
-func (in *ServiceClient) SetSecurityPolicy(ctx context.Context, projectID string, policy *string, backendService string) (bool, error) {
+func (in *ServiceClient) SetSecurityPolicy(ctx context.Context, projectID string, policy *string, backendService string) (*computepb.Operation, error) {
+	ctx, span := tracing.Start(ctx, "ServiceClient.SetSecurityPolicy", tracing.AttributeProject.String(projectID), tracing.AttributeBackend.String(backendService))
+	defer span.End()
+
 	req := &computepb.SetSecurityPolicyBackendServiceRequest{
 		BackendService: backendService,
 		Project:        projectID,
@@ -33,27 +48,111 @@ func (in *ServiceClient) SetSecurityPolicy(ctx context.Context, projectID string
 			SecurityPolicy: policy,
 		},
 	}
-	op, err := in.Client.SetSecurityPolicy(ctx, req)
+	var op *compute.Operation
+	err := in.retrier.Do(ctx, projectID, serviceClientName, "SetSecurityPolicy", false, func() (err error) {
+		op, err = in.Client.SetSecurityPolicy(ctx, req)
+		return err
+	})
 	if err != nil {
+		return nil, armorError(err, "insert policy to backend")
+	}
+
+	return wait(ctx, serviceClientName, "SetSecurityPolicy", projectID, "wait for backend", op)
+}
+
+// ListBackendServices returns all backend services in the project, fetching every page.
+func (in *ServiceClient) ListBackendServices(ctx context.Context, projectID string) ([]*computepb.BackendService, error) {
+	req := &computepb.ListBackendServicesRequest{
+		Project: projectID,
+	}
+
+	it := in.Client.List(ctx, req)
+	fetch := it.InternalFetch
+	it.InternalFetch = func(pageSize int, pageToken string) ([]*computepb.BackendService, string, error) {
+		ctx, span := tracing.Start(ctx, "ServiceClient.ListBackendServices", tracing.AttributeProject.String(projectID))
+		defer span.End()
+
+		var backends []*computepb.BackendService
+		var next string
+		err := in.retrier.Do(ctx, projectID, serviceClientName, "ListBackendServices", true, func() (err error) {
+			backends, next, err = fetch(pageSize, pageToken)
+			return err
+		})
+		return backends, next, err
+	}
+
+	backends := []*computepb.BackendService{}
+	for {
+		backend, err := it.Next()
+		if err == iterator.Done {
+			return backends, nil
+		}
 		if err != nil {
-			return false, fmt.Errorf("insert policy to backend: %w", err)
+			return nil, armorError(err, "list backend services")
 		}
+		backends = append(backends, backend)
 	}
+}
 
-	err = op.Wait(ctx)
+func (in *ServiceClient) ListBackendServicesPage(ctx context.Context, projectID string, opts cloudarmor.ListOptions) ([]*computepb.BackendService, string, error) {
+	ctx, span := tracing.Start(ctx, "ServiceClient.ListBackendServicesPage", tracing.AttributeProject.String(projectID))
+	defer span.End()
+
+	req := &computepb.ListBackendServicesRequest{
+		Project: projectID,
+	}
+	if opts.Filter != "" {
+		req.Filter = proto.String(opts.Filter)
+	}
+
+	var backends []*computepb.BackendService
+	var next string
+	err := in.retrier.Do(ctx, projectID, serviceClientName, "ListBackendServices", true, func() (err error) {
+		backends, next, err = in.Client.List(ctx, req).InternalFetch(opts.PageSize, opts.PageToken)
+		return err
+	})
 	if err != nil {
-		if err != nil {
-			return false, fmt.Errorf("wait for backend: %w", err)
-		}
+		return nil, "", armorError(err, "list backend services")
+	}
+	return backends, next, nil
+}
+
+func (in *ServiceClient) GetBackendService(ctx context.Context, projectID, backendService string) (*computepb.BackendService, error) {
+	ctx, span := tracing.Start(ctx, "ServiceClient.GetBackendService",
+		tracing.AttributeProject.String(projectID), tracing.AttributeBackend.String(backendService))
+	defer span.End()
+
+	req := &computepb.GetBackendServiceRequest{
+		Project:        projectID,
+		BackendService: backendService,
 	}
 
-	return op.Done(), nil
+	var result *computepb.BackendService
+	err := in.retrier.Do(ctx, projectID, serviceClientName, "GetBackendService", true, func() (err error) {
+		result, err = in.Client.Get(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, armorError(err, "get backend service")
+	}
+
+	return result, nil
 }
 
-func (in *ServiceClient) ListBackendServices(ctx context.Context, projectID string) *compute.BackendServiceIterator {
+// Probe lists at most one backend service in the project to verify that the Compute API is reachable with the current credentials.
+func (in *ServiceClient) Probe(ctx context.Context, projectID string) error {
+	ctx, span := tracing.Start(ctx, "ServiceClient.Probe", tracing.AttributeProject.String(projectID))
+	defer span.End()
+
 	req := &computepb.ListBackendServicesRequest{
-		Project: projectID,
+		Project:    projectID,
+		MaxResults: proto.Uint32(1),
 	}
 
-	return in.Client.List(ctx, req)
+	_, err := in.Client.List(ctx, req).Next()
+	if err != nil && err != iterator.Done {
+		tracing.RecordError(ctx, err)
+		return armorError(err, "probe backend services")
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/google"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+const (
+	EndpointGetAudit = "/projects/{project}/audit"
+
+	defaultAuditLimit = 100
+)
+
+func (h *Handler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	if ok, value := parse(projectID); !ok {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
+		return
+	}
+
+	limit := defaultAuditLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			h.writeError(w, r, armorerr.New(armorerr.KindParse, "invalid limit: %s", l))
+			return
+		}
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			h.writeError(w, r, armorerr.New(armorerr.KindParse, "invalid since, expected RFC3339: %s", s))
+			return
+		}
+	}
+
+	entries := []*audit.Entry{}
+	if h.auditor != nil {
+		entries = h.auditor.Recent(projectID, since, limit)
+	}
+	response(w, interface{}(entries))
+}
+
+// audit records the outcome of a mutating call, entry carries the target and the before and after state.
+func (h *Handler) audit(ctx context.Context, entry *audit.Entry, op *compute.Operation, err error) {
+	if h.auditor == nil {
+		return
+	}
+
+	entry.User = caller(ctx)
+
+	entry.OperationID = op.GetName()
+	entry.Outcome = audit.OutcomeSuccess
+	var running *google.OperationRunningError
+	switch {
+	case errors.As(err, &running):
+		entry.Outcome = audit.OutcomeRunning
+		entry.OperationID = running.Name
+	case err != nil:
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+	}
+	h.auditor.Record(entry)
+}
+
+// policySnapshot returns the current policy for the audit log, or nil if it can not be read.
+func (h *Handler) policySnapshot(ctx context.Context, projectID, policy string) interface{} {
+	resource, err := h.security(ctx).GetPolicy(ctx, projectID, policy)
+	if err != nil {
+		h.contextLog(ctx).Debugf("audit snapshot of policy %s: %v", policy, err)
+		return nil
+	}
+	return resource
+}
+
+// ruleSnapshot returns the current rule for the audit log, or nil if it can not be read.
+func (h *Handler) ruleSnapshot(ctx context.Context, projectID, policy string, priority int32) interface{} {
+	resource, err := h.security(ctx).GetRule(ctx, &priority, projectID, policy)
+	if err != nil {
+		h.contextLog(ctx).Debugf("audit snapshot of rule %d: %v", priority, err)
+		return nil
+	}
+	return resource
+}
+
+// backendSnapshot returns the security policy of the backend service for the audit log, or nil if it can not be read.
+func (h *Handler) backendSnapshot(ctx context.Context, projectID, backend string) interface{} {
+	resource, err := h.service(ctx).GetBackendService(ctx, projectID, backend)
+	if err != nil {
+		h.contextLog(ctx).Debugf("audit snapshot of backend service %s: %v", backend, err)
+		return nil
+	}
+	return &compute.SecurityPolicyReference{SecurityPolicy: resource.SecurityPolicy}
+}
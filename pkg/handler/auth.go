@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/logging"
+)
+
+const internalPathPrefix = "/internal/"
+
+// authMiddleware requires a valid bearer token on every endpoint except the internal probes and the documentation.
+func (h *Handler) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isInternal(r) || isPublic(r) || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if h.localIdentity != nil {
+			next.ServeHTTP(w, r.WithContext(h.withIdentity(r, h.localIdentity)))
+			return
+		}
+		if h.cfg.DevelopmentMode {
+			next.ServeHTTP(w, r.WithContext(h.withIdentity(r, auth.DevelopmentIdentity)))
+			return
+		}
+
+		if h.authenticator == nil {
+			h.requestLog(r).Error("authentication is not configured, rejecting request")
+			h.writeError(w, r, armorerr.New(armorerr.KindUnauthenticated, "authentication is not configured"))
+			return
+		}
+
+		identity, err := h.authenticator.Authenticate(r)
+		if err != nil {
+			h.requestLog(r).Warnf("unauthenticated request to %s: %v", r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="armor"`)
+			h.writeError(w, r, armorerr.New(armorerr.KindUnauthenticated, "unauthenticated: valid bearer token required"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(h.withIdentity(r, identity)))
+	})
+}
+
+// withIdentity adds the identity to the request context and the caller to the request log.
+func (h *Handler) withIdentity(r *http.Request, identity *auth.Identity) context.Context {
+	ctx := auth.WithIdentity(r.Context(), identity)
+	return logging.WithLogger(ctx, h.requestLog(r).WithField("user", identity.String()))
+}
+
+func isInternal(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, internalPathPrefix)
+}
+
+// authorize only calls next when the caller is granted verb in the project of the request.
+func (h *Handler) authorize(verb auth.Verb, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.authorized(w, r, mux.Vars(r)["project"], verb) {
+			next(w, r)
+		}
+	}
+}
+
+// authorized writes a forbidden response and returns false unless the caller is granted verb in the project.
+func (h *Handler) authorized(w http.ResponseWriter, r *http.Request, projectID string, verb auth.Verb) bool {
+	if err := h.access(r.Context(), projectID, verb); err != nil {
+		h.writeError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// access returns a forbidden error unless the caller of ctx is granted verb in the project.
+func (h *Handler) access(ctx context.Context, projectID string, verb auth.Verb) error {
+	if h.cfg.DevelopmentMode || h.localIdentity != nil {
+		return nil
+	}
+
+	if h.authorizer == nil {
+		h.contextLog(ctx).Error("authorization is not configured, rejecting request")
+		return armorerr.New(armorerr.KindForbidden, "forbidden: authorization is not configured")
+	}
+
+	identity, _ := auth.IdentityFromContext(ctx)
+	if err := h.authorizer.Authorize(identity, projectID, verb); err != nil {
+		h.contextLog(ctx).Warnf("unauthorized request in project %s: %v", projectID, err)
+		return armorerr.New(armorerr.KindForbidden, "forbidden: %v", err)
+	}
+	return nil
+}
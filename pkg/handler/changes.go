@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/metrics"
+	"github.com/nais/armor/pkg/model"
+	"github.com/nais/armor/pkg/operation"
+	"github.com/nais/armor/pkg/validation"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// The changes below are checked the same way for the REST and the gRPC API. Each returns the mutation making
+// the change in Google and recording it, to be run with the request context or the context of an operation.
+// ctx is the request context, carrying the caller and its Google clients.
+
+func (h *Handler) createPolicy(projectID string, resource *compute.SecurityPolicy) (operation.Mutation, error) {
+	if resource.Name == nil {
+		return nil, armorerr.New(armorerr.KindValidation, "policy name is required")
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:  "CreatePolicy",
+			Project: projectID,
+			Policy:  resource.GetName(),
+		}
+
+		op, err := h.security(ctx).CreatePolicy(ctx, resource, projectID)
+		if err == nil {
+			entry.After = h.policySnapshot(ctx, projectID, resource.GetName())
+		}
+		h.record(ctx, entry, op, err)
+		return entry.After, err
+	}, nil
+}
+
+// updatePolicy merges the fields of the request into the current policy, rules are not changed.
+func (h *Handler) updatePolicy(ctx context.Context, projectID, policy string, request *model.ArmorRequestPolicy) (operation.Mutation, error) {
+	currentPolicy, err := h.security(ctx).GetPolicy(ctx, projectID, policy)
+	if err != nil {
+		h.contextLog(ctx).Errorf("failed to get policy %s: %v", policy, err)
+		return nil, err
+	}
+
+	// MergePolicy clears the rules of the policy it merges from, the current policy is kept whole for the audit.
+	resource := compute.SecurityPolicy{}
+	if err := request.MergePolicy(&resource, proto.Clone(currentPolicy).(*compute.SecurityPolicy)); err != nil {
+		h.contextLog(ctx).Warnf("failed to merge policy: %v", err)
+		return nil, armorerr.Wrap(armorerr.KindInternal, err, "merge policy %s for project %s", policy, projectID)
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:  "UpdatePolicy",
+			Project: projectID,
+			Policy:  policy,
+			Before:  currentPolicy,
+		}
+
+		op, err := h.security(ctx).UpdatePolicy(ctx, &resource, projectID, policy)
+		if err == nil {
+			entry.After = h.policySnapshot(ctx, projectID, policy)
+		}
+		h.record(ctx, entry, op, err)
+		return entry.After, err
+	}, nil
+}
+
+func (h *Handler) deletePolicy(projectID, policy string) operation.Mutation {
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:  "DeletePolicy",
+			Project: projectID,
+			Policy:  policy,
+			Before:  h.policySnapshot(ctx, projectID, policy),
+		}
+
+		op, err := h.security(ctx).DeletePolicy(ctx, projectID, policy)
+		h.record(ctx, entry, op, err)
+		return nil, err
+	}
+}
+
+func (h *Handler) createRule(projectID, policy string, resource *compute.SecurityPolicyRule) (operation.Mutation, error) {
+	if ok, err := validation.Rule(resource); !ok {
+		return nil, armorerr.New(armorerr.KindValidation, "validation of rule: %v", err)
+	}
+
+	if h.cfg.IsProtectedRule(strconv.Itoa(int(*resource.Priority))) {
+		metrics.ProtectedRuleRejection(projectID, "CreateRule")
+		return nil, armorerr.New(armorerr.KindProtectedRule, "forbidden to create protected priority %d", *resource.Priority)
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:   "CreateRule",
+			Project:  projectID,
+			Policy:   policy,
+			Priority: resource.Priority,
+		}
+
+		op, err := h.security(ctx).AddRule(ctx, resource, projectID, policy)
+		if err == nil {
+			entry.After = h.ruleSnapshot(ctx, projectID, policy, resource.GetPriority())
+		}
+		h.record(ctx, entry, op, err)
+		return entry.After, err
+	}, nil
+}
+
+// updateRule merges the fields of the request into the current rule, unless it is protected.
+func (h *Handler) updateRule(ctx context.Context, projectID, policy string, priority int32, request *model.ArmorRequestRule) (operation.Mutation, error) {
+	currentRule, err := h.security(ctx).GetRule(ctx, &priority, projectID, policy)
+	if err != nil {
+		h.contextLog(ctx).Errorf("failed to get rule %d: %v", priority, err)
+		return nil, err
+	}
+
+	if h.cfg.IsProtectedRule(strconv.Itoa(int(priority))) {
+		metrics.ProtectedRuleRejection(projectID, "UpdateRule")
+		return nil, armorerr.New(armorerr.KindProtectedRule, "forbidden to update protected rule %d", priority)
+	}
+
+	resource := compute.SecurityPolicyRule{}
+	if err := request.MergeRule(&resource, currentRule); err != nil {
+		h.contextLog(ctx).Warnf("failed to merge rule: %v", err)
+		return nil, armorerr.Wrap(armorerr.KindInternal, err, "merge rule %d for project %s", priority, projectID)
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:   "UpdateRule",
+			Project:  projectID,
+			Policy:   policy,
+			Priority: &priority,
+			Before:   currentRule,
+		}
+
+		op, err := h.security(ctx).UpdateRule(ctx, &resource, projectID, policy)
+		if err == nil {
+			entry.After = h.ruleSnapshot(ctx, projectID, policy, priority)
+		}
+		h.record(ctx, entry, op, err)
+		return entry.After, err
+	}, nil
+}
+
+func (h *Handler) deleteRule(projectID, policy string, priority int32) (operation.Mutation, error) {
+	if h.cfg.IsProtectedRule(strconv.Itoa(int(priority))) {
+		metrics.ProtectedRuleRejection(projectID, "DeleteRule")
+		return nil, armorerr.New(armorerr.KindProtectedRule, "forbidden to delete protected rule %d", priority)
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:   "DeleteRule",
+			Project:  projectID,
+			Policy:   policy,
+			Priority: &priority,
+			Before:   h.ruleSnapshot(ctx, projectID, policy, priority),
+		}
+
+		op, err := h.security(ctx).RemoveRule(ctx, &priority, projectID, policy)
+		h.record(ctx, entry, op, err)
+		return nil, err
+	}, nil
+}
+
+// setPolicyBackend attaches the policy to the backend service by the self-link of the policy.
+func (h *Handler) setPolicyBackend(ctx context.Context, projectID, policy, backend string) (operation.Mutation, error) {
+	resource, err := h.security(ctx).GetPolicy(ctx, projectID, policy)
+	if err != nil {
+		h.contextLog(ctx).Errorf("failed to get policy %s: %v", policy, err)
+		return nil, err
+	}
+
+	return func(ctx context.Context) (interface{}, error) {
+		entry := &audit.Entry{
+			Action:  "SetPolicyBackend",
+			Project: projectID,
+			Policy:  policy,
+			Backend: backend,
+			Before:  h.backendSnapshot(ctx, projectID, backend),
+		}
+
+		op, err := h.service(ctx).SetSecurityPolicy(ctx, projectID, resource.SelfLink, backend)
+		if err == nil {
+			entry.After = h.backendSnapshot(ctx, projectID, backend)
+		}
+		h.record(ctx, entry, op, err)
+		return entry.After, err
+	}, nil
+}
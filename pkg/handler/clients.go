@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/cloudarmor"
+)
+
+type clientsContextKey struct{}
+
+type callerClients struct {
+	security cloudarmor.SecurityPolicies
+	service  cloudarmor.BackendServices
+	// token leases the clients again for operations outliving the request.
+	token string
+}
+
+// callerClientsMiddleware resolves Google clients authenticated as the caller when a client pool is configured.
+// Requests without a forwarded access token fall back to the shared clients.
+func (h *Handler) callerClientsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.clientPool == nil || isInternal(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, release, err := h.withCallerClients(r.Context(), r.Header.Get(h.cfg.CallerTokenHeader))
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withCallerClients adds the Google clients calling with the forwarded access token of the caller to ctx,
+// release is called when ctx is no longer used.
+func (h *Handler) withCallerClients(ctx context.Context, token string) (context.Context, func(), error) {
+	if token == "" {
+		h.contextLog(ctx).Debugf("no caller access token in %s, using shared credentials", h.cfg.CallerTokenHeader)
+	}
+
+	security, service, release, err := h.clientPool.Clients(token)
+	if err != nil {
+		h.contextLog(ctx).Errorf("failed to create caller clients: %v", err)
+		return nil, nil, armorerr.Wrap(armorerr.KindInternal, err, "create google clients for caller")
+	}
+	return context.WithValue(ctx, clientsContextKey{}, &callerClients{security: security, service: service, token: token}), release, nil
+}
+
+func (h *Handler) security(ctx context.Context) cloudarmor.SecurityPolicies {
+	if c, ok := ctx.Value(clientsContextKey{}).(*callerClients); ok {
+		return c.security
+	}
+	return h.securityClient
+}
+
+func (h *Handler) service(ctx context.Context) cloudarmor.BackendServices {
+	if c, ok := ctx.Value(clientsContextKey{}).(*callerClients); ok {
+		return c.service
+	}
+	return h.serviceClient
+}
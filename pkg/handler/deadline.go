@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// streamingRoutes are long-lived and ended by the client or on shutdown instead of by a deadline.
+var streamingRoutes = map[string]bool{
+	"GetEvents": true,
+}
+
+// deadlineMiddleware bounds the request context of named routes by their configured deadline,
+// every call to Google made with the request context is cancelled when it expires or the client disconnects.
+func (h *Handler) deadlineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || route.GetName() == "" || streamingRoutes[route.GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		deadline := h.cfg.Deadline(route.GetName(), r.Method != http.MethodGet)
+		if deadline <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), deadline)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
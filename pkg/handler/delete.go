@@ -1,9 +1,8 @@
 package handler
 
 import (
-	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
+	"github.com/nais/armor/pkg/armorerr"
 	"net/http"
 )
 
@@ -13,67 +12,67 @@ const (
 )
 
 func (h *Handler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "DeletePolicy",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 
 	if ok, value := parse(projectID, policy); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	if ok, err := h.securityClient.DeletePolicy(h.ctx, projectID, policy); !ok {
-		if err != nil {
-			h.log.Errorf("failed to delete policy %s: %v", policy, err)
-			h.HttpError(err, w, projectID, securityTypePolicy)
-			return
-		}
+	mutation := h.deletePolicy(projectID, policy)
+
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "DeletePolicy", mutation)
+		return
 	}
 
-	h.log.Debug("deleted policy: ", policy)
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("failed to delete policy %s: %v", policy, err)
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.requestLog(r).Debug("deleted policy: ", policy)
 	w.WriteHeader(http.StatusOK)
 	return
 }
 
 func (h *Handler) DeleteRule(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "DeleteRule",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 	priority := mux.Vars(r)["priority"]
 
 	if ok, value := parse(projectID, policy, priority); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	if h.cfg.IsProtectedRule(priority) {
-		HttpError(w, fmt.Sprintf("forbidden to delete protected rule %s", priority), http.StatusBadRequest)
+	p, err := parseInt(priority)
+	if err != nil {
+		h.requestLog(r).Errorf("failed to parse priority %s: %v", priority, err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse priority: %s", priority))
 		return
 	}
 
-	var err error
-	p, err := parseInt(priority)
+	mutation, err := h.deleteRule(projectID, policy, p)
 	if err != nil {
-		h.log.Errorf("failed to parse priority %s: %v", priority, err)
-		HttpError(w, fmt.Sprintf("parse priority: %s", priority), http.StatusInternalServerError)
+		h.writeError(w, r, err)
 		return
 	}
 
-	if ok, err := h.securityClient.RemoveRule(h.ctx, &p, projectID, policy); !ok {
-		if err != nil {
-			h.log.Errorf("failed to get rule %s: %v", priority, err)
-			h.HttpError(err, w, projectID, securityTypeRule)
-			return
-		}
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "DeleteRule", mutation)
+		return
+	}
+
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("failed to get rule %s: %v", priority, err)
+		h.writeError(w, r, err)
+		return
 	}
 
-	h.log.Debug("deleted rule: ", policy)
+	h.requestLog(r).Debug("deleted rule: ", policy)
 	w.WriteHeader(http.StatusOK)
 	return
 }
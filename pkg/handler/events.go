@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/events"
+	"github.com/nais/armor/pkg/google"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+const (
+	EndpointGetEvents = "/projects/{project}/events"
+
+	eventHistory      = 1000
+	heartbeatInterval = 15 * time.Second
+	// streamWriteTimeout bounds every write to an event stream, a client not reading is disconnected.
+	streamWriteTimeout = 2 * heartbeatInterval
+)
+
+var mutationEvents = map[string]string{
+	"CreatePolicy":     events.PolicyCreated,
+	"UpdatePolicy":     events.PolicyPatched,
+	"DeletePolicy":     events.PolicyDeleted,
+	"CreateRule":       events.RuleAdded,
+	"UpdateRule":       events.RulePatched,
+	"DeleteRule":       events.RuleRemoved,
+	"SetPolicyBackend": events.BackendAttached,
+}
+
+// GetEvents streams the events of a project as Server-Sent Events, resuming after Last-Event-ID if given.
+func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	if ok, value := parse(projectID); !ok {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeError(w, r, armorerr.New(armorerr.KindInternal, "streaming is not supported"))
+		return
+	}
+
+	// A Last-Event-ID from before armor restarted is of another epoch, and only new events are streamed.
+	subscription, missed, err := h.events.Subscribe(projectID, r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "invalid Last-Event-ID"))
+		return
+	}
+	defer h.events.Unsubscribe(subscription)
+
+	// The server write timeout bounds whole responses, so a stream instead extends the deadline before every write.
+	controller := http.NewResponseController(w)
+	extendDeadline := func() {
+		_ = controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	}
+	extendDeadline()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.streamsClosed:
+			return
+		case <-heartbeat.C:
+			extendDeadline()
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-subscription.Events():
+			if !ok {
+				h.requestLog(r).Debugf("event subscriber for %s is lagging behind, disconnecting", projectID)
+				return
+			}
+			extendDeadline()
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// eventContext publishes the lifecycle of the Google operations started with the returned context.
+func (h *Handler) eventContext(ctx context.Context) context.Context {
+	user := caller(ctx)
+	return google.WithOperationHook(ctx, func(event google.OperationEvent) {
+		e := &events.Event{
+			Type:      events.OperationStarted,
+			Project:   event.Project,
+			Method:    event.Method,
+			Operation: event.Name,
+			User:      user,
+		}
+		switch {
+		case event.Running:
+			e.Type = events.OperationRunning
+			e.Error = event.Err.Error()
+		case event.Done && event.Err != nil:
+			e.Type = events.OperationFailed
+			e.Error = event.Err.Error()
+		case event.Done:
+			e.Type = events.OperationFinished
+		}
+		h.events.Publish(e)
+	})
+}
+
+// record audits a mutation and publishes it as an event when it succeeded.
+func (h *Handler) record(ctx context.Context, entry *audit.Entry, op *compute.Operation, err error) {
+	h.audit(ctx, entry, op, err)
+
+	eventType, ok := mutationEvents[entry.Action]
+	if err != nil || !ok {
+		return
+	}
+
+	h.events.Publish(&events.Event{
+		Type:      eventType,
+		Project:   entry.Project,
+		Policy:    entry.Policy,
+		Priority:  entry.Priority,
+		Backend:   entry.Backend,
+		Operation: op.GetName(),
+		User:      caller(ctx),
+	})
+}
+
+func caller(ctx context.Context) string {
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		return identity.String()
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/events"
+	"github.com/nais/armor/pkg/fake"
+	"github.com/nais/armor/pkg/google"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_eventContextDeadline(t *testing.T) {
+	fakeCompute := fake.NewCompute()
+	fakeCompute.SetOperationLatency(time.Hour)
+	fakeCompute.AddPolicy("fake-project", &compute.SecurityPolicy{Name: proto.String("policy")})
+
+	cfg := &config.Config{}
+	entry := log.WithField("component", "test")
+	securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, fakeCompute.ClientOptions()...)
+	assert.NoError(t, err)
+	h := NewHandler(context.Background(), cfg, securityClient, nil, entry)
+
+	subscription, _, err := h.events.Subscribe("fake-project", "")
+	assert.NoError(t, err)
+	defer h.events.Unsubscribe(subscription)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = securityClient.UpdatePolicy(h.eventContext(ctx), &compute.SecurityPolicy{Description: proto.String("slow")}, "fake-project", "policy")
+	var running *google.OperationRunningError
+	assert.ErrorAs(t, err, &running)
+
+	started := <-subscription.Events()
+	assert.Equal(t, events.OperationStarted, started.Type)
+	ended := <-subscription.Events()
+	assert.Equal(t, events.OperationRunning, ended.Type, "the operation is still running when the deadline expires")
+	assert.Equal(t, running.Name, ended.Operation)
+}
+
+func Test_GetEvents(t *testing.T) {
+	h := newHarness(t)
+
+	stream := h.events(t, "")
+	status, _ := h.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules", validRule)
+	assert.Equal(t, http.StatusCreated, status)
+	added := stream.until(t, events.RuleAdded)
+	stream.close()
+
+	status, _ = h.do(http.MethodDelete, "/projects/fake-project/policies/test-policy/rules/20", "")
+	assert.Equal(t, http.StatusOK, status)
+	stream = h.events(t, added.id)
+	removed := stream.until(t, events.RuleRemoved)
+	assert.Equal(t, removed.ID, removed.id, "the SSE id is the id of the event")
+	stream.close()
+
+	stream = h.events(t, "restarted-1")
+	defer stream.close()
+	status, _ = h.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules", validRule)
+	assert.Equal(t, http.StatusCreated, status)
+	first := stream.next(t)
+	assert.Equal(t, events.OperationStarted, first.Type, "a Last-Event-ID of another epoch replays nothing")
+	assert.Greater(t, sequence(t, first.id), sequence(t, removed.id))
+
+	r, err := http.NewRequest(http.MethodGet, h.server.URL+"/projects/fake-project/events", nil)
+	assert.NoError(t, err)
+	r.Header.Set("Last-Event-ID", "20")
+	response, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode, "a Last-Event-ID without epoch is invalid")
+}
+
+func sequence(t *testing.T, id string) uint64 {
+	_, value, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseUint(value, 10, 64)
+	assert.NoError(t, err)
+	return n
+}
+
+type sseEvent struct {
+	events.Event
+	id string
+}
+
+type eventStream struct {
+	reader *bufio.Reader
+	close  func()
+}
+
+// events opens the event stream of the fake project, resuming after lastEventID if given.
+func (in *harness) events(t *testing.T, lastEventID string) *eventStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, in.server.URL+"/projects/fake-project/events", nil)
+	assert.NoError(t, err)
+	if lastEventID != "" {
+		r.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(r)
+	if !assert.NoError(t, err) {
+		cancel()
+		t.FailNow()
+	}
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	return &eventStream{
+		reader: bufio.NewReader(response.Body),
+		close: func() {
+			cancel()
+			_ = response.Body.Close()
+		},
+	}
+}
+
+// until reads the events of the stream up to the first of the given type.
+func (in *eventStream) until(t *testing.T, eventType string) *sseEvent {
+	for {
+		if event := in.next(t); event.Type == eventType {
+			return event
+		}
+	}
+}
+
+// next reads the next event of the stream, skipping comments.
+func (in *eventStream) next(t *testing.T) *sseEvent {
+	event := &sseEvent{}
+	for {
+		line, err := in.reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.Type != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Event))
+		}
+	}
+}
@@ -1,177 +1,174 @@
 package handler
 
 import (
-	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
-	"google.golang.org/api/iterator"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/cloudarmor"
 	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
 	"net/http"
+	"strconv"
 )
 
 const (
 	EndpointGetPolicy             = "/projects/{project}/policies/{policy}"
 	EndpointGetPolicies           = "/projects/{project}/policies"
 	EndpointGetRule               = "/projects/{project}/policies/{policy}/rules/{priority}"
+	EndpointGetRules              = "/projects/{project}/policies/{policy}/rules"
 	EndpointGetPreConfiguredRules = "/projects/{project}/preConfiguredRules"
 	EndpointGetBackendServices    = "/projects/{project}/backendServices"
 )
 
 func (h *Handler) GetPolicy(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "GetPolicy",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 
 	if ok, value := parse(projectID, policy); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	resource, err := h.securityClient.GetPolicy(h.ctx, projectID, policy)
+	resource, err := h.security(r.Context()).GetPolicy(r.Context(), projectID, policy)
 	if err != nil {
-		h.log.Errorf("failed to get policy %s: %v", policy, err)
-		h.HttpError(err, w, projectID, securityTypePolicy)
-		response(w, interface{}(&compute.SecurityPolicy{}))
+		h.requestLog(r).Errorf("failed to get policy %s: %v", policy, err)
+		h.writeError(w, r, err)
 		return
 	}
 
-	h.log.Debug("got policy: ", resource)
+	h.requestLog(r).Debug("got policy: ", resource)
 	response(w, interface{}(resource))
 	return
 }
 
 func (h *Handler) GetPolicies(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "GetPolicies",
-	})
-
 	projectID := mux.Vars(r)["project"]
 
 	if ok, value := parse(projectID); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	policies := []*compute.SecurityPolicy{}
-	it := h.securityClient.ListPolicies(h.ctx, projectID)
-	for {
-		resp, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err == nil {
-			policies = append(policies, resp)
-			continue
-		}
-
-		h.log.Errorf("failed to list policies %s: %v", projectID, err)
-		h.HttpError(err, w, projectID, securityTypePolicy)
-		response(w, interface{}(policies))
+	query, err := parseListQuery(r.URL.Query(), &compute.SecurityPolicy{})
+	if err != nil {
+		h.writeError(w, r, err)
 		return
 	}
 
-	h.log.Debug("got policies: ", policies)
-	response(w, interface{}(policies))
-	return
+	h.writeList(w, r, query, func(opts cloudarmor.ListOptions) ([]proto.Message, string, error) {
+		policies, next, err := h.security(r.Context()).ListPoliciesPage(r.Context(), projectID, opts)
+		items := make([]proto.Message, 0, len(policies))
+		for _, policy := range policies {
+			items = append(items, policy)
+		}
+		return items, next, err
+	})
 }
 
 func (h *Handler) GetRule(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "GetRule",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 	priority := mux.Vars(r)["priority"]
 
 	if ok, value := parse(projectID, policy, priority); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
 	p, err := parseInt(priority)
 	if err != nil {
-		h.log.Errorf("failed to parse priority %s: %v", priority, err)
-		HttpError(w, fmt.Sprintf("parse priority: %s", priority), http.StatusInternalServerError)
+		h.requestLog(r).Errorf("failed to parse priority %s: %v", priority, err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse priority: %s", priority))
 		return
 	}
 
-	resource, err := h.securityClient.GetRule(h.ctx, &p, projectID, policy)
+	resource, err := h.security(r.Context()).GetRule(r.Context(), &p, projectID, policy)
 	if err != nil {
-		h.log.Errorf("failed to get rule %s: %v", policy, err)
-		h.HttpError(err, w, projectID, securityTypeRule)
-		response(w, interface{}(&compute.SecurityPolicyRule{}))
+		h.requestLog(r).Errorf("failed to get rule %s: %v", policy, err)
+		h.writeError(w, r, err)
 		return
 	}
 
-	h.log.Debug("got rule: ", resource)
+	h.requestLog(r).Debug("got rule: ", resource)
 	response(w, interface{}(resource))
 	return
 }
 
-func (h *Handler) GetPreConfiguredRules(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "GetPreConfiguredRules",
-	})
+// GetRules lists the rules of a policy matching the filters of the query, see parseRuleQuery, with the number of
+// matching rules in the X-Total-Count header.
+func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["project"]
+	policy := mux.Vars(r)["policy"]
+
+	if ok, value := parse(projectID, policy); !ok {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
+		return
+	}
+
+	query, err := parseRuleQuery(r.URL.Query())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	resource, err := h.security(r.Context()).GetPolicy(r.Context(), projectID, policy)
+	if err != nil {
+		h.requestLog(r).Errorf("failed to get policy %s: %v", policy, err)
+		h.writeError(w, r, err)
+		return
+	}
+
+	page := query.apply(resource.GetRules())
+	h.requestLog(r).Debugf("got %d of %d matching rules", len(page.items), page.total)
+	setPageHeaders(w, page.next)
+	w.Header().Set(headerTotalCount, strconv.Itoa(page.total))
+	response(w, interface{}(page.items))
+}
 
+func (h *Handler) GetPreConfiguredRules(w http.ResponseWriter, r *http.Request) {
 	projectID := mux.Vars(r)["project"]
 	ruleType := r.URL.Query().Get("rule-type")
 	version := r.URL.Query().Get("version")
 
 	if ok, value := parse(projectID, ruleType, version); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	resource, err := h.securityClient.ListPreConfiguredRules(h.ctx, projectID)
+	resource, err := h.security(r.Context()).ListPreConfiguredRules(r.Context(), projectID)
 	var filteredResponse []*compute.WafExpressionSet
 	if err != nil {
-		h.log.Errorf("failed to pre configured rules for %s: %v", projectID, err)
-		h.HttpError(err, w, projectID, securityTypeRule)
-		response(w, interface{}(filteredResponse))
+		h.requestLog(r).Errorf("failed to pre configured rules for %s: %v", projectID, err)
+		h.writeError(w, r, err)
 		return
 	}
 
-	h.log.Debug("got pre configured rules: ", resource)
+	h.requestLog(r).Debug("got pre configured rules: ", resource)
 
-	filteredResponse = filterResult(ruleType, version, resource.GetPreconfiguredExpressionSets().GetWafRules().GetExpressionSets())
+	filteredResponse = filterResult(ruleType, version, resource)
 	response(w, interface{}(filteredResponse))
 	return
 }
 
 func (h *Handler) GetBackendServices(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "GetBackendServices",
-	})
-
 	projectID := mux.Vars(r)["project"]
 
 	if ok, value := parse(projectID); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	backends := []*compute.BackendService{}
-	it := h.serviceClient.ListBackendServices(h.ctx, projectID)
-	for {
-		resp, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err == nil {
-			backends = append(backends, resp)
-			continue
-		}
-
-		h.log.Errorf("failed to list backend services %s: %v", projectID, err)
-		h.HttpError(err, w, projectID, securityTypePolicy)
-		response(w, interface{}(backends))
+	query, err := parseListQuery(r.URL.Query(), &compute.BackendService{})
+	if err != nil {
+		h.writeError(w, r, err)
 		return
 	}
 
-	h.log.Debug("got backend services: ", backends)
-	response(w, interface{}(backends))
+	h.writeList(w, r, query, func(opts cloudarmor.ListOptions) ([]proto.Message, string, error) {
+		backends, next, err := h.service(r.Context()).ListBackendServicesPage(r.Context(), projectID, opts)
+		items := make([]proto.Message, 0, len(backends))
+		for _, backend := range backends {
+			items = append(items, backend)
+		}
+		return items, next, err
+	})
 }
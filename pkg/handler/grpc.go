@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/armorpb"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/logging"
+	"github.com/nais/armor/pkg/metrics"
+	"github.com/nais/armor/pkg/tracing"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// GRPCServiceName is the name of the service described by proto/armor/v1/armor.proto.
+	GRPCServiceName = "armor.v1.Armor"
+
+	errorDomain = "armor"
+)
+
+// problemCodes translate the status of a problem to the code of a gRPC status.
+var problemCodes = map[int]codes.Code{
+	http.StatusAccepted:              codes.DeadlineExceeded,
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.Aborted,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// SetupGRPCServer serves the gRPC API of armor with the health service and reflection. The methods are named like
+// the routes of SetupHttpRouter, so route deadlines apply to both.
+func SetupGRPCServer(h *Handler, healthServer *health.Server, opts ...grpc.ServerOption) *grpc.Server {
+	if h.cfg.MaxBodyBytes > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(h.cfg.MaxBodyBytes)))
+	}
+	s := grpc.NewServer(append(opts, grpc.UnaryInterceptor(h.unaryInterceptor))...)
+	armorpb.RegisterArmorServer(s, &grpcService{Handler: h})
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	healthServer.SetServingStatus(GRPCServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	reflection.Register(s)
+	return s
+}
+
+// unaryInterceptor does for gRPC what the middlewares of SetupHttpRouter do for REST: it traces, logs and measures
+// the request, authenticates and authorizes the caller, resolves the Google clients of the caller and bounds the
+// request by the deadline of its method. Errors are returned as a gRPC status with the code of their problem.
+// Methods of the service missing from grpcMethods and requests without a project are rejected.
+func (h *Handler) unaryInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+	if path.Dir(info.FullMethod) != "/"+GRPCServiceName {
+		// The health service and reflection are public, like the internal probes.
+		return handler(ctx, request)
+	}
+	name := path.Base(info.FullMethod)
+	method, known := grpcMethods[name]
+	start := time.Now()
+
+	header := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	ctx = tracing.Extract(ctx, header)
+	attributes := append([]attribute.KeyValue{
+		semconv.RPCSystemKey.String("grpc"),
+		semconv.RPCServiceKey.String(GRPCServiceName),
+		semconv.RPCMethodKey.String(name),
+	}, requestAttributes(request)...)
+	ctx, span := tracing.StartServer(ctx, name, attributes...)
+
+	traceID := traceIDFrom(ctx, header)
+	id := requestIDFrom(header, traceID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(headerRequestID, id))
+	fields := logrus.Fields{
+		"request-id":  id,
+		"grpc-method": name,
+		"route":       name,
+	}
+	if traceID != "" {
+		fields["trace-id"] = traceID
+	}
+	for key, value := range requestFields(request) {
+		fields[key] = value
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	ctx = logging.WithLogger(ctx, h.log.WithFields(fields))
+
+	defer func() {
+		if err != nil {
+			err = h.grpcStatus(ctx, err)
+		}
+		code := status.Code(err)
+		if code != codes.OK {
+			span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		}
+		tracing.End(span, err)
+		metrics.GrpcRequest(name, code.String(), start)
+	}()
+
+	authenticated, err := h.grpcAuthenticate(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+	ctx = authenticated
+	if !known {
+		h.contextLog(ctx).Errorf("gRPC method %s is not authorized, rejecting request", name)
+		return nil, armorerr.New(armorerr.KindForbidden, "forbidden: method %s is not authorized", name)
+	}
+	project := method.project(request)
+	if project == "" {
+		return nil, armorerr.New(armorerr.KindParse, "project is required")
+	}
+	if err := h.access(ctx, project, method.verb); err != nil {
+		return nil, err
+	}
+	if h.clientPool != nil {
+		clients, release, err := h.withCallerClients(ctx, header.Get(h.cfg.CallerTokenHeader))
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		ctx = clients
+	}
+
+	if deadline := h.cfg.Deadline(name, method.verb != auth.VerbRead); deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+	return handler(ctx, request)
+}
+
+// grpcAuthenticate adds the identity of the bearer token in the authorization metadata to ctx.
+func (h *Handler) grpcAuthenticate(ctx context.Context, header http.Header) (context.Context, error) {
+	if h.localIdentity != nil {
+		return h.withContextIdentity(ctx, h.localIdentity), nil
+	}
+	if h.cfg.DevelopmentMode {
+		return h.withContextIdentity(ctx, auth.DevelopmentIdentity), nil
+	}
+
+	if h.authenticator == nil {
+		h.contextLog(ctx).Error("authentication is not configured, rejecting request")
+		return nil, armorerr.New(armorerr.KindUnauthenticated, "authentication is not configured")
+	}
+
+	token, err := auth.BearerToken(header.Get("Authorization"))
+	if err == nil {
+		var identity *auth.Identity
+		if identity, err = h.authenticator.Verify(ctx, token); err == nil {
+			return h.withContextIdentity(ctx, identity), nil
+		}
+	}
+	h.contextLog(ctx).Warnf("unauthenticated gRPC request: %v", err)
+	return nil, armorerr.New(armorerr.KindUnauthenticated, "unauthenticated: valid bearer token required")
+}
+
+// grpcStatus translates err into a gRPC status, with the problem type and request id as error info.
+func (h *Handler) grpcStatus(ctx context.Context, err error) error {
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		// Errors of gRPC itself, e.g. a request that could not be decoded.
+		return err
+	}
+
+	problem := newProblem(err)
+	if problem.Status >= http.StatusInternalServerError {
+		h.contextLog(ctx).Errorf("gRPC request failed: %v", err)
+	}
+
+	code, ok := problemCodes[problem.Status]
+	if !ok {
+		code = codes.Internal
+	}
+	message := problem.Detail
+	if message == "" {
+		message = problem.Title
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason:   strings.TrimPrefix(problem.Type, problemTypePrefix),
+		Domain:   errorDomain,
+		Metadata: map[string]string{"request-id": contextRequestID(ctx)},
+	}
+	if problem.GoogleOperation != "" {
+		info.Metadata["google-operation"] = problem.GoogleOperation
+	}
+	s, detailsErr := status.New(code, message).WithDetails(info)
+	if detailsErr != nil {
+		return status.Error(code, message)
+	}
+	return s.Err()
+}
+
+// withContextIdentity adds the identity to ctx and the caller to the log of ctx.
+func (h *Handler) withContextIdentity(ctx context.Context, identity *auth.Identity) context.Context {
+	ctx = auth.WithIdentity(ctx, identity)
+	return logging.WithLogger(ctx, h.contextLog(ctx).WithField("user", identity.String()))
+}
+
+// requestFields are the log fields of the resource a request is about, like the route variables of a REST request.
+func requestFields(request interface{}) logrus.Fields {
+	fields := logrus.Fields{}
+	if r, ok := request.(interface{ GetProject() string }); ok {
+		fields["project"] = r.GetProject()
+	}
+	if r, ok := request.(interface{ GetSecurityPolicy() string }); ok {
+		fields["policy"] = r.GetSecurityPolicy()
+	}
+	if r, ok := request.(interface{ GetPriority() int32 }); ok {
+		fields["priority"] = r.GetPriority()
+	}
+	if r, ok := request.(interface{ GetBackendService() string }); ok {
+		fields["backend"] = r.GetBackendService()
+	}
+	return fields
+}
+
+func requestAttributes(request interface{}) []attribute.KeyValue {
+	var attributes []attribute.KeyValue
+	for key, value := range requestFields(request) {
+		switch key {
+		case "project":
+			attributes = append(attributes, tracing.AttributeProject.String(value.(string)))
+		case "policy":
+			attributes = append(attributes, tracing.AttributePolicy.String(value.(string)))
+		case "priority":
+			attributes = append(attributes, tracing.AttributePriority.Int64(int64(value.(int32))))
+		case "backend":
+			attributes = append(attributes, tracing.AttributeBackend.String(value.(string)))
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/armorpb"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/model"
+	"github.com/nais/armor/pkg/operation"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcService implements the methods of the gRPC API with the compute messages of the matching Compute API calls.
+type grpcService struct {
+	armorpb.UnimplementedArmorServer
+	*Handler
+}
+
+// grpcMethod authorizes a method of the service: the caller must be granted verb in the project of the request.
+type grpcMethod struct {
+	verb    auth.Verb
+	project func(request interface{}) string
+}
+
+// grpcMethods authorize every method of the service by name, methods missing here are denied.
+var grpcMethods = map[string]grpcMethod{
+	"GetPolicies": {verb: auth.VerbRead, project: func(request interface{}) string {
+		r, _ := request.(*compute.ListSecurityPoliciesRequest)
+		return r.GetProject()
+	}},
+	"GetPolicy": {verb: auth.VerbRead, project: func(request interface{}) string {
+		r, _ := request.(*compute.GetSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"CreatePolicy": {verb: auth.VerbWritePolicies, project: func(request interface{}) string {
+		r, _ := request.(*compute.InsertSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"UpdatePolicy": {verb: auth.VerbWritePolicies, project: func(request interface{}) string {
+		r, _ := request.(*compute.PatchSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"DeletePolicy": {verb: auth.VerbWritePolicies, project: func(request interface{}) string {
+		r, _ := request.(*compute.DeleteSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"GetRule": {verb: auth.VerbRead, project: func(request interface{}) string {
+		r, _ := request.(*compute.GetRuleSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"CreateRule": {verb: auth.VerbWriteRules, project: func(request interface{}) string {
+		r, _ := request.(*compute.AddRuleSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"UpdateRule": {verb: auth.VerbWriteRules, project: func(request interface{}) string {
+		r, _ := request.(*compute.PatchRuleSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"DeleteRule": {verb: auth.VerbWriteRules, project: func(request interface{}) string {
+		r, _ := request.(*compute.RemoveRuleSecurityPolicyRequest)
+		return r.GetProject()
+	}},
+	"GetPreConfiguredRules": {verb: auth.VerbRead, project: func(request interface{}) string {
+		r, _ := request.(*compute.ListPreconfiguredExpressionSetsSecurityPoliciesRequest)
+		return r.GetProject()
+	}},
+	"GetBackendServices": {verb: auth.VerbRead, project: func(request interface{}) string {
+		r, _ := request.(*compute.ListBackendServicesRequest)
+		return r.GetProject()
+	}},
+	"SetPolicyBackend": {verb: auth.VerbAttachBackends, project: func(request interface{}) string {
+		r, _ := request.(*compute.SetSecurityPolicyBackendServiceRequest)
+		return r.GetProject()
+	}},
+}
+
+func (s *grpcService) GetPolicies(ctx context.Context, request *compute.ListSecurityPoliciesRequest) (*compute.SecurityPolicyList, error) {
+	if err := parseFields(request.GetProject()); err != nil {
+		return nil, err
+	}
+
+	opts, err := grpcListOptions(request)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &compute.SecurityPolicyList{}
+	for {
+		policies, next, err := s.security(ctx).ListPoliciesPage(ctx, request.GetProject(), opts)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, policies...)
+		if opts.PageSize > 0 || next == "" {
+			if next != "" {
+				list.NextPageToken = proto.String(next)
+			}
+			return list, nil
+		}
+		opts.PageToken = next
+	}
+}
+
+func (s *grpcService) GetPolicy(ctx context.Context, request *compute.GetSecurityPolicyRequest) (*compute.SecurityPolicy, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	return s.security(ctx).GetPolicy(ctx, request.GetProject(), request.GetSecurityPolicy())
+}
+
+func (s *grpcService) CreatePolicy(ctx context.Context, request *compute.InsertSecurityPolicyRequest) (*emptypb.Empty, error) {
+	if err := parseFields(request.GetProject()); err != nil {
+		return nil, err
+	}
+	if request.GetSecurityPolicyResource() == nil {
+		return nil, armorerr.New(armorerr.KindParse, "security_policy_resource is required")
+	}
+
+	mutation, err := s.createPolicy(request.GetProject(), request.GetSecurityPolicyResource())
+	return s.run(ctx, mutation, err)
+}
+
+func (s *grpcService) UpdatePolicy(ctx context.Context, request *compute.PatchSecurityPolicyRequest) (*emptypb.Empty, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	if request.GetSecurityPolicyResource() == nil {
+		return nil, armorerr.New(armorerr.KindParse, "security_policy_resource is required")
+	}
+
+	patch := &model.ArmorRequestPolicy{SecurityPolicy: request.GetSecurityPolicyResource()}
+	mutation, err := s.updatePolicy(ctx, request.GetProject(), request.GetSecurityPolicy(), patch)
+	return s.run(ctx, mutation, err)
+}
+
+func (s *grpcService) DeletePolicy(ctx context.Context, request *compute.DeleteSecurityPolicyRequest) (*emptypb.Empty, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	return s.run(ctx, s.deletePolicy(request.GetProject(), request.GetSecurityPolicy()), nil)
+}
+
+func (s *grpcService) GetRule(ctx context.Context, request *compute.GetRuleSecurityPolicyRequest) (*compute.SecurityPolicyRule, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	if request.Priority == nil {
+		return nil, armorerr.New(armorerr.KindParse, "priority is required")
+	}
+	return s.security(ctx).GetRule(ctx, request.Priority, request.GetProject(), request.GetSecurityPolicy())
+}
+
+func (s *grpcService) CreateRule(ctx context.Context, request *compute.AddRuleSecurityPolicyRequest) (*emptypb.Empty, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	if request.GetSecurityPolicyRuleResource() == nil {
+		return nil, armorerr.New(armorerr.KindParse, "security_policy_rule_resource is required")
+	}
+
+	mutation, err := s.createRule(request.GetProject(), request.GetSecurityPolicy(), request.GetSecurityPolicyRuleResource())
+	return s.run(ctx, mutation, err)
+}
+
+func (s *grpcService) UpdateRule(ctx context.Context, request *compute.PatchRuleSecurityPolicyRequest) (*emptypb.Empty, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	if request.Priority == nil || request.GetSecurityPolicyRuleResource() == nil {
+		return nil, armorerr.New(armorerr.KindParse, "priority and security_policy_rule_resource are required")
+	}
+
+	patch := &model.ArmorRequestRule{SecurityPolicyRule: request.GetSecurityPolicyRuleResource()}
+	mutation, err := s.updateRule(ctx, request.GetProject(), request.GetSecurityPolicy(), request.GetPriority(), patch)
+	return s.run(ctx, mutation, err)
+}
+
+func (s *grpcService) DeleteRule(ctx context.Context, request *compute.RemoveRuleSecurityPolicyRequest) (*emptypb.Empty, error) {
+	if err := parseFields(request.GetProject(), request.GetSecurityPolicy()); err != nil {
+		return nil, err
+	}
+	if request.Priority == nil {
+		return nil, armorerr.New(armorerr.KindParse, "priority is required")
+	}
+
+	mutation, err := s.deleteRule(request.GetProject(), request.GetSecurityPolicy(), request.GetPriority())
+	return s.run(ctx, mutation, err)
+}
+
+// GetPreConfiguredRules lists the preconfigured expression sets, the filter keeps the sets with ids containing it,
+// e.g. sqli-v33.
+func (s *grpcService) GetPreConfiguredRules(ctx context.Context, request *compute.ListPreconfiguredExpressionSetsSecurityPoliciesRequest) (*compute.SecurityPoliciesListPreconfiguredExpressionSetsResponse, error) {
+	if err := parseFields(request.GetProject()); err != nil {
+		return nil, err
+	}
+
+	sets, err := s.security(ctx).ListPreConfiguredRules(ctx, request.GetProject())
+	if err != nil {
+		return nil, err
+	}
+	var filtered []*compute.WafExpressionSet
+	for _, set := range sets {
+		if strings.Contains(set.GetId(), request.GetFilter()) {
+			filtered = append(filtered, set)
+		}
+	}
+	return &compute.SecurityPoliciesListPreconfiguredExpressionSetsResponse{
+		PreconfiguredExpressionSets: &compute.SecurityPoliciesWafConfig{
+			WafRules: &compute.PreconfiguredWafSet{ExpressionSets: filtered},
+		},
+	}, nil
+}
+
+func (s *grpcService) GetBackendServices(ctx context.Context, request *compute.ListBackendServicesRequest) (*compute.BackendServiceList, error) {
+	if err := parseFields(request.GetProject()); err != nil {
+		return nil, err
+	}
+
+	opts, err := grpcListOptions(request)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &compute.BackendServiceList{}
+	for {
+		backends, next, err := s.service(ctx).ListBackendServicesPage(ctx, request.GetProject(), opts)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, backends...)
+		if opts.PageSize > 0 || next == "" {
+			if next != "" {
+				list.NextPageToken = proto.String(next)
+			}
+			return list, nil
+		}
+		opts.PageToken = next
+	}
+}
+
+// listRequest is a list request of the Compute API.
+type listRequest interface {
+	GetFilter() string
+	GetMaxResults() uint32
+	GetPageToken() string
+}
+
+// grpcListOptions selects the page of a list given by max_results and page_token, or every page when max_results is
+// not set, like parseListQuery.
+func grpcListOptions(request listRequest) (cloudarmor.ListOptions, error) {
+	if request.GetMaxResults() > maxListPageSize {
+		return cloudarmor.ListOptions{}, armorerr.New(armorerr.KindParse, "invalid max_results, expected at most %d: %d", maxListPageSize, request.GetMaxResults())
+	}
+	if request.GetPageToken() != "" && request.GetMaxResults() == 0 {
+		return cloudarmor.ListOptions{}, armorerr.New(armorerr.KindParse, "page_token requires max_results")
+	}
+	return cloudarmor.ListOptions{
+		Filter:    request.GetFilter(),
+		PageSize:  int(request.GetMaxResults()),
+		PageToken: request.GetPageToken(),
+	}, nil
+}
+
+// SetPolicyBackend attaches the policy named in the security policy reference to the backend service.
+func (s *grpcService) SetPolicyBackend(ctx context.Context, request *compute.SetSecurityPolicyBackendServiceRequest) (*emptypb.Empty, error) {
+	policy := request.GetSecurityPolicyReferenceResource().GetSecurityPolicy()
+	if err := parseFields(request.GetProject(), request.GetBackendService(), policy); err != nil {
+		return nil, err
+	}
+	if policy == "" {
+		return nil, armorerr.New(armorerr.KindParse, "security_policy_reference_resource.security_policy is required")
+	}
+
+	mutation, err := s.setPolicyBackend(ctx, request.GetProject(), policy, request.GetBackendService())
+	return s.run(ctx, mutation, err)
+}
+
+// run runs a checked mutation for the duration of the request, gRPC callers wait for the change to finish.
+func (s *grpcService) run(ctx context.Context, mutation operation.Mutation, err error) (*emptypb.Empty, error) {
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mutation(s.eventContext(ctx)); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// parseFields rejects the names in a request that parse would reject in the path of a REST request.
+func parseFields(fields ...string) error {
+	if ok, value := parse(fields...); !ok {
+		return armorerr.New(armorerr.KindParse, "unknown parameter: %s", value)
+	}
+	return nil
+}
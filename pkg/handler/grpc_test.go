@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/armorpb"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/memory"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcConn serves the gRPC API of a handler calling an in-memory Cloud Armor with a policy and a backend service.
+func grpcConn(t *testing.T, cfg *config.Config) (*grpc.ClientConn, *audit.Auditor) {
+	c := memory.New()
+	c.AddPolicy(project, &compute.SecurityPolicy{Name: proto.String("policy")})
+	c.AddBackend(project, &compute.BackendService{Name: proto.String("backend")})
+	c.SetPreconfiguredExpressionSets([]*compute.WafExpressionSet{{Id: proto.String("sqli-v33-stable")}, {Id: proto.String("xss-v33-stable")}})
+
+	entry := log.WithField("component", "test")
+	auditor := audit.New(audit.NewWriterSink(io.Discard), 100, entry)
+	h := NewHandler(context.Background(), cfg, c, c, entry, WithAuditor(auditor))
+	s := SetupGRPCServer(h, health.NewServer())
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = s.Serve(listener) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, auditor
+}
+
+func invoke(conn *grpc.ClientConn, method string, request, response proto.Message, opts ...grpc.CallOption) error {
+	return conn.Invoke(context.Background(), "/"+GRPCServiceName+"/"+method, request, response, opts...)
+}
+
+func Test_grpc(t *testing.T) {
+	conn, auditor := grpcConn(t, &config.Config{
+		DevelopmentMode: true,
+		ProtectedRules:  []string{"1000", "2147483647"},
+		WriteDeadline:   5 * time.Second,
+	})
+
+	var header metadata.MD
+	err := invoke(conn, "CreateRule", &compute.AddRuleSecurityPolicyRequest{
+		Project:        project,
+		SecurityPolicy: "policy",
+		SecurityPolicyRuleResource: &compute.SecurityPolicyRule{
+			Priority: proto.Int32(20),
+			Action:   proto.String("deny(403)"),
+			Preview:  proto.Bool(false),
+			Match: &compute.SecurityPolicyRuleMatcher{
+				VersionedExpr: proto.String("SRC_IPS_V1"),
+				Config:        &compute.SecurityPolicyRuleMatcherConfig{SrcIpRanges: []string{"203.0.113.0/24"}},
+			},
+		},
+	}, &emptypb.Empty{}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, header.Get(headerRequestID))
+
+	err = invoke(conn, "UpdateRule", &compute.PatchRuleSecurityPolicyRequest{
+		Project:                    project,
+		SecurityPolicy:             "policy",
+		Priority:                   proto.Int32(20),
+		SecurityPolicyRuleResource: &compute.SecurityPolicyRule{Description: proto.String("Patched over gRPC")},
+	}, &emptypb.Empty{})
+	assert.NoError(t, err)
+
+	rule := &compute.SecurityPolicyRule{}
+	assert.NoError(t, invoke(conn, "GetRule", &compute.GetRuleSecurityPolicyRequest{Project: project, SecurityPolicy: "policy", Priority: proto.Int32(20)}, rule))
+	assert.Equal(t, "Patched over gRPC", rule.GetDescription())
+	assert.Equal(t, "deny(403)", rule.GetAction(), "a patch keeps the fields not given")
+
+	assert.NoError(t, invoke(conn, "SetPolicyBackend", &compute.SetSecurityPolicyBackendServiceRequest{
+		Project:                         project,
+		BackendService:                  "backend",
+		SecurityPolicyReferenceResource: &compute.SecurityPolicyReference{SecurityPolicy: proto.String("policy")},
+	}, &emptypb.Empty{}))
+	backends := &compute.BackendServiceList{}
+	assert.NoError(t, invoke(conn, "GetBackendServices", &compute.ListBackendServicesRequest{Project: project}, backends))
+	if assert.Len(t, backends.GetItems(), 1) {
+		assert.Contains(t, backends.GetItems()[0].GetSecurityPolicy(), "policy")
+	}
+
+	assert.NoError(t, invoke(conn, "GetBackendServices", &compute.ListBackendServicesRequest{Project: project, Filter: proto.String(`name != "backend"`)}, backends))
+	assert.Empty(t, backends.GetItems(), "lists are filtered")
+	err = invoke(conn, "GetPolicies", &compute.ListSecurityPoliciesRequest{Project: project, MaxResults: proto.Uint32(501)}, &compute.SecurityPolicyList{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	sets := &compute.SecurityPoliciesListPreconfiguredExpressionSetsResponse{}
+	filter := &compute.ListPreconfiguredExpressionSetsSecurityPoliciesRequest{Project: project, Filter: proto.String("sqli")}
+	assert.NoError(t, invoke(conn, "GetPreConfiguredRules", filter, sets))
+	assert.Len(t, sets.GetPreconfiguredExpressionSets().GetWafRules().GetExpressionSets(), 1)
+
+	actions := []string{}
+	for _, entry := range auditor.Recent(project, time.Time{}, 10) {
+		actions = append(actions, entry.Action)
+		assert.Equal(t, "development", entry.User)
+	}
+	assert.ElementsMatch(t, []string{"CreateRule", "UpdateRule", "SetPolicyBackend"}, actions, "changes over gRPC are audited")
+}
+
+func Test_grpcErrors(t *testing.T) {
+	conn, _ := grpcConn(t, &config.Config{DevelopmentMode: true, ProtectedRules: []string{"1000"}})
+
+	for _, test := range []struct {
+		name     string
+		method   string
+		request  proto.Message
+		response proto.Message
+		code     codes.Code
+		reason   string
+	}{
+		{
+			name:     "Missing policy",
+			method:   "GetPolicy",
+			request:  &compute.GetSecurityPolicyRequest{Project: project, SecurityPolicy: "missing"},
+			response: &compute.SecurityPolicy{},
+			code:     codes.NotFound,
+			reason:   "not-found",
+		},
+		{
+			name:     "Protected rule",
+			method:   "DeleteRule",
+			request:  &compute.RemoveRuleSecurityPolicyRequest{Project: project, SecurityPolicy: "policy", Priority: proto.Int32(1000)},
+			response: &emptypb.Empty{},
+			code:     codes.InvalidArgument,
+			reason:   "protected-rule",
+		},
+		{
+			name:   "Invalid rule",
+			method: "CreateRule",
+			request: &compute.AddRuleSecurityPolicyRequest{Project: project, SecurityPolicy: "policy",
+				SecurityPolicyRuleResource: &compute.SecurityPolicyRule{Priority: proto.Int32(20), Action: proto.String("allow")}},
+			response: &emptypb.Empty{},
+			code:     codes.InvalidArgument,
+			reason:   "validation",
+		},
+		{
+			name:     "Missing project",
+			method:   "GetPolicy",
+			request:  &compute.GetSecurityPolicyRequest{SecurityPolicy: "policy"},
+			response: &compute.SecurityPolicy{},
+			code:     codes.InvalidArgument,
+			reason:   "parse",
+		},
+		{
+			name:     "Invalid project",
+			method:   "GetPolicies",
+			request:  &compute.ListSecurityPoliciesRequest{Project: "not a project"},
+			response: &compute.SecurityPolicyList{},
+			code:     codes.InvalidArgument,
+			reason:   "parse",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := invoke(conn, test.method, test.request, test.response)
+			s := status.Convert(err)
+			assert.Equal(t, test.code, s.Code())
+			if assert.Len(t, s.Details(), 1) {
+				info := s.Details()[0].(*errdetails.ErrorInfo)
+				assert.Equal(t, test.reason, info.GetReason())
+				assert.NotEmpty(t, info.GetMetadata()["request-id"])
+			}
+		})
+	}
+}
+
+func Test_grpcMethods(t *testing.T) {
+	for _, method := range armorpb.Armor_ServiceDesc.Methods {
+		assert.Contains(t, grpcMethods, method.MethodName, "every method of the service is authorized")
+	}
+
+	h := NewHandler(context.Background(), &config.Config{DevelopmentMode: true}, nil, nil, log.WithField("component", "test"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + GRPCServiceName + "/Unknown"}
+	_, err := h.unaryInterceptor(context.Background(), &emptypb.Empty{}, info, func(context.Context, interface{}) (interface{}, error) {
+		t.Error("unknown methods are not handled")
+		return nil, nil
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "unknown methods are denied")
+}
+
+func Test_grpcAuthentication(t *testing.T) {
+	conn, _ := grpcConn(t, &config.Config{})
+
+	err := invoke(conn, "GetPolicies", &compute.ListSecurityPoliciesRequest{Project: project}, &compute.SecurityPolicyList{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	response, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: GRPCServiceName})
+	assert.NoError(t, err, "the health service is public")
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.GetStatus())
+}
+
+func Test_grpcReflection(t *testing.T) {
+	conn, _ := grpcConn(t, &config.Config{DevelopmentMode: true})
+
+	stream, err := grpc_reflection_v1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: GRPCServiceName},
+	}))
+	response, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.GetFileDescriptorResponse().GetFileDescriptorProto(), "the service is described with the compute messages")
+}
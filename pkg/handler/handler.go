@@ -2,78 +2,105 @@ package handler
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"github.com/nais/armor/config"
-	"github.com/nais/armor/pkg/google"
-	"github.com/nais/armor/pkg/model"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/events"
+	"github.com/nais/armor/pkg/operation"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/api/googleapi"
-	"google.golang.org/genproto/googleapis/cloud/compute/v1"
-	"net/http"
+	"sync"
 )
 
 type Handler struct {
 	ctx            context.Context
 	log            *logrus.Entry
 	cfg            *config.Config
-	securityClient *google.SecurityClient
-	serviceClient  *google.ServiceClient
+	securityClient cloudarmor.SecurityPolicies
+	serviceClient  cloudarmor.BackendServices
+	authenticator  *auth.Authenticator
+	authorizer     *auth.Authorizer
+	localIdentity  *auth.Identity
+	clientPool     ClientPool
+	auditor        *audit.Auditor
+	readiness      readiness
+	operations     *operation.Tracker
+	events         *events.Broker
+	streamsClosed  chan struct{}
+	closeStreams   sync.Once
 }
 
-const (
-	securityTypePolicy = "policy"
-	securityTypeRule   = "rule"
-)
+type Option func(h *Handler)
 
-func NewHandler(ctx context.Context, cfg *config.Config, securityClient *google.SecurityClient, serviceClient *google.ServiceClient, log *logrus.Entry) *Handler {
-	return &Handler{
-		log:            log.WithField("subsystem", "handler"),
-		securityClient: securityClient,
-		serviceClient:  serviceClient,
-		ctx:            ctx,
-		cfg:            cfg,
+// ClientPool hands out clients calling Google with the forwarded access token of the caller,
+// or the shared clients when no token is given. The clients may be closed once release is called.
+type ClientPool interface {
+	Clients(accessToken string) (security cloudarmor.SecurityPolicies, service cloudarmor.BackendServices, release func(), err error)
+}
+
+func WithAuthenticator(authenticator *auth.Authenticator) Option {
+	return func(h *Handler) {
+		h.authenticator = authenticator
 	}
 }
 
-func (h *Handler) createPolicy(request *model.ArmorRequestPolicy, projectID string) (*compute.SecurityPolicy, error) {
-	parsedPolicy, err := request.ParsePolicy()
-	if err != nil {
-		return nil, err
+// WithClientPool makes the handler call Google with the forwarded credentials of the caller.
+func WithClientPool(pool ClientPool) Option {
+	return func(h *Handler) {
+		h.clientPool = pool
 	}
+}
 
-	if parsedPolicy.Name == nil {
-		return nil, fmt.Errorf("policy name is required")
+func WithAuditor(auditor *audit.Auditor) Option {
+	return func(h *Handler) {
+		h.auditor = auditor
 	}
+}
 
-	if ok, err := h.securityClient.CreatePolicy(h.ctx, parsedPolicy, projectID); !ok {
-		if err != nil {
-			return nil, err
-		}
+func WithAuthorizer(authorizer *auth.Authorizer) Option {
+	return func(h *Handler) {
+		h.authorizer = authorizer
 	}
-	return parsedPolicy, nil
 }
 
-func (h *Handler) HttpError(err error, w http.ResponseWriter, projectID, resource string) {
-	if ErrorType(err, http.StatusNotFound) {
-		w.WriteHeader(http.StatusNotFound)
+// WithLocalIdentity makes every request a request of identity, without authentication or authorization, for a
+// handler serving its own process, like the CLI calling Google with the credentials of its user.
+func WithLocalIdentity(identity *auth.Identity) Option {
+	return func(h *Handler) {
+		h.localIdentity = identity
 	}
-	if ErrorType(err, http.StatusBadRequest) {
-		h.log.Warnf("%s: %v", resource, err)
-		HttpError(w, fmt.Sprintf("%s resource %s: %s", resource, projectID, err.Error()), http.StatusBadRequest)
+}
+
+const (
+	securityTypePolicy = "policy"
+	securityTypeRule   = "rule"
+)
+
+func NewHandler(ctx context.Context, cfg *config.Config, securityClient cloudarmor.SecurityPolicies, serviceClient cloudarmor.BackendServices, log *logrus.Entry, opts ...Option) *Handler {
+	h := &Handler{
+		log:            log.WithField("subsystem", "handler"),
+		securityClient: securityClient,
+		serviceClient:  serviceClient,
+		ctx:            ctx,
+		cfg:            cfg,
+		operations:     operation.NewTracker(ctx, log.WithField("subsystem", "operations")),
+		events:         events.NewBroker(eventHistory),
+		streamsClosed:  make(chan struct{}),
 	}
-	if ErrorType(err, http.StatusConflict) {
-		h.log.Warnf("failed %s: %v", resource, err)
-		HttpError(w, fmt.Sprintf("%s exists in %s", resource, projectID), http.StatusConflict)
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
-func ErrorType(err error, code int) bool {
-	var e *googleapi.Error
-	if ok := errors.As(err, &e); ok {
-		if e.Code == code {
-			return true
-		}
-	}
-	return false
+// CloseStreams ends all open event streams, so they do not hold up a graceful shutdown.
+func (h *Handler) CloseStreams() {
+	h.closeStreams.Do(func() {
+		close(h.streamsClosed)
+	})
+}
+
+// Drain waits for asynchronous operations to finish or ctx to expire.
+func (h *Handler) Drain(ctx context.Context) error {
+	return h.operations.Wait(ctx)
 }
@@ -1,16 +1,126 @@
 package handler
 
-import "net/http"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nais/armor/config"
+)
 
 const (
 	EndpointIsAlive = "/internal/isalive"
 	EndpointIsReady = "/internal/isready"
+
+	readinessProbeTimeout = 5 * time.Second
+
+	dependencySecurityPolicies = "security-policies"
+	dependencyBackendServices  = "backend-services"
+
+	statusOk    = "ok"
+	statusError = "error"
 )
 
+type dependencyStatus struct {
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked,omitempty"`
+}
+
+type readinessResponse struct {
+	Ready        bool                         `json:"ready"`
+	Dependencies map[string]*dependencyStatus `json:"dependencies"`
+}
+
+// readiness caches the result of the last probe so the Compute API is called at most once per interval.
+type readiness struct {
+	mu      sync.Mutex
+	checked time.Time
+	result  *readinessResponse
+	// refreshed is closed when the running probe is done, nil while no probe runs.
+	refreshed chan struct{}
+}
+
 func (h *Handler) isAlive(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("alive"))
 }
 
 func (h *Handler) isReady(w http.ResponseWriter, r *http.Request) {
-	_, _ = w.Write([]byte("ready"))
+	result := h.probe()
+
+	if !result.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// probe is shared by concurrent readiness requests, so it runs with the handler context instead of the request context.
+// Only one probe runs at a time, the others get the last result meanwhile, or wait for the first one.
+func (h *Handler) probe() *readinessResponse {
+	h.readiness.mu.Lock()
+	if h.readiness.result != nil && time.Since(h.readiness.checked) < h.cfg.ReadinessInterval {
+		defer h.readiness.mu.Unlock()
+		return h.readiness.result
+	}
+	if refreshed := h.readiness.refreshed; refreshed != nil {
+		result := h.readiness.result
+		h.readiness.mu.Unlock()
+		if result != nil {
+			return result
+		}
+		<-refreshed
+		h.readiness.mu.Lock()
+		defer h.readiness.mu.Unlock()
+		return h.readiness.result
+	}
+	refreshed := make(chan struct{})
+	h.readiness.refreshed = refreshed
+	h.readiness.mu.Unlock()
+
+	result := h.checkDependencies()
+
+	h.readiness.mu.Lock()
+	h.readiness.result = result
+	h.readiness.checked = time.Now()
+	h.readiness.refreshed = nil
+	h.readiness.mu.Unlock()
+	close(refreshed)
+	return result
+}
+
+// checkDependencies calls the Compute API in the readiness project, the checks are skipped without one.
+func (h *Handler) checkDependencies() *readinessResponse {
+	result := &readinessResponse{
+		Ready:        true,
+		Dependencies: map[string]*dependencyStatus{},
+	}
+
+	if h.cfg.ReadinessProject == "" {
+		err := fmt.Sprintf("no project to probe, --%s is not set and the default credentials name none", config.ReadinessProject)
+		result.Dependencies[dependencySecurityPolicies] = &dependencyStatus{Status: statusError, Error: err}
+		result.Dependencies[dependencyBackendServices] = &dependencyStatus{Status: statusError, Error: err}
+		result.Ready = false
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, readinessProbeTimeout)
+	defer cancel()
+
+	check := func(name string, probe func(context.Context, string) error) {
+		status := &dependencyStatus{Status: statusOk, Checked: time.Now().UTC()}
+		if err := probe(ctx, h.cfg.ReadinessProject); err != nil {
+			h.log.Warnf("readiness probe %s failed: %v", name, err)
+			status.Status = statusError
+			status.Error = err.Error()
+			result.Ready = false
+		}
+		result.Dependencies[name] = status
+	}
+
+	check(dependencySecurityPolicies, h.securityClient.Probe)
+	check(dependencyBackendServices, h.serviceClient.Probe)
+	return result
 }
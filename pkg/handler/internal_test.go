@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/google"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+)
+
+func Test_isReady(t *testing.T) {
+	forbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"code":403,"message":"forbidden"}}`))
+	}))
+	defer forbidden.Close()
+
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer reachable.Close()
+	healthy := []option.ClientOption{option.WithEndpoint(reachable.URL), option.WithoutAuthentication()}
+
+	for _, test := range []struct {
+		name    string
+		project string
+		opts    []option.ClientOption
+		status  int
+		result  string
+	}{
+		{
+			name:    "Reachable Compute API is ready",
+			project: "fake-project",
+			opts:    healthy,
+			status:  http.StatusOK,
+			result:  statusOk,
+		},
+		{
+			name:    "Broken credentials are not ready",
+			project: "fake-project",
+			opts:    []option.ClientOption{option.WithEndpoint(forbidden.URL), option.WithoutAuthentication()},
+			status:  http.StatusServiceUnavailable,
+			result:  statusError,
+		},
+		{
+			name:   "Probe without a readiness project is not ready",
+			opts:   healthy,
+			status: http.StatusServiceUnavailable,
+			result: statusError,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{ReadinessProject: test.project, ReadinessInterval: time.Minute}
+			entry := log.WithField("component", "test")
+			securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, test.opts...)
+			assert.NoError(t, err)
+			serviceClient, err := google.NewServiceClient(cfg, context.Background(), entry, test.opts...)
+			assert.NoError(t, err)
+
+			h := NewHandler(context.Background(), cfg, securityClient, serviceClient, entry)
+			w := httptest.NewRecorder()
+			h.isReady(w, httptest.NewRequest(http.MethodGet, EndpointIsReady, nil))
+			assert.Equal(t, test.status, w.Code)
+
+			result := readinessResponse{}
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+			assert.Equal(t, test.result, result.Dependencies[dependencySecurityPolicies].Status)
+			assert.Same(t, h.probe(), h.probe(), "probe result is cached within the interval")
+		})
+	}
+}
+
+func Test_probeRefresh(t *testing.T) {
+	var calls int32
+	blocked := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first probe calls each client once, the probes after it are blocked.
+		if atomic.AddInt32(&calls, 1) > 2 {
+			<-blocked
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer slow.Close()
+	opts := []option.ClientOption{option.WithEndpoint(slow.URL), option.WithoutAuthentication()}
+
+	cfg := &config.Config{ReadinessProject: "fake-project", ReadinessInterval: time.Minute}
+	entry := log.WithField("component", "test")
+	securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, opts...)
+	assert.NoError(t, err)
+	serviceClient, err := google.NewServiceClient(cfg, context.Background(), entry, opts...)
+	assert.NoError(t, err)
+	h := NewHandler(context.Background(), cfg, securityClient, serviceClient, entry)
+
+	first := h.probe()
+	h.readiness.mu.Lock()
+	h.readiness.checked = time.Now().Add(-time.Hour)
+	h.readiness.mu.Unlock()
+
+	refreshed := make(chan *readinessResponse)
+	go func() { refreshed <- h.probe() }()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) > 2 }, time.Second, time.Millisecond)
+	assert.Same(t, first, h.probe(), "the last result is served while a probe runs")
+
+	close(blocked)
+	assert.NotSame(t, first, <-refreshed)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls), "only one probe runs at a time")
+}
+
+func Test_internalPort(t *testing.T) {
+	cfg := &config.Config{DevelopmentMode: true, InternalPort: ":8081"}
+	h := NewHandler(context.Background(), cfg, nil, nil, log.WithField("component", "test"))
+
+	w := httptest.NewRecorder()
+	SetupHttpRouter(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, EndpointIsAlive, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "internal routes are only served on the internal port")
+
+	w = httptest.NewRecorder()
+	SetupInternalRouter(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, EndpointIsAlive, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
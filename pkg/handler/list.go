@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	headerNextPageToken = "X-Next-Page-Token"
+	// headerTotalCount is the number of items on all pages, set by lists that know it.
+	headerTotalCount = "X-Total-Count"
+	// maxListPageSize is the largest page of a Compute API list, and of every other list.
+	maxListPageSize = 500
+)
+
+// listQuery is the filter, page and fields of a list of Compute resources, given in the query of a request.
+type listQuery struct {
+	opts cloudarmor.ListOptions
+	// fields are the paths of the fields of each resource to respond with, every field when empty.
+	fields [][]string
+}
+
+// listPage fetches a page of a list of Compute resources.
+type listPage func(opts cloudarmor.ListOptions) ([]proto.Message, string, error)
+
+// parseListQuery parses filter, passed through to Google, fields, separated by commas with nested fields separated
+// by dots, and pageSize and pageToken, which select a single page of the list instead of every resource.
+func parseListQuery(values url.Values, resource proto.Message) (*listQuery, error) {
+	q := &listQuery{opts: cloudarmor.ListOptions{Filter: values.Get("filter")}}
+
+	var err error
+	if q.opts.PageSize, q.opts.PageToken, err = parsePage(values); err != nil {
+		return nil, err
+	}
+
+	if v := values.Get("fields"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			path := strings.Split(strings.TrimSpace(field), ".")
+			if !validPath(resource.ProtoReflect().Descriptor(), path) {
+				return nil, armorerr.New(armorerr.KindParse, "unknown field: %s", field)
+			}
+			q.fields = append(q.fields, path)
+		}
+	}
+	return q, nil
+}
+
+// parsePage parses pageSize and pageToken, the same for every list: without a pageSize the whole list is returned,
+// with one only that page, with the token of the next page in the X-Next-Page-Token header.
+func parsePage(values url.Values) (int, string, error) {
+	size, token := 0, values.Get("pageToken")
+	if v := values.Get("pageSize"); v != "" {
+		var err error
+		size, err = strconv.Atoi(v)
+		if err != nil || size < 1 || size > maxListPageSize {
+			return 0, "", armorerr.New(armorerr.KindParse, "invalid pageSize, expected 1 to %d: %s", maxListPageSize, v)
+		}
+	}
+	if token != "" && size == 0 {
+		return 0, "", armorerr.New(armorerr.KindParse, "pageToken requires a pageSize")
+	}
+	return size, token, nil
+}
+
+// setPageHeaders sets the token of the next page, if there is one, and lets browsers read the headers of a page.
+func setPageHeaders(w http.ResponseWriter, next string) {
+	if next != "" {
+		w.Header().Set(headerNextPageToken, next)
+	}
+	w.Header().Set("Access-Control-Expose-Headers", headerNextPageToken+", "+headerTotalCount)
+}
+
+// fieldByName finds a field by its name in responses, or by its name in the Compute REST API.
+func fieldByName(message protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if field := message.Fields().ByName(protoreflect.Name(name)); field != nil {
+		return field
+	}
+	return message.Fields().ByJSONName(name)
+}
+
+func validPath(message protoreflect.MessageDescriptor, path []string) bool {
+	field := fieldByName(message, path[0])
+	switch {
+	case field == nil || field.IsMap():
+		return false
+	case len(path) == 1:
+		return true
+	case field.Message() == nil:
+		return false
+	}
+	return validPath(field.Message(), path[1:])
+}
+
+// selectFields returns a copy of the message with only the fields at the paths.
+func selectFields(message protoreflect.Message, paths [][]string) protoreflect.Message {
+	selected := message.New()
+	nested := map[protoreflect.FieldDescriptor][][]string{}
+	for _, path := range paths {
+		field := fieldByName(message.Descriptor(), path[0])
+		if !message.Has(field) {
+			continue
+		}
+		if len(path) == 1 {
+			selected.Set(field, message.Get(field))
+			continue
+		}
+		nested[field] = append(nested[field], path[1:])
+	}
+
+	for field, paths := range nested {
+		if selected.Has(field) {
+			continue
+		}
+		if !field.IsList() {
+			selected.Set(field, protoreflect.ValueOfMessage(selectFields(message.Get(field).Message(), paths)))
+			continue
+		}
+		list, selectedList := message.Get(field).List(), selected.Mutable(field).List()
+		for i := 0; i < list.Len(); i++ {
+			selectedList.Append(protoreflect.ValueOfMessage(selectFields(list.Get(i).Message(), paths)))
+		}
+	}
+	return selected
+}
+
+// writeList streams a list of Compute resources as a JSON array, encoding each page as it is fetched, so whole
+// projects are not kept in memory. With a page size only that page is written, with the token of the next page in
+// the X-Next-Page-Token header. An error after the first page aborts the response, recording the status of the error.
+func (h *Handler) writeList(w http.ResponseWriter, r *http.Request, query *listQuery, fetch listPage) {
+	opts := query.opts
+	items, next, err := fetch(opts)
+	if err != nil {
+		h.requestLog(r).Errorf("failed to list: %v", err)
+		h.writeError(w, r, err)
+		return
+	}
+
+	if opts.PageSize > 0 {
+		setPageHeaders(w, next)
+	}
+	flusher, _ := w.(http.Flusher)
+	count := 0
+	_, _ = w.Write([]byte("["))
+	for {
+		for _, item := range items {
+			if len(query.fields) > 0 {
+				item = selectFields(item.ProtoReflect(), query.fields).Interface()
+			}
+			data, err := json.Marshal(item)
+			if err != nil {
+				h.requestLog(r).Errorf("failed to encode list: %v", err)
+				abortResponse(w, http.StatusInternalServerError)
+			}
+			if count > 0 {
+				_, _ = w.Write([]byte(","))
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			count++
+		}
+		if opts.PageSize > 0 || next == "" {
+			break
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		opts.PageToken = next
+		if items, next, err = fetch(opts); err != nil {
+			h.requestLog(r).Errorf("failed to list page after %d items: %v", count, err)
+			abortResponse(w, newProblem(err).Status)
+		}
+	}
+	_, _ = w.Write([]byte("]\n"))
+	h.requestLog(r).Debugf("listed %d items", count)
+}
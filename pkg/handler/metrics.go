@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/metrics"
+)
+
+const EndpointMetrics = "/internal/metrics"
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	aborted int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection, e.g. to extend the write deadline of a stream.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *statusRecorder) statusCode() int {
+	if s.aborted != 0 {
+		return s.aborted
+	}
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// abortResponse aborts a response whose status was already written, recording status in place of the written one
+// for the metrics and the trace of the request.
+func abortResponse(w http.ResponseWriter, status int) {
+	for w != nil {
+		if recorder, ok := w.(*statusRecorder); ok {
+			recorder.aborted = status
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		// Deferred, so aborted responses are counted as well.
+		defer func() {
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			metrics.HttpRequest(route, r.Method, recorder.statusCode(), start)
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
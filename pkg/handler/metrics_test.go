@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrapeMetrics returns the metrics exposition of the default registry.
+func scrapeMetrics(t *testing.T) string {
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, EndpointMetrics, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func Test_metricsMiddlewareAbort(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(metricsMiddleware)
+	router.Use(tracingMiddleware)
+	router.HandleFunc("/aborted/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("["))
+		abortResponse(w, http.StatusServiceUnavailable)
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/aborted/1", nil))
+	})
+	assert.Contains(t, scrapeMetrics(t), `armor_http_requests_total{method="GET",route="/aborted/{id}",status="503"} 1`)
+}
+
+func Test_routeMetrics(t *testing.T) {
+	h := newHarness(t)
+
+	status, _ := h.do(http.MethodGet, "/projects/fake-project/policies/test-policy/rules/1000", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := h.do(http.MethodGet, EndpointMetrics, "")
+	assert.Equal(t, http.StatusOK, status)
+	metrics := string(body)
+	assert.Regexp(t, `armor_http_requests_total\{method="GET",route="/projects/\{project\}/policies/\{policy\}/rules/\{priority\}",status="200"\} [1-9]`, metrics)
+	assert.Regexp(t, `armor_http_request_duration_seconds_count\{method="GET",route="/projects/\{project\}/policies/\{policy\}/rules/\{priority\}",status="200"\} [1-9]`, metrics)
+	assert.NotContains(t, metrics, `route="/projects/fake-project`, "routes are labelled by their template, not the requested path")
+}
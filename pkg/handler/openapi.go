@@ -0,0 +1,343 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/events"
+	"github.com/nais/armor/pkg/operation"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+const (
+	EndpointOpenAPI = "/openapi.json"
+
+	contentTypeEventStream = "text/event-stream"
+	schemaRefPrefix        = "#/components/schemas/"
+	// namePattern is what parse accepts in the path and query of a request.
+	namePattern = "^[A-Za-z0-9]+(?:-[A-Za-z0-9]+)*$"
+)
+
+// apiDocument is the OpenAPI document of the routes of SetupHttpRouter, and the schemas request bodies are validated with.
+var apiDocument = newAPIDocument()
+
+type openAPI struct {
+	doc  *openapi3.T
+	json []byte
+	// requests are the schemas of the request bodies by route name.
+	requests map[string]*openapi3.Schema
+}
+
+// apiRoute is an operation in the document.
+type apiRoute struct {
+	name     string
+	method   string
+	path     string
+	tag      string
+	summary  string
+	params   openapi3.Parameters
+	request  *openapi3.SchemaRef
+	status   int
+	response openapi3.Content
+	// headers are the headers of the response with status.
+	headers openapi3.Headers
+	// mutation routes can be run in the background and answer 202 Accepted.
+	mutation bool
+	public   bool
+}
+
+func newAPIDocument() *openAPI {
+	schemas := componentSchemas()
+	ref := func(name string) *openapi3.SchemaRef {
+		return openapi3.NewSchemaRef(schemaRefPrefix+name, schemas[name].Value)
+	}
+	list := func(name string) openapi3.Content {
+		array := openapi3.NewArraySchema()
+		array.Items = ref(name)
+		return openapi3.NewContentWithJSONSchema(array)
+	}
+
+	// Creates require the fields the handlers check, patches only change the fields given.
+	createPolicy := &openapi3.Schema{AllOf: openapi3.SchemaRefs{
+		ref("ArmorRequestPolicy"),
+		openapi3.NewSchemaRef("", openapi3.NewSchema().WithPropertyRef("policy", openapi3.NewSchemaRef("", &openapi3.Schema{
+			Required: []string{"name"},
+		}))),
+	}}
+	createRule := &openapi3.Schema{AllOf: openapi3.SchemaRefs{
+		ref("ArmorRequestRule"),
+		openapi3.NewSchemaRef("", openapi3.NewSchema().WithPropertyRef("rule", openapi3.NewSchemaRef("", &openapi3.Schema{
+			Required: []string{"action", "priority", "preview", "match"},
+		}))),
+	}}
+
+	project := pathParameter("project", "Google project id.", namePattern)
+	policy := pathParameter("policy", "Name of the security policy.", namePattern)
+	priority := pathParameter("priority", "Priority of the rule.", "")
+	priority.Value.Schema = openapi3.NewInt32Schema().NewRef()
+	backend := pathParameter("backend", "Name of the backend service.", namePattern)
+	pageParams := func(params ...*openapi3.ParameterRef) openapi3.Parameters {
+		return append(params,
+			queryParameter("pageSize", "Only list a page of this size, with the token of the next page in the X-Next-Page-Token header.",
+				openapi3.NewIntegerSchema().WithMin(1).WithMax(maxListPageSize)),
+			queryParameter("pageToken", "X-Next-Page-Token of the previous page, with the same query and pageSize.", openapi3.NewStringSchema()),
+		)
+	}
+	listParams := func(params ...*openapi3.ParameterRef) openapi3.Parameters {
+		return pageParams(append(params,
+			queryParameter("filter", `Compute API filter expression, e.g. name = "my-name".`, openapi3.NewStringSchema()),
+			queryParameter("fields", "Fields of the items to respond with separated by commas, nested fields by dots, e.g. name,rules.priority.",
+				openapi3.NewStringSchema()),
+		)...)
+	}
+	pageHeaders := openapi3.Headers{
+		headerNextPageToken: responseHeader("Token of the next page, when a page was asked for and there are more items.",
+			openapi3.NewStringSchema()),
+	}
+
+	routes := []apiRoute{
+		{name: "GetPolicies", method: http.MethodGet, path: EndpointGetPolicies, tag: "policies",
+			summary: "List the security policies of the project", params: listParams(project),
+			status: http.StatusOK, response: list("SecurityPolicy"), headers: pageHeaders},
+		{name: "CreatePolicy", method: http.MethodPost, path: EndpointCreatePolicy, tag: "policies",
+			summary: "Create a security policy", params: openapi3.Parameters{project},
+			request: openapi3.NewSchemaRef("", createPolicy), status: http.StatusCreated, mutation: true},
+		{name: "GetPolicy", method: http.MethodGet, path: EndpointGetPolicy, tag: "policies",
+			summary: "Get a security policy with its rules", params: openapi3.Parameters{project, policy},
+			status: http.StatusOK, response: openapi3.NewContentWithJSONSchemaRef(ref("SecurityPolicy"))},
+		{name: "UpdatePolicy", method: http.MethodPatch, path: EndpointUpdatePolicy, tag: "policies",
+			summary: "Change the given fields of a security policy, its rules are changed with the rule routes",
+			params:  openapi3.Parameters{project, policy}, request: ref("ArmorRequestPolicy"), status: http.StatusOK, mutation: true},
+		{name: "DeletePolicy", method: http.MethodDelete, path: EndpointDeletePolicy, tag: "policies",
+			summary: "Delete a security policy", params: openapi3.Parameters{project, policy}, status: http.StatusOK, mutation: true},
+		{name: "CreateRule", method: http.MethodPost, path: EndpointCreateRule, tag: "rules",
+			summary: "Add a rule to a security policy", params: openapi3.Parameters{project, policy},
+			request: openapi3.NewSchemaRef("", createRule), status: http.StatusCreated, mutation: true},
+		{name: "GetRules", method: http.MethodGet, path: EndpointGetRules, tag: "rules",
+			summary: "List the rules of a security policy matching all given filters",
+			params: pageParams(project, policy,
+				queryParameter("action", "Action of the rules, e.g. allow, or deny for any deny status.", openapi3.NewStringSchema()),
+				queryParameter("preview", "Only rules in preview, or only enforced rules.", openapi3.NewBoolSchema()),
+				queryParameter("minPriority", "Lowest priority of the rules.", openapi3.NewInt32Schema().WithMin(0)),
+				queryParameter("maxPriority", "Highest priority of the rules.", openapi3.NewInt32Schema().WithMin(0)),
+				queryParameter("srcIp", "IP address or CIDR range contained in a source IP range of the rules.", openapi3.NewStringSchema()),
+				queryParameter("expressionSet", "Preconfigured WAF expression set the rules evaluate, by id or rule type, e.g. sqli-v33-stable or sqli.",
+					openapi3.NewStringSchema().WithPattern(namePattern)),
+				queryParameter("description", "Text in the description of the rules, ignoring case.", openapi3.NewStringSchema()),
+				queryParameter("orderBy", "priority (default), action or description, followed by desc to reverse the order.",
+					openapi3.NewStringSchema().WithPattern(`^(priority|action|description)( (asc|desc))?$`)),
+			),
+			status: http.StatusOK, response: list("SecurityPolicyRule"), headers: openapi3.Headers{
+				headerNextPageToken: pageHeaders[headerNextPageToken],
+				headerTotalCount:    responseHeader("Number of rules matching the filters, on all pages.", openapi3.NewIntegerSchema()),
+			}},
+		{name: "GetRule", method: http.MethodGet, path: EndpointGetRule, tag: "rules",
+			summary: "Get a rule of a security policy", params: openapi3.Parameters{project, policy, priority},
+			status: http.StatusOK, response: openapi3.NewContentWithJSONSchemaRef(ref("SecurityPolicyRule"))},
+		{name: "UpdateRule", method: http.MethodPatch, path: EndpointUpdateRule, tag: "rules",
+			summary: "Change the given fields of a rule", params: openapi3.Parameters{project, policy, priority},
+			request: ref("ArmorRequestRule"), status: http.StatusOK, mutation: true},
+		{name: "DeleteRule", method: http.MethodDelete, path: EndpointDeleteRule, tag: "rules",
+			summary: "Remove a rule from a security policy", params: openapi3.Parameters{project, policy, priority},
+			status: http.StatusOK, mutation: true},
+		{name: "GetPreConfiguredRules", method: http.MethodGet, path: EndpointGetPreConfiguredRules, tag: "rules",
+			summary: "List the preconfigured WAF expression sets",
+			params: openapi3.Parameters{project,
+				queryParameter("rule-type", "Rule type of the expression sets, e.g. sqli.", openapi3.NewStringSchema().WithPattern(namePattern)),
+				queryParameter("version", "Version of the expression sets with a rule type, e.g. v33-stable.", openapi3.NewStringSchema().WithPattern(namePattern)),
+			},
+			status: http.StatusOK, response: list("WafExpressionSet")},
+		{name: "GetBackendServices", method: http.MethodGet, path: EndpointGetBackendServices, tag: "backend services",
+			summary: "List the backend services of the project", params: listParams(project),
+			status: http.StatusOK, response: list("BackendService"), headers: pageHeaders},
+		{name: "SetPolicyBackend", method: http.MethodPost, path: EndpointSetPolicyBackend, tag: "backend services",
+			summary: "Attach a security policy to a backend service", params: openapi3.Parameters{project, policy, backend},
+			status: http.StatusCreated, mutation: true},
+		{name: "GetOperation", method: http.MethodGet, path: EndpointGetOperation, tag: "operations",
+			summary: "Get a change running in the background", params: openapi3.Parameters{pathParameter("id", "Id of the operation.", "")},
+			status: http.StatusOK, response: openapi3.NewContentWithJSONSchemaRef(ref("Operation"))},
+		{name: "GetEvents", method: http.MethodGet, path: EndpointGetEvents, tag: "events",
+			summary: "Stream the changes in the project as Server-Sent Events",
+			params: openapi3.Parameters{project,
+				headerParameter("Last-Event-ID", "Resume after the event with this id.", openapi3.NewStringSchema()),
+			},
+			status: http.StatusOK, response: openapi3.NewContentWithSchemaRef(ref("Event"), []string{contentTypeEventStream})},
+		{name: "GetAudit", method: http.MethodGet, path: EndpointGetAudit, tag: "audit",
+			summary: "List the most recent changes in the project",
+			params: openapi3.Parameters{project,
+				queryParameter("limit", "Maximum number of entries.", openapi3.NewIntegerSchema().WithMin(1)),
+				queryParameter("since", "Only entries after this time.", openapi3.NewDateTimeSchema()),
+			},
+			status: http.StatusOK, response: list("AuditEntry")},
+		{name: "GetOpenAPI", method: http.MethodGet, path: EndpointOpenAPI, tag: "documentation", public: true,
+			summary: "This OpenAPI document", status: http.StatusOK, response: openapi3.NewContentWithJSONSchema(openapi3.NewObjectSchema())},
+		{name: "IsAlive", method: http.MethodGet, path: EndpointIsAlive, tag: "internal", public: true,
+			summary: "Liveness probe, unless served on the internal port", status: http.StatusOK},
+		{name: "IsReady", method: http.MethodGet, path: EndpointIsReady, tag: "internal", public: true,
+			summary: "Readiness probe, unless served on the internal port", status: http.StatusOK},
+		{name: "Metrics", method: http.MethodGet, path: EndpointMetrics, tag: "internal", public: true,
+			summary: "Prometheus metrics, unless served on the internal port", status: http.StatusOK,
+			response: openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"})},
+	}
+
+	problem := &openapi3.ResponseRef{Value: openapi3.NewResponse().
+		WithDescription("The error, as RFC 7807 problem details.").
+		WithContent(openapi3.NewContentWithSchemaRef(ref("Problem"), []string{contentTypeProblem}))}
+	accepted := &openapi3.ResponseRef{Value: openapi3.NewResponse().
+		WithDescription("Running in the background as an armor operation when asked for with ?async=true or Prefer: respond-async, " +
+			"or still running in Google after the deadline.").
+		WithContent(openapi3.Content{
+			"application/json": openapi3.NewMediaType().WithSchemaRef(ref("Operation")),
+			contentTypeProblem: openapi3.NewMediaType().WithSchemaRef(ref("Problem")),
+		})}
+	async := openapi3.Parameters{
+		queryParameter("async", "Run the change in the background and answer 202 Accepted with an armor operation.", openapi3.NewBoolSchema()),
+		headerParameter("Prefer", "respond-async to run the change in the background, like ?async=true.", openapi3.NewStringSchema()),
+	}
+
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "armor",
+			Description: "Manage Google Cloud Armor security policies, their rules and the backend services they protect.",
+			Version:     "1",
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: schemas,
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearer": &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme()},
+			},
+		},
+		Security: openapi3.SecurityRequirements{openapi3.NewSecurityRequirement().Authenticate("bearer")},
+	}
+
+	requests := map[string]*openapi3.Schema{}
+	for _, route := range routes {
+		op := openapi3.NewOperation()
+		op.OperationID = route.name
+		op.Summary = route.summary
+		op.Tags = []string{route.tag}
+		op.Parameters = route.params
+		if route.mutation {
+			op.Parameters = append(append(openapi3.Parameters{}, route.params...), async...)
+		}
+		if route.public {
+			op.Security = openapi3.NewSecurityRequirements()
+		}
+		if route.request != nil {
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(route.request)}
+			requests[route.name] = route.request.Value
+		}
+
+		op.Responses = openapi3.NewResponses()
+		op.Responses.Set("default", problem)
+		ok := openapi3.NewResponse().WithDescription(http.StatusText(route.status)).WithContent(route.response)
+		ok.Headers = route.headers
+		op.Responses.Set(fmt.Sprint(route.status), &openapi3.ResponseRef{Value: ok})
+		if route.mutation {
+			op.Responses.Set(fmt.Sprint(http.StatusAccepted), accepted)
+		}
+
+		item := doc.Paths.Value(route.path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(route.path, item)
+		}
+		item.SetOperation(route.method, op)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic(fmt.Sprintf("marshal openapi document: %v", err))
+	}
+	return &openAPI{doc: doc, json: data, requests: requests}
+}
+
+// componentSchemas generates the schemas of the resources from their types, as they are encoded in JSON.
+func componentSchemas() openapi3.Schemas {
+	types := map[string]interface{}{
+		"SecurityPolicy":     &compute.SecurityPolicy{},
+		"SecurityPolicyRule": &compute.SecurityPolicyRule{},
+		"BackendService":     &compute.BackendService{},
+		"WafExpressionSet":   &compute.WafExpressionSet{},
+		"Operation":          &operation.Operation{},
+		"AuditEntry":         &audit.Entry{},
+		"Event":              &events.Event{},
+		"Problem":            &Problem{},
+	}
+
+	schemas := openapi3.Schemas{}
+	for name, value := range types {
+		schema, err := openapi3gen.NewSchemaRefForValue(value, schemas, openapi3gen.SchemaCustomizer(closedObjects))
+		if err != nil {
+			panic(fmt.Sprintf("generate schema of %s: %v", name, err))
+		}
+		schemas[name] = schema
+	}
+
+	// The rules of a policy refer to the rule schema instead of repeating it.
+	rules := schemas["SecurityPolicy"].Value.Properties["rules"].Value
+	rules.Items = openapi3.NewSchemaRef(schemaRefPrefix+"SecurityPolicyRule", schemas["SecurityPolicyRule"].Value)
+
+	schemas["ArmorRequestPolicy"] = envelope("policy", "SecurityPolicy", schemas)
+	schemas["ArmorRequestRule"] = envelope("rule", "SecurityPolicyRule", schemas)
+	return schemas
+}
+
+// envelope is the schema of a request body wrapping a resource in a single field.
+func envelope(field, name string, schemas openapi3.Schemas) *openapi3.SchemaRef {
+	schema := openapi3.NewObjectSchema().
+		WithPropertyRef(field, openapi3.NewSchemaRef(schemaRefPrefix+name, schemas[name].Value)).
+		WithoutAdditionalProperties()
+	schema.Required = []string{field}
+	return schema.NewRef()
+}
+
+// closedObjects rejects fields a struct does not have, which encoding/json would silently drop.
+func closedObjects(_ string, t reflect.Type, _ reflect.StructTag, schema *openapi3.Schema) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && schema.Type == openapi3.TypeObject {
+		schema.WithoutAdditionalProperties()
+	}
+	return nil
+}
+
+func pathParameter(name, description, pattern string) *openapi3.ParameterRef {
+	schema := openapi3.NewStringSchema()
+	if pattern != "" {
+		schema.WithPattern(pattern)
+	}
+	return &openapi3.ParameterRef{Value: openapi3.NewPathParameter(name).WithDescription(description).WithSchema(schema)}
+}
+
+func queryParameter(name, description string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(schema)}
+}
+
+func responseHeader(description string, schema *openapi3.Schema) *openapi3.HeaderRef {
+	return &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+		Description: description,
+		Schema:      schema.NewRef(),
+	}}}
+}
+
+func headerParameter(name, description string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Value: openapi3.NewHeaderParameter(name).WithDescription(description).WithSchema(schema)}
+}
+
+func isPublic(r *http.Request) bool {
+	return r.URL.Path == EndpointOpenAPI
+}
+
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(apiDocument.json)
+}
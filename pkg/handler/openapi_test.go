@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/memory"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_openAPIDocument(t *testing.T) {
+	assert.NoError(t, apiDocument.doc.Validate(context.Background()))
+
+	h := NewHandler(context.Background(), &config.Config{}, memory.New(), memory.New(), log.WithField("component", "test"))
+	router := SetupHttpRouter(h)
+	routes := 0
+	assert.NoError(t, router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		assert.NoError(t, err)
+		methods, err := route.GetMethods()
+		assert.NoError(t, err)
+
+		item := apiDocument.doc.Paths.Value(path)
+		if !assert.NotNil(t, item, "%s is documented", path) {
+			return nil
+		}
+		for _, method := range methods {
+			op := item.GetOperation(method)
+			if !assert.NotNil(t, op, "%s %s is documented", method, path) {
+				continue
+			}
+			routes++
+			if route.GetName() != "" {
+				assert.Equal(t, route.GetName(), op.OperationID)
+			}
+		}
+		return nil
+	}))
+	operations := 0
+	for _, item := range apiDocument.doc.Paths.Map() {
+		operations += len(item.Operations())
+	}
+	assert.Equal(t, operations, routes, "every operation is a route")
+}
+
+func Test_openAPIIsPublic(t *testing.T) {
+	h := NewHandler(context.Background(), &config.Config{InternalPort: "8081"}, memory.New(), memory.New(), log.WithField("component", "test"))
+	server := httptest.NewServer(SetupHttpRouter(h))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + EndpointOpenAPI)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	var doc map[string]interface{}
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	response, err = http.Get(server.URL + "/projects/fake-project/policies")
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode, "the API itself still requires authentication")
+}
+
+func Test_validation(t *testing.T) {
+	h := newHarness(t)
+	for _, test := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		params []InvalidParam
+	}{
+		{
+			name:   "Wrong type deep in a rule",
+			method: http.MethodPost,
+			path:   "/projects/fake-project/policies/test-policy/rules",
+			body:   `{"rule":{"priority":20,"action":"deny(403)","preview":false,"match":{"config":{"src_ip_ranges":[42]}}}}`,
+			params: []InvalidParam{{Name: "rule.match.config.src_ip_ranges[0]", Reason: "value must be a string"}},
+		},
+		{
+			name:   "Unknown field",
+			method: http.MethodPatch,
+			path:   "/projects/fake-project/policies/test-policy",
+			body:   `{"policy":{"descripton":"typo"}}`,
+			params: []InvalidParam{{Name: "policy", Reason: `property "descripton" is unsupported`}},
+		},
+		{
+			name:   "Missing fields of a new rule",
+			method: http.MethodPost,
+			path:   "/projects/fake-project/policies/test-policy/rules",
+			body:   `{"rule":{"priority":20,"action":"deny(403)"}}`,
+			params: []InvalidParam{
+				{Name: "rule.match", Reason: `property "match" is missing`},
+				{Name: "rule.preview", Reason: `property "preview" is missing`},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := h.do(test.method, test.path, test.body)
+			assert.Equal(t, http.StatusBadRequest, status)
+			problem := &Problem{}
+			assert.NoError(t, json.Unmarshal(body, problem))
+			assert.Equal(t, test.params, problem.InvalidParams)
+			assert.Contains(t, problem.Detail, "invalid request body: ")
+		})
+	}
+
+	status, _ := h.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules", validRule)
+	assert.Equal(t, http.StatusCreated, status, "a valid rule passes the validation")
+}
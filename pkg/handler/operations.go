@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/auth"
+	"github.com/nais/armor/pkg/logging"
+	"github.com/nais/armor/pkg/operation"
+	"github.com/nais/armor/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EndpointGetOperation = "/operations/{id}"
+
+	preferRespondAsync = "respond-async"
+)
+
+func (h *Handler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if ok, value := parse(id); !ok {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
+		return
+	}
+
+	op, ok := h.operations.Get(id)
+	if !ok {
+		h.writeError(w, r, armorerr.New(armorerr.KindNotFound, "operation %s not found", id))
+		return
+	}
+
+	if !h.authorized(w, r, op.Project, auth.VerbRead) {
+		return
+	}
+
+	response(w, interface{}(op))
+}
+
+// isAsync reports whether the caller asked for a mutation to be tracked in the background,
+// with either ?async=true or a Prefer: respond-async header.
+func (h *Handler) isAsync(r *http.Request) bool {
+	if r.URL.Query().Get("async") == "true" {
+		return true
+	}
+	for _, prefer := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(prefer, ",") {
+			if strings.TrimSpace(preference) == preferRespondAsync {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startOperation runs the mutation in the background and responds with 202 and the armor operation.
+// The mutation is bounded by the deadline of the action like a synchronous one, an operation Google is still
+// running when it expires stays running with the name of the Google operation.
+func (h *Handler) startOperation(w http.ResponseWriter, r *http.Request, projectID, action string, mutation operation.Mutation) {
+	op, err := h.operations.Start(projectID, action, func(ctx context.Context) (result interface{}, err error) {
+		if deadline := h.cfg.Deadline(action, true); deadline > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, deadline)
+			defer cancel()
+		}
+		ctx, release, err := h.detach(ctx, r.Context())
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		ctx, span := tracing.Start(ctx, "Operation."+action, tracing.AttributeProject.String(projectID))
+		defer func() { tracing.End(span, err) }()
+
+		return mutation(h.eventContext(ctx))
+	})
+	if err != nil {
+		h.requestLog(r).Errorf("failed to start operation %s: %v", action, err)
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindInternal, err, "start operation %s", action))
+		return
+	}
+
+	h.requestLog(r).Debugf("started operation %s %s in %s", op.ID, action, projectID)
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.AttributeOperation.String(op.ID))
+	w.Header().Set("Location", strings.Replace(EndpointGetOperation, "{id}", op.ID, 1))
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(op)
+}
+
+// detach carries the trace, log, caller and Google clients of the request over to ctx of an operation outliving it.
+// The caller clients are leased again until release is called, as the lease of the request ends with it.
+func (h *Handler) detach(ctx, request context.Context) (context.Context, func(), error) {
+	ctx = trace.ContextWithSpanContext(ctx, trace.SpanContextFromContext(request))
+	ctx = logging.WithLogger(ctx, h.contextLog(request))
+	if identity, ok := auth.IdentityFromContext(request); ok {
+		ctx = auth.WithIdentity(ctx, identity)
+	}
+	if clients, ok := request.Value(clientsContextKey{}).(*callerClients); ok && h.clientPool != nil {
+		return h.withCallerClients(ctx, clients.token)
+	}
+	return ctx, func() {}, nil
+}
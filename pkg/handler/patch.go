@@ -2,11 +2,9 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
 	"github.com/nais/armor/pkg/model"
-	"github.com/sirupsen/logrus"
-	"google.golang.org/genproto/googleapis/cloud/compute/v1"
 	"io"
 	"net/http"
 )
@@ -17,55 +15,47 @@ const (
 )
 
 func (h *Handler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "UpdatePolicy",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 
 	if ok, value := parse(projectID, policy); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
 	reqBody, err := io.ReadAll(r.Body)
-	if err != nil || len(reqBody) == 0 {
-		HttpError(w, "error body", http.StatusBadRequest)
+	if err != nil {
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "read request body"))
+		return
+	}
+	if len(reqBody) == 0 {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "request body is required"))
 		return
 	}
 
 	request := model.ArmorRequestPolicy{}
 	err = json.Unmarshal(reqBody, &request)
 	if err != nil {
-		h.log.Errorf("parse rule %v", err)
-		HttpError(w, fmt.Sprintf("parse request body for project %s: policy %s", projectID, policy), http.StatusBadRequest)
+		h.requestLog(r).Errorf("parse rule %v", err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse request body for project %s: policy %s", projectID, policy))
 		return
 	}
 
-	currentPolicy, err := h.securityClient.GetPolicy(h.ctx, projectID, policy)
+	mutation, err := h.updatePolicy(r.Context(), projectID, policy, &request)
 	if err != nil {
-		h.log.Errorf("failed to get policy %s: %v", policy, err)
-		h.HttpError(err, w, projectID, securityTypePolicy)
-		response(w, interface{}(&compute.SecurityPolicy{}))
+		h.writeError(w, r, err)
 		return
 	}
 
-	resource := compute.SecurityPolicy{}
-	if err := request.MergePolicy(&resource, currentPolicy); err != nil {
-		h.log.Warnf("failed to merge policy: %v", err)
-		HttpError(w, fmt.Sprintf("merge policy %s for project %s", policy, projectID), http.StatusInternalServerError)
-		response(w, interface{}(&compute.SecurityPolicy{}))
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "UpdatePolicy", mutation)
 		return
 	}
 
-	if ok, err := h.securityClient.UpdatePolicy(h.ctx, &resource, projectID, policy); !ok {
-		if err != nil {
-			h.log.Errorf("failed to get policy %s: %v", policy, err)
-			h.HttpError(err, w, projectID, securityTypePolicy)
-			response(w, interface{}(&compute.SecurityPolicy{}))
-			return
-		}
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("failed to get policy %s: %v", policy, err)
+		h.writeError(w, r, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -73,70 +63,57 @@ func (h *Handler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "UpdateRule",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 	priority := mux.Vars(r)["priority"]
 
 	if ok, value := parse(projectID, policy, priority); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
 	reqBody, err := io.ReadAll(r.Body)
-	if err != nil || len(reqBody) == 0 {
-		HttpError(w, "error body", http.StatusBadRequest)
+	if err != nil {
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "read request body"))
+		return
+	}
+	if len(reqBody) == 0 {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "request body is required"))
 		return
 	}
 
 	request := model.ArmorRequestRule{}
 	err = json.Unmarshal(reqBody, &request)
 	if err != nil {
-		h.log.Errorf("parse rule %v", err)
-		HttpError(w, fmt.Sprintf("parse request body for project %s: policy %s", projectID, policy), http.StatusBadRequest)
+		h.requestLog(r).Errorf("parse rule %v", err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse request body for project %s: policy %s", projectID, policy))
 		return
 	}
 
 	p, err := parseInt(priority)
 	if err != nil {
-		h.log.Errorf("failed to parse priority %s: %v", priority, err)
-		HttpError(w, fmt.Sprintf("parse priority: %s", priority), http.StatusInternalServerError)
+		h.requestLog(r).Errorf("failed to parse priority %s: %v", priority, err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse priority: %s", priority))
 		return
 	}
 
-	currentRule, err := h.securityClient.GetRule(h.ctx, &p, projectID, policy)
+	mutation, err := h.updateRule(r.Context(), projectID, policy, p, &request)
 	if err != nil {
-		h.log.Errorf("failed to get rule %s: %v", priority, err)
-		h.HttpError(err, w, projectID, securityTypeRule)
-		response(w, interface{}(&compute.SecurityPolicyRule{}))
+		h.writeError(w, r, err)
 		return
 	}
 
-	if h.cfg.IsProtectedRule(priority) {
-		HttpError(w, fmt.Sprintf("forbidden to update protected rule %s", priority), http.StatusBadRequest)
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "UpdateRule", mutation)
 		return
 	}
 
-	resource := compute.SecurityPolicyRule{}
-	if err := request.MergeRule(&resource, currentRule); err != nil {
-		h.log.Warnf("failed to merge rule: %v", err)
-		HttpError(w, fmt.Sprintf("merge rule %s for project %s", priority, projectID), http.StatusInternalServerError)
-		response(w, interface{}(&compute.SecurityPolicyRule{}))
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("failed to update rule %s: %v", priority, err)
+		h.writeError(w, r, err)
 		return
 	}
 
-	if ok, err := h.securityClient.UpdateRule(h.ctx, &resource, projectID, policy); !ok {
-		if err != nil {
-			h.log.Errorf("failed to update rule %s: %v", priority, err)
-			h.HttpError(err, w, projectID, securityTypeRule)
-			response(w, interface{}(&compute.SecurityPolicyRule{}))
-			return
-		}
-	}
-
 	w.WriteHeader(http.StatusOK)
 	return
 }
@@ -2,16 +2,12 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
-	"github.com/nais/armor/pkg/validation"
 	"io"
 	"net/http"
-	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
 	"github.com/nais/armor/pkg/model"
-	"github.com/sirupsen/logrus"
-	"google.golang.org/genproto/googleapis/cloud/compute/v1"
 )
 
 const (
@@ -21,131 +17,143 @@ const (
 )
 
 func (h *Handler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "CreatePolicy",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	if ok, value := parse(projectID); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
 	reqBody, err := io.ReadAll(r.Body)
-	if err != nil || len(reqBody) == 0 {
-		HttpError(w, "error body", http.StatusBadRequest)
+	if err != nil {
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "read request body"))
+		return
+	}
+	if len(reqBody) == 0 {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "request body is required"))
 		return
 	}
 
 	request := model.ArmorRequestPolicy{}
 	err = json.Unmarshal(reqBody, &request)
 	if err != nil {
-		h.log.Errorf("parse policy %v", err)
-		HttpError(w, fmt.Sprintf("parse policy for project %s", projectID), http.StatusBadRequest)
+		h.requestLog(r).Errorf("parse policy %v", err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse policy for project %s", projectID))
+		return
+	}
+
+	resource, err := request.ParsePolicy()
+	if err != nil {
+		h.requestLog(r).Errorf("parse policy %v", err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse policy for project %s", projectID))
 		return
 	}
 
-	resource, err := h.createPolicy(&request, projectID)
+	mutation, err := h.createPolicy(projectID, resource)
 	if err != nil {
-		h.log.Errorf("error creating policy %v", err)
-		h.HttpError(err, w, projectID, securityTypePolicy)
+		h.writeError(w, r, err)
+		return
+	}
+
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "CreatePolicy", mutation)
+		return
+	}
+
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("error creating policy %v", err)
+		h.writeError(w, r, err)
 		return
 	}
 
-	h.log.Debug("inserted policy ", resource.Name)
+	h.requestLog(r).Debug("inserted policy ", resource.Name)
 	w.WriteHeader(http.StatusCreated)
 	return
 }
 
 func (h *Handler) CreateRule(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "CreateRule",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 
 	if ok, value := parse(projectID, policy); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
 	reqBody, err := io.ReadAll(r.Body)
-	if err != nil || len(reqBody) == 0 {
-		HttpError(w, "error body", http.StatusBadRequest)
+	if err != nil {
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "read request body"))
+		return
+	}
+	if len(reqBody) == 0 {
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "request body is required"))
 		return
 	}
 
 	request := model.ArmorRequestRule{}
 	err = json.Unmarshal(reqBody, &request)
 	if err != nil {
-		h.log.Errorf("parse rule %v", err)
-		HttpError(w, fmt.Sprintf("parse request body for project %s: policy %s", projectID, policy), http.StatusBadRequest)
+		h.requestLog(r).Errorf("parse rule %v", err)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "parse request body for project %s: policy %s", projectID, policy))
 		return
 	}
 
 	resource, err := request.ParseRule()
-	if ok, err := validation.Rule(resource); !ok {
-		h.log.Errorf("error validation of rule %v", err)
-		HttpError(w, fmt.Sprintf("validation of rule: %v", err), http.StatusBadRequest)
+	if err != nil {
+		h.requestLog(r).Errorf("parse rule %v", err)
+		h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "parse rule for project %s: policy %s", projectID, policy))
 		return
 	}
 
-	if h.cfg.IsProtectedRule(strconv.Itoa(int(*resource.Priority))) {
-		HttpError(w, fmt.Sprintf("forbidden to create protected priority %d", *resource.Priority), http.StatusBadRequest)
+	mutation, err := h.createRule(projectID, policy, resource)
+	if err != nil {
+		h.requestLog(r).Errorf("error validation of rule %v", err)
+		h.writeError(w, r, err)
 		return
 	}
 
-	if err != nil {
-		h.log.Errorf("parse rule %v", err)
-		HttpError(w, fmt.Sprintf("parse rule for project %s: policy %s", projectID, policy), http.StatusInternalServerError)
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "CreateRule", mutation)
 		return
 	}
 
-	if ok, err := h.securityClient.AddRule(h.ctx, resource, projectID, policy); !ok {
-		if err != nil {
-			h.log.Errorf("error adding rule %v", err)
-			h.HttpError(err, w, projectID, securityTypeRule)
-			return
-		}
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("error adding rule %v", err)
+		h.writeError(w, r, err)
+		return
 	}
 
-	h.log.Debug("inserted rule ", resource.Priority)
+	h.requestLog(r).Debug("inserted rule ", resource.Priority)
 	w.WriteHeader(http.StatusCreated)
 	return
 }
 
 func (h *Handler) SetPolicyBackend(w http.ResponseWriter, r *http.Request) {
-	h.log.WithFields(logrus.Fields{
-		"method": "SetPolicyBackend",
-	})
-
 	projectID := mux.Vars(r)["project"]
 	policy := mux.Vars(r)["policy"]
 	backend := mux.Vars(r)["backend"]
 
 	if ok, value := parse(projectID, policy, backend); !ok {
-		HttpError(w, fmt.Sprintf("unkown parameter: %s", value), http.StatusBadRequest)
+		h.writeError(w, r, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value))
 		return
 	}
 
-	resource, err := h.securityClient.GetPolicy(h.ctx, projectID, policy)
+	mutation, err := h.setPolicyBackend(r.Context(), projectID, policy, backend)
 	if err != nil {
-		h.log.Errorf("failed to get policy %s: %v", policy, err)
-		h.HttpError(err, w, projectID, securityTypePolicy)
-		response(w, interface{}(&compute.SecurityPolicy{}))
+		h.writeError(w, r, err)
+		return
+	}
+
+	if h.isAsync(r) {
+		h.startOperation(w, r, projectID, "SetPolicyBackend", mutation)
 		return
 	}
 
-	if ok, err := h.serviceClient.SetSecurityPolicy(h.ctx, projectID, resource.SelfLink, backend); !ok {
-		if err != nil {
-			h.log.Errorf("error setting policy backend %v", err)
-			h.HttpError(err, w, projectID, securityTypeRule)
-			response(w, interface{}(&compute.SecurityPolicy{}))
-			return
-		}
+	if _, err := mutation(h.eventContext(r.Context())); err != nil {
+		h.requestLog(r).Errorf("error setting policy backend %v", err)
+		h.writeError(w, r, err)
+		return
 	}
 
-	h.log.Debug("policy backend is set to ", backend)
+	h.requestLog(r).Debug("policy backend is set to ", backend)
 	w.WriteHeader(http.StatusCreated)
 }
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	contentTypeProblem = "application/problem+json"
+
+	problemTypePrefix = "urn:armor:problem:"
+	problemGoogleApi  = "google-api"
+	problemRunning    = "operation-running"
+	problemCircuit    = "circuit-open"
+	problemDeadline   = "deadline-exceeded"
+	problemCanceled   = "canceled"
+	problemTooLarge   = "body-too-large"
+)
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type            string   `json:"type"`
+	Title           string   `json:"title"`
+	Status          int      `json:"status"`
+	Detail          string   `json:"detail,omitempty"`
+	Instance        string   `json:"instance,omitempty"`
+	RequestID       string   `json:"request-id,omitempty"`
+	Reasons         []string `json:"reasons,omitempty"`
+	GoogleOperation string   `json:"google-operation,omitempty"`
+	// InvalidParams are the fields of a request body not matching the OpenAPI document.
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam is a field of a request body by its path, e.g. rule.match.config.src_ip_ranges[0].
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+var kindStatus = map[armorerr.Kind]int{
+	armorerr.KindParse:           http.StatusBadRequest,
+	armorerr.KindValidation:      http.StatusBadRequest,
+	armorerr.KindProtectedRule:   http.StatusBadRequest,
+	armorerr.KindNotFound:        http.StatusNotFound,
+	armorerr.KindConflict:        http.StatusConflict,
+	armorerr.KindUnauthenticated: http.StatusUnauthorized,
+	armorerr.KindForbidden:       http.StatusForbidden,
+	armorerr.KindUnavailable:     http.StatusServiceUnavailable,
+	armorerr.KindInternal:        http.StatusInternalServerError,
+}
+
+var grpcStatus = map[codes.Code]int{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Canceled:           http.StatusServiceUnavailable,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.Unimplemented:      http.StatusNotImplemented,
+}
+
+// writeError translates err into a problem response, every error returned to callers goes through here.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := newProblem(err)
+	problem.Instance = r.URL.Path
+	problem.RequestID = requestID(r)
+
+	if problem.Status >= http.StatusInternalServerError {
+		h.requestLog(r).Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	var circuitOpen *google.CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(circuitOpen.Until).Seconds())+1))
+	}
+
+	w.Header().Set("Content-Type", contentTypeProblem)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+func newProblem(err error) *Problem {
+	var running *google.OperationRunningError
+	if errors.As(err, &running) {
+		return &Problem{
+			Type:            problemTypePrefix + problemRunning,
+			Title:           "Operation still running",
+			Status:          http.StatusAccepted,
+			Detail:          "the change was accepted by Google, but is still running after the deadline",
+			GoogleOperation: running.Name,
+		}
+	}
+
+	var circuitOpen *google.CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		return problemWithStatus(problemCircuit, http.StatusServiceUnavailable, err.Error())
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return problemWithStatus(problemTooLarge, http.StatusRequestEntityTooLarge, fmt.Sprintf("the request body exceeds %d bytes", tooLarge.Limit))
+	}
+
+	// Errors from Google are reported with the status and reasons of Google, even when wrapped in an armor error.
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		problem := problemWithStatus(problemGoogleApi, apiErr.Code, apiErr.Message)
+		for _, item := range apiErr.Errors {
+			if item.Reason != "" {
+				problem.Reasons = append(problem.Reasons, item.Reason)
+			}
+		}
+		return problem
+	}
+
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		code, ok := grpcStatus[s.Code()]
+		if !ok {
+			code = http.StatusInternalServerError
+		}
+		problem := problemWithStatus(problemGoogleApi, code, s.Message())
+		problem.Reasons = []string{s.Code().String()}
+		return problem
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return problemWithStatus(problemDeadline, http.StatusGatewayTimeout, "the request did not finish before its deadline")
+	case errors.Is(err, context.Canceled):
+		return problemWithStatus(problemCanceled, http.StatusServiceUnavailable, "the request was canceled")
+	}
+
+	if e, ok := armorerr.As(err); ok {
+		status, ok := kindStatus[e.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		detail := e.Message
+		if status >= http.StatusInternalServerError {
+			// Internal details are logged, not returned.
+			detail = http.StatusText(status)
+		}
+		problem := problemWithStatus(string(e.Kind), status, detail)
+		var invalid *invalidBodyError
+		if errors.As(err, &invalid) {
+			problem.InvalidParams = invalid.params
+		}
+		return problem
+	}
+
+	return problemWithStatus(string(armorerr.KindInternal), http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+func problemWithStatus(name string, status int, detail string) *Problem {
+	title := http.StatusText(status)
+	if title == "" {
+		title = fmt.Sprintf("Status %d", status)
+	}
+	return &Problem{
+		Type:   problemTypePrefix + name,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
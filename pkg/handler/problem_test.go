@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/google"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_newProblem(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		err     error
+		status  int
+		kind    string
+		reasons []string
+	}{
+		{
+			name:    "Google permission denied",
+			err:     fmt.Errorf("get policy: %w", &googleapi.Error{Code: http.StatusForbidden, Message: "denied", Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}}),
+			status:  http.StatusForbidden,
+			kind:    problemGoogleApi,
+			reasons: []string{"forbidden"},
+		},
+		{
+			name:    "Google error returned as armor error",
+			err:     armorerr.Wrap(armorerr.KindNotFound, &googleapi.Error{Code: http.StatusNotFound, Message: "not found", Errors: []googleapi.ErrorItem{{Reason: "notFound"}}}, "get policy"),
+			status:  http.StatusNotFound,
+			kind:    problemGoogleApi,
+			reasons: []string{"notFound"},
+		},
+		{
+			name:    "Google fingerprint mismatch",
+			err:     &googleapi.Error{Code: http.StatusPreconditionFailed, Errors: []googleapi.ErrorItem{{Reason: "conditionNotMet"}}},
+			status:  http.StatusPreconditionFailed,
+			kind:    problemGoogleApi,
+			reasons: []string{"conditionNotMet"},
+		},
+		{
+			name:   "Google rate limit",
+			err:    &googleapi.Error{Code: http.StatusTooManyRequests},
+			status: http.StatusTooManyRequests,
+			kind:   problemGoogleApi,
+		},
+		{
+			name:    "gRPC status",
+			err:     status.Error(codes.AlreadyExists, "exists"),
+			status:  http.StatusConflict,
+			kind:    problemGoogleApi,
+			reasons: []string{"AlreadyExists"},
+		},
+		{
+			name:   "Validation error",
+			err:    armorerr.New(armorerr.KindValidation, "priority is required"),
+			status: http.StatusBadRequest,
+			kind:   string(armorerr.KindValidation),
+		},
+		{
+			name:   "Protected rule",
+			err:    armorerr.New(armorerr.KindProtectedRule, "forbidden to delete protected rule 1000"),
+			status: http.StatusBadRequest,
+			kind:   string(armorerr.KindProtectedRule),
+		},
+		{
+			name:   "Operation still running",
+			err:    &google.OperationRunningError{Name: "operation-1", Err: context.DeadlineExceeded},
+			status: http.StatusAccepted,
+			kind:   problemRunning,
+		},
+		{
+			name:   "Circuit open",
+			err:    &google.CircuitOpenError{Project: "fake-project", Until: time.Now()},
+			status: http.StatusServiceUnavailable,
+			kind:   problemCircuit,
+		},
+		{
+			name:   "Body too large",
+			err:    armorerr.Wrap(armorerr.KindParse, &http.MaxBytesError{Limit: 1024}, "read request body"),
+			status: http.StatusRequestEntityTooLarge,
+			kind:   problemTooLarge,
+		},
+		{
+			name:   "Deadline exceeded",
+			err:    fmt.Errorf("get policy: %w", context.DeadlineExceeded),
+			status: http.StatusGatewayTimeout,
+			kind:   problemDeadline,
+		},
+		{
+			name:   "Unknown error",
+			err:    fmt.Errorf("boom"),
+			status: http.StatusInternalServerError,
+			kind:   string(armorerr.KindInternal),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			problem := newProblem(test.err)
+			assert.Equal(t, test.status, problem.Status)
+			assert.Equal(t, problemTypePrefix+test.kind, problem.Type)
+			assert.Equal(t, test.reasons, problem.Reasons)
+			assert.NotEmpty(t, problem.Title)
+		})
+	}
+}
+
+func Test_writeErrorFromGoogle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"code":403,"message":"permission denied","errors":[{"reason":"forbidden"}]}}`))
+	}))
+	defer server.Close()
+	opts := []option.ClientOption{option.WithEndpoint(server.URL), option.WithoutAuthentication()}
+
+	cfg := &config.Config{DevelopmentMode: true}
+	entry := log.WithField("component", "test")
+	securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, opts...)
+	assert.NoError(t, err)
+	serviceClient, err := google.NewServiceClient(cfg, context.Background(), entry, opts...)
+	assert.NoError(t, err)
+	h := NewHandler(context.Background(), cfg, securityClient, serviceClient, entry)
+
+	r := httptest.NewRequest(http.MethodGet, "/projects/fake-project/policies/test-2", nil)
+	r.Header.Set(headerRequestID, "request-1")
+	r = mux.SetURLVars(r, map[string]string{"project": "fake-project", "policy": "test-2"})
+	w := httptest.NewRecorder()
+	h.requestMiddleware(http.HandlerFunc(h.GetPolicy)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "request-1", w.Header().Get(headerRequestID))
+	assert.Equal(t, contentTypeProblem, w.Header().Get("Content-Type"))
+
+	var problem Problem
+	decoder := json.NewDecoder(w.Body)
+	assert.NoError(t, decoder.Decode(&problem))
+	assert.False(t, decoder.More(), "nothing is written after the problem")
+	assert.Equal(t, "permission denied", problem.Detail)
+	assert.Equal(t, []string{"forbidden"}, problem.Reasons)
+	assert.Equal(t, "request-1", problem.RequestID)
+	assert.Equal(t, "/projects/fake-project/policies/test-2", problem.Instance)
+}
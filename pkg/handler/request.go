@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	headerRequestID   = "X-Request-ID"
+	headerTraceparent = "traceparent"
+
+	maxRequestIDLength = 128
+)
+
+var (
+	validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+	// traceparent as defined by W3C Trace Context, version-traceid-parentid-flags.
+	validTraceparent = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+type requestIDKey struct{}
+
+// requestMiddleware tags the request with an id, echoed in the response, and a request-scoped log entry.
+// The id is taken from X-Request-ID, the trace id of the request, or generated, in that order.
+func (h *Handler) requestMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := traceIDFrom(r.Context(), r.Header)
+		id := requestIDFrom(r.Header, traceID)
+		w.Header().Set(headerRequestID, id)
+
+		fields := logrus.Fields{
+			"request-id":  id,
+			"http-method": r.Method,
+		}
+		if traceID != "" {
+			fields["trace-id"] = traceID
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+			fields["route"] = route.GetName()
+		}
+		for _, key := range []string{"project", "policy", "priority", "backend"} {
+			if value, ok := mux.Vars(r)[key]; ok {
+				fields[key] = value
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logging.WithLogger(ctx, h.log.WithFields(fields))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestLog returns the request-scoped log entry, or the handler log outside of a request.
+func (h *Handler) requestLog(r *http.Request) *logrus.Entry {
+	return h.contextLog(r.Context())
+}
+
+// contextLog returns the log entry of the request or operation of ctx, or the handler log.
+func (h *Handler) contextLog(ctx context.Context) *logrus.Entry {
+	return logging.LoggerFromContext(ctx, h.log)
+}
+
+func requestID(r *http.Request) string {
+	return contextRequestID(r.Context())
+}
+
+func contextRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDFrom returns the X-Request-ID of the caller if it is valid, or else traceID or a generated id.
+func requestIDFrom(header http.Header, traceID string) string {
+	id := header.Get(headerRequestID)
+	if len(id) > maxRequestIDLength || !validRequestID.MatchString(id) {
+		id = traceID
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	return id
+}
+
+// traceIDFrom returns the trace id of the request span, or of the traceparent header when the request is not traced.
+func traceIDFrom(ctx context.Context, header http.Header) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+
+	match := validTraceparent.FindStringSubmatch(header.Get(headerTraceparent))
+	if match == nil || match[1] == "00000000000000000000000000000000" {
+		return ""
+	}
+	return match[1]
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
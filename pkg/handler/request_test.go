@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_requestMiddleware(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		requestID   string
+		traceparent string
+		expected    string
+	}{
+		{
+			name:      "Request id is propagated",
+			requestID: "request-1",
+			expected:  "request-1",
+		},
+		{
+			name:        "Trace id is used without a request id",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expected:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:      "Invalid request id is replaced",
+			requestID: "request 1\n",
+		},
+		{
+			name:        "Invalid traceparent is ignored",
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHandler(context.Background(), &config.Config{}, nil, nil, log.WithField("component", "test"))
+
+			var inner *http.Request
+			router := mux.NewRouter()
+			router.Use(h.requestMiddleware)
+			router.HandleFunc(EndpointGetPolicy, func(w http.ResponseWriter, r *http.Request) {
+				inner = r
+			}).Name("GetPolicy")
+
+			r := httptest.NewRequest(http.MethodGet, "/projects/fake-project/policies/test-2", nil)
+			if test.requestID != "" {
+				r.Header.Set(headerRequestID, test.requestID)
+			}
+			if test.traceparent != "" {
+				r.Header.Set(headerTraceparent, test.traceparent)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			id := w.Header().Get(headerRequestID)
+			if test.expected != "" {
+				assert.Equal(t, test.expected, id)
+			} else {
+				assert.Len(t, id, 32)
+				assert.False(t, strings.Contains(id, " "))
+			}
+			assert.Equal(t, id, requestID(inner))
+
+			entry := logging.LoggerFromContext(inner.Context(), nil)
+			assert.Equal(t, id, entry.Data["request-id"])
+			assert.Equal(t, "GetPolicy", entry.Data["route"])
+			assert.Equal(t, "fake-project", entry.Data["project"])
+			assert.Equal(t, "test-2", entry.Data["policy"])
+		})
+	}
+}
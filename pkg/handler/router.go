@@ -1,38 +1,74 @@
 package handler
 
 import (
+	"github.com/nais/armor/pkg/auth"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
+// SetupHttpRouter routes the API, and the internal probes and metrics unless they are served on their own port.
 func SetupHttpRouter(h *Handler) *mux.Router {
 	log.WithField("method", "SetupHttpRouter").Debug("setting up http router")
 	r := mux.NewRouter().StrictSlash(true)
+	r.Use(metricsMiddleware)
+	r.Use(tracingMiddleware)
+	r.Use(h.requestMiddleware)
 	r.Use(commonMiddleware)
+	r.Use(h.authMiddleware)
+	r.Use(h.callerClientsMiddleware)
+	r.Use(h.deadlineMiddleware)
+	r.Use(h.validationMiddleware)
 
-	r.HandleFunc(EndpointIsAlive, h.isAlive).Methods(http.MethodGet)
-	r.HandleFunc(EndpointIsReady, h.isReady).Methods(http.MethodGet)
+	if h.cfg.InternalPort == "" {
+		internalRoutes(r, h)
+	}
 	// Policy
-	r.HandleFunc(EndpointGetPolicy, h.GetPolicy).Methods(http.MethodGet)
-	r.HandleFunc(EndpointGetPolicies, h.GetPolicies).Methods(http.MethodGet)
-	r.HandleFunc(EndpointCreatePolicy, h.CreatePolicy).Methods(http.MethodPost)
-	r.HandleFunc(EndpointUpdatePolicy, h.UpdatePolicy).Methods(http.MethodPatch)
-	r.HandleFunc(EndpointDeletePolicy, h.DeletePolicy).Methods(http.MethodDelete)
+	r.HandleFunc(EndpointGetPolicy, h.authorize(auth.VerbRead, h.GetPolicy)).Methods(http.MethodGet).Name("GetPolicy")
+	r.HandleFunc(EndpointGetPolicies, h.authorize(auth.VerbRead, h.GetPolicies)).Methods(http.MethodGet).Name("GetPolicies")
+	r.HandleFunc(EndpointCreatePolicy, h.authorize(auth.VerbWritePolicies, h.CreatePolicy)).Methods(http.MethodPost).Name("CreatePolicy")
+	r.HandleFunc(EndpointUpdatePolicy, h.authorize(auth.VerbWritePolicies, h.UpdatePolicy)).Methods(http.MethodPatch).Name("UpdatePolicy")
+	r.HandleFunc(EndpointDeletePolicy, h.authorize(auth.VerbWritePolicies, h.DeletePolicy)).Methods(http.MethodDelete).Name("DeletePolicy")
 	// Rule
-	r.HandleFunc(EndpointGetRule, h.GetRule).Methods(http.MethodGet)
-	r.HandleFunc(EndpointCreateRule, h.CreateRule).Methods(http.MethodPost)
-	r.HandleFunc(EndpointUpdateRule, h.UpdateRule).Methods(http.MethodPatch)
-	r.HandleFunc(EndpointDeleteRule, h.DeleteRule).Methods(http.MethodDelete)
+	r.HandleFunc(EndpointGetRules, h.authorize(auth.VerbRead, h.GetRules)).Methods(http.MethodGet).Name("GetRules")
+	r.HandleFunc(EndpointGetRule, h.authorize(auth.VerbRead, h.GetRule)).Methods(http.MethodGet).Name("GetRule")
+	r.HandleFunc(EndpointCreateRule, h.authorize(auth.VerbWriteRules, h.CreateRule)).Methods(http.MethodPost).Name("CreateRule")
+	r.HandleFunc(EndpointUpdateRule, h.authorize(auth.VerbWriteRules, h.UpdateRule)).Methods(http.MethodPatch).Name("UpdateRule")
+	r.HandleFunc(EndpointDeleteRule, h.authorize(auth.VerbWriteRules, h.DeleteRule)).Methods(http.MethodDelete).Name("DeleteRule")
 	// Preconfigured rules
-	r.HandleFunc(EndpointGetPreConfiguredRules, h.GetPreConfiguredRules).Methods(http.MethodGet)
+	r.HandleFunc(EndpointGetPreConfiguredRules, h.authorize(auth.VerbRead, h.GetPreConfiguredRules)).Methods(http.MethodGet).Name("GetPreConfiguredRules")
 	// Add policy to backend
-	r.HandleFunc(EndpointSetPolicyBackend, h.SetPolicyBackend).Methods(http.MethodPost)
-	r.HandleFunc(EndpointGetBackendServices, h.GetBackendServices).Methods(http.MethodGet)
+	r.HandleFunc(EndpointSetPolicyBackend, h.authorize(auth.VerbAttachBackends, h.SetPolicyBackend)).Methods(http.MethodPost).Name("SetPolicyBackend")
+	r.HandleFunc(EndpointGetBackendServices, h.authorize(auth.VerbRead, h.GetBackendServices)).Methods(http.MethodGet).Name("GetBackendServices")
+	// Asynchronous operations, authorized against the project of the operation
+	r.HandleFunc(EndpointGetOperation, h.GetOperation).Methods(http.MethodGet).Name("GetOperation")
+	// Event stream
+	r.HandleFunc(EndpointGetEvents, h.authorize(auth.VerbRead, h.GetEvents)).Methods(http.MethodGet).Name("GetEvents")
+	// Audit log
+	r.HandleFunc(EndpointGetAudit, h.authorize(auth.VerbRead, h.GetAudit)).Methods(http.MethodGet).Name("GetAudit")
+	// Documentation
+	r.HandleFunc(EndpointOpenAPI, h.GetOpenAPI).Methods(http.MethodGet).Name("GetOpenAPI")
 	return r
 }
 
+// SetupInternalRouter routes the internal probes and metrics, to be served on the internal port.
+func SetupInternalRouter(h *Handler) *mux.Router {
+	r := mux.NewRouter().StrictSlash(true)
+	r.Use(metricsMiddleware)
+	r.Use(h.requestMiddleware)
+	r.Use(commonMiddleware)
+	internalRoutes(r, h)
+	return r
+}
+
+func internalRoutes(r *mux.Router, h *Handler) {
+	r.HandleFunc(EndpointIsAlive, h.isAlive).Methods(http.MethodGet)
+	r.HandleFunc(EndpointIsReady, h.isReady).Methods(http.MethodGet)
+	r.Handle(EndpointMetrics, promhttp.Handler()).Methods(http.MethodGet)
+}
+
 func commonMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
@@ -0,0 +1,689 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/audit"
+	"github.com/nais/armor/pkg/fake"
+	"github.com/nais/armor/pkg/google"
+	"github.com/nais/armor/pkg/memory"
+	"github.com/nais/armor/pkg/operation"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	project = "fake-project"
+
+	validRule = `{"rule":{"priority":20,"action":"deny(403)","preview":false,"match":{"versioned_expr":"SRC_IPS_V1","config":{"src_ip_ranges":["203.0.113.0/24"]}}}}`
+)
+
+// harness serves the router of a handler calling a fake Compute API seeded with testdata/fixtures.yaml.
+type harness struct {
+	t       *testing.T
+	cfg     *config.Config
+	compute *fake.Compute
+	server  *httptest.Server
+}
+
+func newHarness(t *testing.T) *harness {
+	compute := fake.NewCompute()
+	fixtures, err := os.ReadFile("testdata/fixtures.yaml")
+	assert.NoError(t, err)
+	assert.NoError(t, compute.LoadFixtures(fixtures))
+
+	cfg := &config.Config{
+		DevelopmentMode: true,
+		ProtectedRules:  []string{"1000", "2147483647"},
+		ReadDeadline:    5 * time.Second,
+		WriteDeadline:   5 * time.Second,
+	}
+	entry := log.WithField("component", "test")
+	securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, compute.ClientOptions()...)
+	assert.NoError(t, err)
+	serviceClient, err := google.NewServiceClient(cfg, context.Background(), entry, compute.ClientOptions()...)
+	assert.NoError(t, err)
+
+	auditor := audit.New(audit.NewWriterSink(io.Discard), 100, entry)
+	h := NewHandler(context.Background(), cfg, securityClient, serviceClient, entry, WithAuditor(auditor))
+	server := httptest.NewServer(SetupHttpRouter(h))
+	t.Cleanup(server.Close)
+	return &harness{t: t, cfg: cfg, compute: compute, server: server}
+}
+
+func (in *harness) do(method, path, body string) (int, []byte) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r, err := http.NewRequest(method, in.server.URL+path, reader)
+	assert.NoError(in.t, err)
+	response, err := http.DefaultClient.Do(r)
+	if !assert.NoError(in.t, err) {
+		return 0, nil
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	assert.NoError(in.t, err)
+	return response.StatusCode, data
+}
+
+func Test_routes(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		problem string
+		check   func(t *testing.T, h *harness, body []byte)
+	}{
+		// Reads
+		{
+			name:   "List policies",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/policies",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var policies []*compute.SecurityPolicy
+				assert.NoError(t, json.Unmarshal(body, &policies))
+				assert.Len(t, policies, 2)
+			},
+		},
+		{
+			name:   "List policies matching a filter",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/policies?filter=" + url.QueryEscape(`description:protected`),
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var policies []*compute.SecurityPolicy
+				assert.NoError(t, json.Unmarshal(body, &policies))
+				if assert.Len(t, policies, 1) {
+					assert.Equal(t, "test-policy", policies[0].GetName())
+				}
+			},
+		},
+		{
+			name:    "List policies with an invalid filter",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies?filter=" + url.QueryEscape(`name = "test-policy`),
+			status:  http.StatusBadRequest,
+			problem: problemGoogleApi,
+		},
+		{
+			name:   "List fields of policies",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/policies?fields=name,rules.priority",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var policies []map[string]interface{}
+				assert.NoError(t, json.Unmarshal(body, &policies))
+				if assert.Len(t, policies, 2) {
+					assert.Equal(t, map[string]interface{}{
+						"name": "test-policy",
+						"rules": []interface{}{
+							map[string]interface{}{"priority": float64(10)},
+							map[string]interface{}{"priority": float64(1000)},
+							map[string]interface{}{"priority": float64(2147483647)},
+						},
+					}, policies[0])
+				}
+			},
+		},
+		{
+			name:    "List unknown fields of policies",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies?fields=name,rules.unknown",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "List policies with a page size too large",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies?pageSize=501",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:   "List policies of a project without policies",
+			method: http.MethodGet,
+			path:   "/projects/empty-project/policies",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.JSONEq(t, "[]", string(body))
+			},
+		},
+		{
+			name:   "Get policy",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/policies/test-policy",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var policy compute.SecurityPolicy
+				assert.NoError(t, json.Unmarshal(body, &policy))
+				assert.Equal(t, "test-policy", policy.GetName())
+				assert.Len(t, policy.Rules, 3)
+			},
+		},
+		{
+			name:    "Get missing policy",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies/missing",
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		{
+			name:    "Get policy with an invalid name",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies/not_valid",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:   "Get rule",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/policies/test-policy/rules/10",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var rule compute.SecurityPolicyRule
+				assert.NoError(t, json.Unmarshal(body, &rule))
+				assert.Equal(t, "office", rule.GetDescription())
+			},
+		},
+		{
+			name:   "List rules",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/policies/test-policy/rules?action=allow&orderBy=priority+desc&pageSize=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var rules []*compute.SecurityPolicyRule
+				assert.NoError(t, json.Unmarshal(body, &rules))
+				if assert.Len(t, rules, 1) {
+					assert.Equal(t, "default rule", rules[0].GetDescription())
+				}
+
+				response, err := http.Get(h.server.URL + "/projects/fake-project/policies/test-policy/rules?action=allow&pageSize=1")
+				if assert.NoError(t, err) {
+					defer response.Body.Close()
+					assert.Equal(t, "2", response.Header.Get(headerTotalCount), "the default rule and office allow")
+					assert.NotEmpty(t, response.Header.Get(headerNextPageToken))
+				}
+			},
+		},
+		{
+			name:    "List rules with an invalid filter",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies/test-policy/rules?minPriority=-1",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "List rules of a missing policy",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies/missing/rules",
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		{
+			name:    "Get rule with an invalid priority",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies/test-policy/rules/99999999999",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "Get missing rule",
+			method:  http.MethodGet,
+			path:    "/projects/fake-project/policies/test-policy/rules/11",
+			status:  http.StatusBadRequest,
+			problem: problemGoogleApi,
+		},
+		{
+			name:   "List preconfigured rules of a type and version",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/preConfiguredRules?rule-type=sqli&version=v33",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var sets []*compute.WafExpressionSet
+				assert.NoError(t, json.Unmarshal(body, &sets))
+				if assert.Len(t, sets, 1) {
+					assert.Equal(t, "sqli-v33-stable", sets[0].GetId())
+				}
+			},
+		},
+		{
+			name:   "List backend services matching a filter",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/backendServices?fields=name&filter=" + url.QueryEscape(`securityPolicy:*`),
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.JSONEq(t, `[{"name":"protected-backend"}]`, string(body))
+			},
+		},
+		{
+			name:   "List backend services",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/backendServices",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				var backends []*compute.BackendService
+				assert.NoError(t, json.Unmarshal(body, &backends))
+				assert.Len(t, backends, 2)
+			},
+		},
+		// Policies
+		{
+			name:   "Create policy",
+			method: http.MethodPost,
+			path:   "/projects/fake-project/policies",
+			body:   `{"policy":{"name":"new-policy","description":"created"}}`,
+			status: http.StatusCreated,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.Equal(t, "created", h.compute.Policy(project, "new-policy").GetDescription())
+			},
+		},
+		{
+			name:    "Create existing policy",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies",
+			body:    `{"policy":{"name":"test-policy"}}`,
+			status:  http.StatusConflict,
+			problem: problemGoogleApi,
+		},
+		{
+			name:    "Create policy without a name",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies",
+			body:    `{"policy":{"description":"no name"}}`,
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindValidation),
+		},
+		{
+			name:    "Create policy without a body",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "Create policy from invalid JSON",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies",
+			body:    `{"policy":`,
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:   "Update policy",
+			method: http.MethodPatch,
+			path:   "/projects/fake-project/policies/test-policy",
+			body:   `{"policy":{"description":"updated"}}`,
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				policy := h.compute.Policy(project, "test-policy")
+				assert.Equal(t, "updated", policy.GetDescription())
+				assert.Len(t, policy.Rules, 3, "rules are not changed by updating the policy")
+
+				status, body := h.do(http.MethodGet, "/projects/fake-project/audit", "")
+				assert.Equal(t, http.StatusOK, status)
+				var entries []struct {
+					Before *compute.SecurityPolicy `json:"before"`
+				}
+				assert.NoError(t, json.Unmarshal(body, &entries))
+				if assert.Len(t, entries, 1) {
+					assert.Len(t, entries[0].Before.GetRules(), 3, "the audited policy before the update keeps its rules")
+				}
+			},
+		},
+		{
+			name:    "Update missing policy",
+			method:  http.MethodPatch,
+			path:    "/projects/fake-project/policies/missing",
+			body:    `{"policy":{"description":"updated"}}`,
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		{
+			name:   "Delete policy",
+			method: http.MethodDelete,
+			path:   "/projects/fake-project/policies/unused-policy",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.Nil(t, h.compute.Policy(project, "unused-policy"))
+			},
+		},
+		{
+			name:    "Delete policy attached to a backend service",
+			method:  http.MethodDelete,
+			path:    "/projects/fake-project/policies/test-policy",
+			status:  http.StatusBadRequest,
+			problem: problemGoogleApi,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.NotNil(t, h.compute.Policy(project, "test-policy"))
+			},
+		},
+		{
+			name:    "Delete missing policy",
+			method:  http.MethodDelete,
+			path:    "/projects/fake-project/policies/missing",
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		// Rules
+		{
+			name:   "Create rule",
+			method: http.MethodPost,
+			path:   "/projects/fake-project/policies/test-policy/rules",
+			body:   validRule,
+			status: http.StatusCreated,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.Len(t, h.compute.Policy(project, "test-policy").Rules, 4)
+			},
+		},
+		{
+			name:    "Create rule with a priority in use",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/test-policy/rules",
+			body:    strings.Replace(validRule, `"priority":20`, `"priority":10`, 1),
+			status:  http.StatusBadRequest,
+			problem: problemGoogleApi,
+		},
+		{
+			name:    "Create protected rule",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/unused-policy/rules",
+			body:    strings.Replace(validRule, `"priority":20`, `"priority":1000`, 1),
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindProtectedRule),
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.Len(t, h.compute.Policy(project, "unused-policy").Rules, 1)
+			},
+		},
+		{
+			name:    "Create rule without an action",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/test-policy/rules",
+			body:    strings.Replace(validRule, `"action":"deny(403)",`, "", 1),
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindValidation),
+		},
+		{
+			name:    "Create rule with a versioned expression without config",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/test-policy/rules",
+			body:    `{"rule":{"priority":20,"action":"allow","preview":false,"match":{"versioned_expr":"SRC_IPS_V1"}}}`,
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindValidation),
+		},
+		{
+			name:    "Create rule in missing policy",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/missing/rules",
+			body:    validRule,
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		{
+			name:   "Update rule",
+			method: http.MethodPatch,
+			path:   "/projects/fake-project/policies/test-policy/rules/10",
+			body:   `{"rule":{"description":"updated"}}`,
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				rules := h.compute.Policy(project, "test-policy").Rules
+				assert.Equal(t, int32(10), rules[0].GetPriority())
+				assert.Equal(t, "updated", rules[0].GetDescription())
+				assert.Equal(t, "allow", rules[0].GetAction(), "fields not given are kept")
+			},
+		},
+		{
+			name:    "Update protected rule",
+			method:  http.MethodPatch,
+			path:    "/projects/fake-project/policies/test-policy/rules/1000",
+			body:    `{"rule":{"description":"updated"}}`,
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindProtectedRule),
+		},
+		{
+			name:    "Update missing rule",
+			method:  http.MethodPatch,
+			path:    "/projects/fake-project/policies/test-policy/rules/11",
+			body:    `{"rule":{"description":"updated"}}`,
+			status:  http.StatusBadRequest,
+			problem: problemGoogleApi,
+		},
+		{
+			name:    "Update rule with an invalid priority",
+			method:  http.MethodPatch,
+			path:    "/projects/fake-project/policies/test-policy/rules/ten",
+			body:    `{"rule":{"description":"updated"}}`,
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:   "Delete rule",
+			method: http.MethodDelete,
+			path:   "/projects/fake-project/policies/test-policy/rules/10",
+			status: http.StatusOK,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.Len(t, h.compute.Policy(project, "test-policy").Rules, 2)
+			},
+		},
+		{
+			name:    "Delete protected rule",
+			method:  http.MethodDelete,
+			path:    "/projects/fake-project/policies/test-policy/rules/2147483647",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindProtectedRule),
+		},
+		{
+			name:    "Delete rule with an invalid priority",
+			method:  http.MethodDelete,
+			path:    "/projects/fake-project/policies/test-policy/rules/ten",
+			status:  http.StatusBadRequest,
+			problem: string(armorerr.KindParse),
+		},
+		// Backend services
+		{
+			name:   "Attach policy to backend service",
+			method: http.MethodPost,
+			path:   "/projects/fake-project/policies/unused-policy/backendServices/fake-backend",
+			status: http.StatusCreated,
+			check: func(t *testing.T, h *harness, body []byte) {
+				assert.Equal(t, h.compute.Policy(project, "unused-policy").GetSelfLink(), h.compute.Backend(project, "fake-backend").GetSecurityPolicy())
+			},
+		},
+		{
+			name:    "Attach missing policy",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/missing/backendServices/fake-backend",
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		{
+			name:    "Attach policy to missing backend service",
+			method:  http.MethodPost,
+			path:    "/projects/fake-project/policies/unused-policy/backendServices/missing",
+			status:  http.StatusNotFound,
+			problem: problemGoogleApi,
+		},
+		// Audit
+		{
+			name:   "Audit log",
+			method: http.MethodGet,
+			path:   "/projects/fake-project/audit",
+			status: http.StatusOK,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := newHarness(t)
+			status, body := h.do(test.method, test.path, test.body)
+			assert.Equal(t, test.status, status, "body: %s", body)
+
+			if test.problem != "" {
+				var problem Problem
+				assert.NoError(t, json.Unmarshal(body, &problem))
+				assert.Equal(t, problemTypePrefix+test.problem, problem.Type)
+				assert.Equal(t, test.status, problem.Status)
+				assert.NotEmpty(t, problem.Detail)
+			}
+			if test.check != nil {
+				test.check(t, h, body)
+			}
+		})
+	}
+}
+
+func Test_googleErrorsArePropagated(t *testing.T) {
+	h := newHarness(t)
+	h.compute.Inject(fake.Fault{Method: fake.MethodGetPolicy, Code: http.StatusForbidden, Reason: "forbidden"})
+
+	status, body := h.do(http.MethodGet, "/projects/fake-project/policies/test-policy", "")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(body, &problem))
+	assert.Equal(t, []string{"forbidden"}, problem.Reasons)
+}
+
+func Test_mutationsAreAudited(t *testing.T) {
+	h := newHarness(t)
+
+	status, _ := h.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules", validRule)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = h.do(http.MethodDelete, "/projects/fake-project/policies/test-policy/rules/2147483647", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body := h.do(http.MethodGet, "/projects/fake-project/audit", "")
+	assert.Equal(t, http.StatusOK, status)
+	var entries []*audit.Entry
+	assert.NoError(t, json.Unmarshal(body, &entries))
+	if assert.Len(t, entries, 1, "rejected requests do not reach Google and are not audited") {
+		assert.Equal(t, "CreateRule", entries[0].Action)
+		assert.Equal(t, audit.OutcomeSuccess, entries[0].Outcome)
+	}
+}
+
+func Test_asyncMutation(t *testing.T) {
+	h := newHarness(t)
+
+	status, body := h.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules?async=true", validRule)
+	assert.Equal(t, http.StatusAccepted, status)
+	var op operation.Operation
+	assert.NoError(t, json.Unmarshal(body, &op))
+	assert.Equal(t, "CreateRule", op.Action)
+
+	assert.Eventually(t, func() bool {
+		status, body := h.do(http.MethodGet, "/operations/"+op.ID, "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.NoError(t, json.Unmarshal(body, &op))
+		return op.Status != operation.StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, operation.StatusDone, op.Status, op.Error)
+	assert.Len(t, h.compute.Policy(project, "test-policy").Rules, 4)
+}
+
+func Test_asyncMutationDeadline(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.RouteDeadlines = map[string]time.Duration{"CreateRule": 200 * time.Millisecond}
+	h.compute.SetOperationLatency(time.Minute)
+
+	status, body := h.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules?async=true", validRule)
+	assert.Equal(t, http.StatusAccepted, status)
+	var op operation.Operation
+	assert.NoError(t, json.Unmarshal(body, &op))
+
+	assert.Eventually(t, func() bool {
+		status, body := h.do(http.MethodGet, "/operations/"+op.ID, "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.NoError(t, json.Unmarshal(body, &op))
+		return op.Error != ""
+	}, 5*time.Second, 10*time.Millisecond, "waiting for Google ends with the deadline of the route")
+	assert.Equal(t, operation.StatusRunning, op.Status, "the Google operation is still running")
+	assert.NotEmpty(t, op.GoogleOperation)
+	assert.Nil(t, op.Finished)
+}
+
+func Test_inMemoryClients(t *testing.T) {
+	clients := memory.New()
+	clients.AddPolicy(project, &compute.SecurityPolicy{Name: proto.String("test-policy")})
+	clients.AddBackend(project, &compute.BackendService{Name: proto.String("fake-backend")})
+
+	cfg := &config.Config{DevelopmentMode: true, ProtectedRules: []string{"2147483647"}}
+	h := NewHandler(context.Background(), cfg, clients, clients, log.WithField("component", "test"))
+	server := httptest.NewServer(SetupHttpRouter(h))
+	defer server.Close()
+	harness := &harness{t: t, server: server}
+
+	status, _ := harness.do(http.MethodPost, "/projects/fake-project/policies/test-policy/rules", validRule)
+	assert.Equal(t, http.StatusCreated, status)
+	status, body := harness.do(http.MethodGet, "/projects/fake-project/policies/test-policy/rules/20", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "deny(403)")
+
+	status, _ = harness.do(http.MethodPost, "/projects/fake-project/policies/test-policy/backendServices/fake-backend", "")
+	assert.Equal(t, http.StatusCreated, status)
+	status, body = harness.do(http.MethodDelete, "/projects/fake-project/policies/test-policy", "")
+	assert.Equal(t, http.StatusConflict, status, "a policy in use can not be deleted")
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(body, &problem))
+	assert.Equal(t, problemTypePrefix+string(armorerr.KindConflict), problem.Type)
+
+	status, _ = harness.do(http.MethodGet, "/projects/fake-project/policies/missing", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func Test_listPages(t *testing.T) {
+	clients := memory.New()
+	for i := 0; i < 1201; i++ {
+		clients.AddPolicy(project, &compute.SecurityPolicy{Name: proto.String(fmt.Sprintf("policy-%04d", i))})
+	}
+
+	cfg := &config.Config{DevelopmentMode: true, ProtectedRules: []string{"2147483647"}}
+	h := NewHandler(context.Background(), cfg, clients, clients, log.WithField("component", "test"))
+	server := httptest.NewServer(SetupHttpRouter(h))
+	defer server.Close()
+
+	list := func(query string) ([]*compute.SecurityPolicy, string) {
+		response, err := http.Get(server.URL + "/projects/fake-project/policies?" + query)
+		if !assert.NoError(t, err) {
+			return nil, ""
+		}
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var policies []*compute.SecurityPolicy
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&policies))
+		return policies, response.Header.Get(headerNextPageToken)
+	}
+
+	policies, next := list("")
+	assert.Len(t, policies, 1201, "every page is streamed")
+	assert.Empty(t, next)
+
+	var paged []*compute.SecurityPolicy
+	for query := "pageSize=500"; ; {
+		policies, next := list(query)
+		paged = append(paged, policies...)
+		if next == "" {
+			break
+		}
+		query = "pageSize=500&pageToken=" + url.QueryEscape(next)
+	}
+	if assert.Len(t, paged, 1201) {
+		assert.Equal(t, "policy-1200", paged[1200].GetName())
+	}
+}
@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+)
+
+// ruleFilters are the query parameters selecting rules, a page token is only valid for the filters it was issued for.
+var ruleFilters = []string{"action", "preview", "minPriority", "maxPriority", "srcIp", "expressionSet", "description"}
+
+// preconfiguredExpr matches the expression set ids a rule expression evaluates, e.g. evaluatePreconfiguredWaf('sqli-v33-stable').
+var preconfiguredExpr = regexp.MustCompile(`evaluatePreconfigured(?:Expr|Waf)\(\s*'([^']+)'`)
+
+// rulePage is a page of the rules of a policy.
+type rulePage struct {
+	items []*compute.SecurityPolicyRule
+	// next is set when there are more rules, and given as pageToken for the next page.
+	next string
+	// total is the number of rules matching the filters, on all pages.
+	total int
+}
+
+// ruleQuery selects, orders and pages the rules of a policy.
+type ruleQuery struct {
+	action        string
+	preview       *bool
+	minPriority   int32
+	maxPriority   int32
+	srcIP         *netip.Prefix
+	expressionSet string
+	description   string
+
+	orderBy    string
+	descending bool
+	pageSize   int
+	after      *rulePageToken
+	filter     string
+}
+
+// rulePageToken is the position of the last rule of a page in the order it was listed in.
+type rulePageToken struct {
+	Filter   string `json:"f,omitempty"`
+	OrderBy  string `json:"o"`
+	Key      string `json:"k,omitempty"`
+	Priority int32  `json:"p"`
+}
+
+func parseRuleQuery(values url.Values) (*ruleQuery, error) {
+	q := &ruleQuery{
+		action:      values.Get("action"),
+		description: strings.ToLower(values.Get("description")),
+		maxPriority: math.MaxInt32,
+		orderBy:     "priority",
+	}
+
+	if v := values.Get("preview"); v != "" {
+		preview, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, armorerr.New(armorerr.KindParse, "invalid preview: %s", v)
+		}
+		q.preview = &preview
+	}
+	for _, bound := range []struct {
+		name     string
+		priority *int32
+	}{{"minPriority", &q.minPriority}, {"maxPriority", &q.maxPriority}} {
+		if v := values.Get(bound.name); v != "" {
+			p, err := parseInt(v)
+			if err != nil || p < 0 {
+				return nil, armorerr.New(armorerr.KindParse, "invalid %s: %s", bound.name, v)
+			}
+			*bound.priority = p
+		}
+	}
+	if v := values.Get("srcIp"); v != "" {
+		prefix, err := parsePrefix(v)
+		if err != nil {
+			return nil, armorerr.New(armorerr.KindParse, "invalid srcIp, expected an IP address or CIDR range: %s", v)
+		}
+		q.srcIP = &prefix
+	}
+	if v := values.Get("expressionSet"); v != "" {
+		if ok, value := parse(v); !ok {
+			return nil, armorerr.New(armorerr.KindParse, "unknown parameter: %s", value)
+		}
+		q.expressionSet = v
+	}
+
+	if v := values.Get("orderBy"); v != "" {
+		fields := strings.Fields(v)
+		if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != "asc" && fields[1] != "desc") {
+			return nil, armorerr.New(armorerr.KindParse, "invalid orderBy, expected a field optionally followed by asc or desc: %s", v)
+		}
+		switch fields[0] {
+		case "priority", "action", "description":
+		default:
+			return nil, armorerr.New(armorerr.KindParse, "invalid orderBy, rules are ordered by priority, action or description: %s", fields[0])
+		}
+		q.orderBy = fields[0]
+		q.descending = len(fields) == 2 && fields[1] == "desc"
+	}
+	size, token, err := parsePage(values)
+	if err != nil {
+		return nil, err
+	}
+	q.pageSize = size
+
+	filter := url.Values{}
+	for _, name := range ruleFilters {
+		if v, ok := values[name]; ok {
+			filter[name] = v
+		}
+	}
+	q.filter = filter.Encode()
+
+	if token != "" {
+		after, err := q.parsePageToken(token)
+		if err != nil {
+			return nil, err
+		}
+		q.after = after
+	}
+	return q, nil
+}
+
+// parsePrefix parses a CIDR range, or an address as the range of only that address.
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (q *ruleQuery) parsePageToken(value string) (*rulePageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, armorerr.New(armorerr.KindParse, "invalid pageToken")
+	}
+	token := &rulePageToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, armorerr.New(armorerr.KindParse, "invalid pageToken")
+	}
+	if token.Filter != q.filter || token.OrderBy != q.order() {
+		return nil, armorerr.New(armorerr.KindParse, "pageToken was issued for other filters or another order")
+	}
+	return token, nil
+}
+
+func (q *ruleQuery) pageToken(rule *compute.SecurityPolicyRule) string {
+	data, _ := json.Marshal(&rulePageToken{Filter: q.filter, OrderBy: q.order(), Key: q.key(rule), Priority: rule.GetPriority()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (q *ruleQuery) order() string {
+	if q.descending {
+		return q.orderBy + " desc"
+	}
+	return q.orderBy
+}
+
+func (q *ruleQuery) key(rule *compute.SecurityPolicyRule) string {
+	switch q.orderBy {
+	case "action":
+		return rule.GetAction()
+	case "description":
+		return rule.GetDescription()
+	}
+	return ""
+}
+
+// before orders rules by their key, and by priority when the keys are equal, as priorities are unique in a policy.
+func (q *ruleQuery) before(key string, priority int32, otherKey string, otherPriority int32) bool {
+	if key != otherKey {
+		return (key < otherKey) != q.descending
+	}
+	return priority != otherPriority && (priority < otherPriority) != q.descending
+}
+
+func (q *ruleQuery) matches(rule *compute.SecurityPolicyRule) bool {
+	if q.action != "" && rule.GetAction() != q.action && !strings.HasPrefix(rule.GetAction(), q.action+"(") {
+		return false
+	}
+	if q.preview != nil && rule.GetPreview() != *q.preview {
+		return false
+	}
+	if rule.GetPriority() < q.minPriority || rule.GetPriority() > q.maxPriority {
+		return false
+	}
+	if q.description != "" && !strings.Contains(strings.ToLower(rule.GetDescription()), q.description) {
+		return false
+	}
+	if q.srcIP != nil && !containsPrefix(rule.GetMatch().GetConfig().GetSrcIpRanges(), *q.srcIP) {
+		return false
+	}
+	if q.expressionSet != "" && !usesExpressionSet(rule.GetMatch().GetExpr().GetExpression(), q.expressionSet) {
+		return false
+	}
+	return true
+}
+
+// containsPrefix reports whether one of the source ranges of a rule contains the whole prefix.
+func containsPrefix(ranges []string, prefix netip.Prefix) bool {
+	for _, r := range ranges {
+		if r == "*" {
+			return true
+		}
+		source, err := parsePrefix(r)
+		if err != nil {
+			continue
+		}
+		if source.Addr().Is4() == prefix.Addr().Is4() && source.Bits() <= prefix.Bits() && source.Contains(prefix.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesExpressionSet reports whether an expression evaluates the preconfigured expression set, given by its id or
+// by its rule type, e.g. sqli-v33-stable or sqli.
+func usesExpressionSet(expression, set string) bool {
+	for _, match := range preconfiguredExpr.FindAllStringSubmatch(expression, -1) {
+		if match[1] == set || strings.HasPrefix(match[1], set+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns the page of the matching rules after the page token, or all of them without a page size.
+func (q *ruleQuery) apply(rules []*compute.SecurityPolicyRule) *rulePage {
+	matching := make([]*compute.SecurityPolicyRule, 0, len(rules))
+	for _, rule := range rules {
+		if q.matches(rule) {
+			matching = append(matching, rule)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return q.before(q.key(matching[i]), matching[i].GetPriority(), q.key(matching[j]), matching[j].GetPriority())
+	})
+
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(matching), func(i int) bool {
+			return q.before(q.after.Key, q.after.Priority, q.key(matching[i]), matching[i].GetPriority())
+		})
+	}
+	end := len(matching)
+	if q.pageSize > 0 && start+q.pageSize < end {
+		end = start + q.pageSize
+	}
+
+	page := &rulePage{items: matching[start:end], total: len(matching)}
+	if end < len(matching) {
+		page.next = q.pageToken(matching[end-1])
+	}
+	return page
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testRules() []*compute.SecurityPolicyRule {
+	srcIPs := func(priority int32, action, description string, ranges ...string) *compute.SecurityPolicyRule {
+		return &compute.SecurityPolicyRule{
+			Priority:    proto.Int32(priority),
+			Action:      proto.String(action),
+			Description: proto.String(description),
+			Preview:     proto.Bool(false),
+			Match: &compute.SecurityPolicyRuleMatcher{
+				VersionedExpr: proto.String("SRC_IPS_V1"),
+				Config:        &compute.SecurityPolicyRuleMatcherConfig{SrcIpRanges: ranges},
+			},
+		}
+	}
+	expr := func(priority int32, action, description, expression string) *compute.SecurityPolicyRule {
+		return &compute.SecurityPolicyRule{
+			Priority:    proto.Int32(priority),
+			Action:      proto.String(action),
+			Description: proto.String(description),
+			Preview:     proto.Bool(true),
+			Match:       &compute.SecurityPolicyRuleMatcher{Expr: &compute.Expr{Expression: proto.String(expression)}},
+		}
+	}
+	return []*compute.SecurityPolicyRule{
+		srcIPs(2147483647, "allow", "Default rule", "*"),
+		srcIPs(10, "allow", "Office", "192.0.2.0/24", "2001:db8::/32"),
+		srcIPs(20, "deny(403)", "Scanners", "198.51.100.0/24"),
+		srcIPs(30, "deny(404)", "Single scanner", "203.0.113.7"),
+		expr(40, "deny(403)", "Block SQL injection", "evaluatePreconfiguredWaf('sqli-v33-stable', {'sensitivity': 1})"),
+		expr(50, "deny(403)", "Block XSS", "evaluatePreconfiguredExpr('xss-stable') || evaluatePreconfiguredExpr('lfi-stable')"),
+	}
+}
+
+func priorities(rules []*compute.SecurityPolicyRule) []int32 {
+	result := []int32{}
+	for _, rule := range rules {
+		result = append(result, rule.GetPriority())
+	}
+	return result
+}
+
+func Test_ruleQuery(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		query    string
+		expected []int32
+		problem  string
+	}{
+		{
+			name:     "All rules by priority",
+			expected: []int32{10, 20, 30, 40, 50, 2147483647},
+		},
+		{
+			name:     "Action",
+			query:    "action=allow",
+			expected: []int32{10, 2147483647},
+		},
+		{
+			name:     "Action of any deny status",
+			query:    "action=deny",
+			expected: []int32{20, 30, 40, 50},
+		},
+		{
+			name:     "Action of a deny status",
+			query:    "action=deny(404)",
+			expected: []int32{30},
+		},
+		{
+			name:     "Preview",
+			query:    "preview=true",
+			expected: []int32{40, 50},
+		},
+		{
+			name:     "Priority range",
+			query:    "minPriority=20&maxPriority=40",
+			expected: []int32{20, 30, 40},
+		},
+		{
+			name:     "Source IP",
+			query:    "srcIp=192.0.2.10",
+			expected: []int32{10, 2147483647},
+		},
+		{
+			name:     "Source range",
+			query:    "srcIp=198.51.100.128/25&minPriority=1&maxPriority=1000",
+			expected: []int32{20},
+		},
+		{
+			name:     "Source range larger than the ranges of the rules",
+			query:    "srcIp=192.0.0.0/16&maxPriority=1000",
+			expected: []int32{},
+		},
+		{
+			name:     "Source IPv6",
+			query:    "srcIp=2001:db8::1&maxPriority=1000",
+			expected: []int32{10},
+		},
+		{
+			name:     "Single source address",
+			query:    "srcIp=203.0.113.7&maxPriority=1000",
+			expected: []int32{30},
+		},
+		{
+			name:     "Expression set by id",
+			query:    "expressionSet=sqli-v33-stable",
+			expected: []int32{40},
+		},
+		{
+			name:     "Expression set by rule type",
+			query:    "expressionSet=lfi",
+			expected: []int32{50},
+		},
+		{
+			name:     "Description ignoring case",
+			query:    "description=SCANNER",
+			expected: []int32{20, 30},
+		},
+		{
+			name:     "Order by action",
+			query:    "orderBy=action",
+			expected: []int32{10, 2147483647, 20, 40, 50, 30},
+		},
+		{
+			name:     "Order by description descending",
+			query:    "orderBy=description+desc",
+			expected: []int32{30, 20, 10, 2147483647, 50, 40},
+		},
+		{
+			name:     "First page",
+			query:    "pageSize=2&orderBy=priority+desc",
+			expected: []int32{2147483647, 50},
+		},
+		{
+			name:    "Unknown order",
+			query:   "orderBy=match",
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "Page size too large",
+			query:   "pageSize=501",
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "Invalid source IP",
+			query:   "srcIp=203.0.113",
+			problem: string(armorerr.KindParse),
+		},
+		{
+			name:    "Invalid page token",
+			query:   "pageSize=10&pageToken=not-a-token",
+			problem: string(armorerr.KindParse),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := url.ParseQuery(test.query)
+			assert.NoError(t, err)
+			query, err := parseRuleQuery(values)
+			if test.problem != "" {
+				assert.True(t, armorerr.Is(err, armorerr.Kind(test.problem)), "expected %s, got %v", test.problem, err)
+				return
+			}
+			assert.NoError(t, err)
+			page := query.apply(testRules())
+			assert.Equal(t, test.expected, priorities(page.items))
+		})
+	}
+}
+
+func Test_ruleQueryPages(t *testing.T) {
+	for _, order := range []string{"priority", "action", "description desc"} {
+		t.Run(order, func(t *testing.T) {
+			values := url.Values{"orderBy": {order}, "pageSize": {"4"}}
+			query, err := parseRuleQuery(values)
+			assert.NoError(t, err)
+			all := priorities(query.apply(testRules()).items)
+
+			values.Set("pageSize", "2")
+			paged := []int32{}
+			for pages := 0; pages < 10; pages++ {
+				query, err := parseRuleQuery(values)
+				if !assert.NoError(t, err) {
+					return
+				}
+				page := query.apply(testRules())
+				assert.Equal(t, 6, page.total)
+				paged = append(paged, priorities(page.items)...)
+				if page.next == "" {
+					break
+				}
+				values.Set("pageToken", page.next)
+			}
+			assert.Equal(t, priorities(sortedBy(t, order)), paged, "pages are in the order of the rules")
+			assert.Equal(t, all, paged[:4])
+		})
+	}
+
+	first, err := parseRuleQuery(url.Values{"pageSize": {"1"}})
+	assert.NoError(t, err)
+	token := first.apply(testRules()).next
+	_, err = parseRuleQuery(url.Values{"pageSize": {"1"}, "action": {"allow"}, "pageToken": {token}})
+	assert.True(t, armorerr.Is(err, armorerr.KindParse), "a page token is only valid for the filters it was issued for")
+}
+
+func sortedBy(t *testing.T, order string) []*compute.SecurityPolicyRule {
+	query, err := parseRuleQuery(url.Values{"orderBy": {order}})
+	assert.NoError(t, err)
+	return query.apply(testRules()).items
+}
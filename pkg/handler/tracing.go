@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// tracingMiddleware continues the trace of the caller and spans the request with the name of the route.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+
+		name := r.URL.Path
+		attributes := []attribute.KeyValue{semconv.HTTPMethodKey.String(r.Method)}
+		if route := mux.CurrentRoute(r); route != nil {
+			if route.GetName() != "" {
+				name = route.GetName()
+			}
+			if template, err := route.GetPathTemplate(); err == nil {
+				attributes = append(attributes, semconv.HTTPRouteKey.String(template))
+			}
+		}
+		attributes = append(attributes, routeAttributes(r)...)
+
+		ctx, span := tracing.StartServer(ctx, name, attributes...)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			status := recorder.statusCode()
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		}()
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+	})
+}
+
+func routeAttributes(r *http.Request) []attribute.KeyValue {
+	vars := mux.Vars(r)
+	var attributes []attribute.KeyValue
+	if project, ok := vars["project"]; ok {
+		attributes = append(attributes, tracing.AttributeProject.String(project))
+	}
+	if policy, ok := vars["policy"]; ok {
+		attributes = append(attributes, tracing.AttributePolicy.String(policy))
+	}
+	if priority, err := parseInt(vars["priority"]); err == nil {
+		attributes = append(attributes, tracing.AttributePriority.Int64(int64(priority)))
+	}
+	if backend, ok := vars["backend"]; ok {
+		attributes = append(attributes, tracing.AttributeBackend.String(backend))
+	}
+	return attributes
+}
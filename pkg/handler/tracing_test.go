@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nais/armor/config"
+	"github.com/nais/armor/pkg/fake"
+	"github.com/nais/armor/pkg/google"
+	"github.com/nais/armor/pkg/tracing"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	compute := fake.NewCompute()
+	compute.AddPolicy("fake-project", &computepb.SecurityPolicy{Name: proto.String("test-2")})
+	opts := compute.ClientOptions()
+
+	cfg := &config.Config{DevelopmentMode: true}
+	entry := log.WithField("component", "test")
+	securityClient, err := google.NewSecurityClient(cfg, context.Background(), entry, opts...)
+	assert.NoError(t, err)
+	h := NewHandler(context.Background(), cfg, securityClient, nil, entry)
+
+	r := httptest.NewRequest(http.MethodGet, "/projects/fake-project/policies/test-2", nil)
+	r.Header.Set(headerTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	SetupHttpRouter(h).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	call, request := spans[0], spans[1]
+
+	assert.Equal(t, "GetPolicy", request.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext().TraceID().String())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get(headerRequestID))
+
+	assert.Equal(t, "SecurityClient.GetPolicy", call.Name())
+	assert.Equal(t, request.SpanContext().SpanID(), call.Parent().SpanID())
+	assert.Contains(t, call.Attributes(), tracing.AttributeProject.String("fake-project"))
+	assert.Contains(t, call.Attributes(), tracing.AttributePolicy.String("test-2"))
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"github.com/nais/armor/pkg/armorerr"
+)
+
+// invalidBodyError lists the fields of a request body not matching its schema.
+type invalidBodyError struct {
+	params []InvalidParam
+}
+
+func (e *invalidBodyError) Error() string {
+	reasons := make([]string, 0, len(e.params))
+	for _, param := range e.params {
+		reasons = append(reasons, param.Name+": "+param.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// validationMiddleware rejects request bodies not matching the schema of their route in the OpenAPI document.
+// Empty bodies and bodies that are not JSON are left to the handlers to report.
+func (h *Handler) validationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		schema, ok := apiDocument.requests[route.GetName()]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.writeError(w, r, armorerr.Wrap(armorerr.KindParse, err, "read request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := validateBody(schema, body); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validateBody(schema *openapi3.Schema, body []byte) error {
+	var value interface{}
+	if len(body) == 0 || json.Unmarshal(body, &value) != nil {
+		return nil
+	}
+
+	err := schema.VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return nil
+	}
+	invalid := &invalidBodyError{params: invalidParams(err, nil)}
+	sort.Slice(invalid.params, func(i, j int) bool {
+		return invalid.params[i].Name < invalid.params[j].Name
+	})
+	return armorerr.Wrap(armorerr.KindValidation, invalid, "invalid request body: %s", invalid.Error())
+}
+
+// invalidParams flattens the errors of a schema validation into the fields they are about.
+func invalidParams(err error, params []InvalidParam) []InvalidParam {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		for _, e := range multi {
+			params = invalidParams(e, params)
+		}
+		return params
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return append(params, InvalidParam{Name: "", Reason: err.Error()})
+	}
+	// A composed schema reports the error of the schema it is composed of.
+	if schemaErr.Origin != nil {
+		return invalidParams(schemaErr.Origin, params)
+	}
+	return append(params, InvalidParam{Name: fieldPath(schemaErr.JSONPointer()), Reason: schemaErr.Reason})
+}
+
+// fieldPath joins a JSON pointer as a field path, e.g. rule.match.config.src_ip_ranges[0].
+func fieldPath(pointer []string) string {
+	var path strings.Builder
+	for _, segment := range pointer {
+		if _, err := strconv.Atoi(segment); err == nil {
+			path.WriteString("[" + segment + "]")
+			continue
+		}
+		if path.Len() > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(segment)
+	}
+	return path.String()
+}
@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying the request-scoped log entry.
+func WithLogger(ctx context.Context, log *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// LoggerFromContext returns the log entry in the context, or fallback when there is none.
+// A nil fallback falls back to the standard logger.
+func LoggerFromContext(ctx context.Context, fallback *logrus.Entry) *logrus.Entry {
+	if log, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return log
+	}
+	if fallback == nil {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	return fallback
+}
@@ -0,0 +1,703 @@
+// Package memory implements the Cloud Armor operations of package cloudarmor in memory, for tests and tools
+// running without Google. Changes are applied immediately, their operations are done when returned unless an
+// operation latency is set. Package fake serves the same store as the Compute REST API.
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/nais/armor/pkg/filter"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	selfLinkPrefix = "https://www.googleapis.com/compute/v1/"
+
+	defaultRulePriority = 2147483647
+	// defaultPageSize is the number of resources on a page of a list of the Compute API.
+	defaultPageSize = 500
+
+	// Reasons of the errors of the Compute API.
+	reasonNotFound      = "notFound"
+	reasonAlreadyExists = "alreadyExists"
+	reasonInvalid       = "invalid"
+	reasonConditionNot  = "conditionNotMet"
+	reasonResourceInUse = "resourceInUseByAnotherResource"
+)
+
+var (
+	_ cloudarmor.SecurityPolicies = &Compute{}
+	_ cloudarmor.BackendServices  = &Compute{}
+)
+
+// Compute keeps security policies, backend services and operations per project, projects are created on first use.
+type Compute struct {
+	mu               sync.Mutex
+	projects         map[string]*project
+	preconfigured    []*computepb.WafExpressionSet
+	sequence         uint64
+	operationLatency time.Duration
+}
+
+type project struct {
+	policies   map[string]*computepb.SecurityPolicy
+	backends   map[string]*computepb.BackendService
+	operations map[string]*operation
+}
+
+type operation struct {
+	proto *computepb.Operation
+	done  time.Time
+}
+
+// Error is an armor error of Compute, with the status, reason and message the Compute API answers the same call
+// with.
+type Error struct {
+	Code   int
+	Reason string
+	// Message is the message of the Compute API, the armor error in the chain has the message for callers of armor.
+	Message string
+	err     error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+func New() *Compute {
+	return &Compute{
+		projects: make(map[string]*project),
+	}
+}
+
+// AddPolicy stores a policy in the project as is, adding the default rule and output only fields when missing.
+func (c *Compute) AddPolicy(projectID string, policy *computepb.SecurityPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.project(projectID).policies[policy.GetName()] = c.newPolicy(projectID, proto.Clone(policy).(*computepb.SecurityPolicy))
+}
+
+// AddBackend stores a backend service in the project as is, adding output only fields when missing.
+func (c *Compute) AddBackend(projectID string, backend *computepb.BackendService) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	backend = proto.Clone(backend).(*computepb.BackendService)
+	if backend.Id == nil {
+		backend.Id = proto.Uint64(c.next())
+	}
+	if backend.SelfLink == nil {
+		backend.SelfLink = proto.String(selfLink(projectID, "backendServices", backend.GetName()))
+	}
+	if backend.Kind == nil {
+		backend.Kind = proto.String("compute#backendService")
+	}
+	c.project(projectID).backends[backend.GetName()] = backend
+}
+
+// SetPreconfiguredExpressionSets sets the preconfigured WAF expression sets of every project.
+func (c *Compute) SetPreconfiguredExpressionSets(sets []*computepb.WafExpressionSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.preconfigured = clones(sets)
+}
+
+// SetOperationLatency keeps the operations of later changes running for d.
+func (c *Compute) SetOperationLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operationLatency = d
+}
+
+// GetOperation returns an operation of a change in the project, done once the operation latency has passed.
+func (c *Compute) GetOperation(ctx context.Context, projectID, name string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "get operation")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op, ok := c.project(projectID).operations[name]
+	if !ok {
+		return nil, notFound(projectID, "operations", name, "operation")
+	}
+	if op.proto.GetStatus() != computepb.Operation_DONE && !time.Now().Before(op.done) {
+		op.proto.Status = computepb.Operation_DONE.Enum()
+		op.proto.Progress = proto.Int32(100)
+		op.proto.EndTime = proto.String(op.done.Format(time.RFC3339))
+	}
+	return proto.Clone(op.proto).(*computepb.Operation), nil
+}
+
+func (c *Compute) ListPolicies(ctx context.Context, projectID string) ([]*computepb.SecurityPolicy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "list policies")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policies := make([]*computepb.SecurityPolicy, 0, len(c.project(projectID).policies))
+	for _, policy := range c.project(projectID).policies {
+		policies = append(policies, proto.Clone(policy).(*computepb.SecurityPolicy))
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].GetName() < policies[j].GetName()
+	})
+	return policies, nil
+}
+
+func (c *Compute) ListPoliciesPage(ctx context.Context, projectID string, opts cloudarmor.ListOptions) ([]*computepb.SecurityPolicy, string, error) {
+	f, err := filter.Parse(opts.Filter)
+	if err != nil {
+		return nil, "", invalid(armorerr.KindParse, fmt.Sprintf("Invalid list filter expression: %v", err), "invalid filter: %v", err)
+	}
+	policies, err := c.ListPolicies(ctx, projectID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	matching := policies[:0]
+	for _, policy := range policies {
+		if f.Match(policy) {
+			matching = append(matching, policy)
+		}
+	}
+	start, end, next, err := page(opts, len(matching))
+	if err != nil {
+		return nil, "", err
+	}
+	return matching[start:end], next, nil
+}
+
+func (c *Compute) GetPolicy(ctx context.Context, projectID, policyName string) (*computepb.SecurityPolicy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "get policy")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(policy).(*computepb.SecurityPolicy), nil
+}
+
+func (c *Compute) CreatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "insert policy")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if policy.GetName() == "" {
+		return nil, invalid(armorerr.KindValidation, "Invalid value for field 'resource.name': ''. Must be a match of regex '[a-z]([-a-z0-9]*[a-z0-9])?'",
+			"policy name is required")
+	}
+	p := c.project(projectID)
+	if _, ok := p.policies[policy.GetName()]; ok {
+		return nil, apiError(armorerr.KindConflict, http.StatusConflict, reasonAlreadyExists,
+			fmt.Sprintf("The resource '%s' already exists", resourcePath(projectID, "securityPolicies", policy.GetName())),
+			"policy %s already exists in project %s", policy.GetName(), projectID)
+	}
+
+	policy = proto.Clone(policy).(*computepb.SecurityPolicy)
+	policy.Id, policy.CreationTimestamp, policy.SelfLink, policy.Fingerprint = nil, nil, nil, nil
+	policy = c.newPolicy(projectID, policy)
+	if err := validateRules(policy.Rules); err != nil {
+		return nil, err
+	}
+	p.policies[policy.GetName()] = policy
+	return c.operation(projectID, "insert", policy.GetSelfLink(), policy.GetId()), nil
+}
+
+// UpdatePolicy updates the fields set in policy, except the rules which are changed with the rule methods.
+func (c *Compute) UpdatePolicy(ctx context.Context, policy *computepb.SecurityPolicy, projectID, policyName string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "update policy")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Fingerprint != nil && policy.GetFingerprint() != current.GetFingerprint() {
+		return nil, apiError(armorerr.KindConflict, http.StatusPreconditionFailed, reasonConditionNot, "Invalid fingerprint.",
+			"policy %s was changed since it was read", policyName)
+	}
+
+	patch := proto.Clone(policy).(*computepb.SecurityPolicy)
+	patch.Rules = nil
+	patch.Name, patch.Id, patch.CreationTimestamp, patch.SelfLink, patch.Fingerprint, patch.Kind = nil, nil, nil, nil, nil, nil
+	proto.Merge(current, patch)
+	c.changed(current)
+	return c.operation(projectID, "patch", current.GetSelfLink(), current.GetId()), nil
+}
+
+func (c *Compute) DeletePolicy(ctx context.Context, projectID, policyName string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "delete policy")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	p := c.project(projectID)
+	for _, backend := range p.backends {
+		if backend.GetSecurityPolicy() == policy.GetSelfLink() {
+			return nil, apiError(armorerr.KindConflict, http.StatusBadRequest, reasonResourceInUse,
+				fmt.Sprintf("The security_policy resource '%s' is already being used by '%s'",
+					resourcePath(projectID, "securityPolicies", policyName), resourcePath(projectID, "backendServices", backend.GetName())),
+				"policy %s is used by backend service %s", policyName, backend.GetName())
+		}
+	}
+	delete(p.policies, policyName)
+	return c.operation(projectID, "delete", policy.GetSelfLink(), policy.GetId()), nil
+}
+
+func (c *Compute) GetRule(ctx context.Context, priority *int32, projectID, policyName string) (*computepb.SecurityPolicyRule, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "get rule")
+	}
+	if priority == nil {
+		return nil, priorityRequired()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	i := ruleIndex(policy, *priority)
+	if i < 0 {
+		return nil, noRule(policyName, *priority)
+	}
+	return proto.Clone(policy.Rules[i]).(*computepb.SecurityPolicyRule), nil
+}
+
+func (c *Compute) AddRule(ctx context.Context, rule *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "add rule")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	rule = proto.Clone(rule).(*computepb.SecurityPolicyRule)
+	if err := validateRules(append([]*computepb.SecurityPolicyRule{rule}, policy.Rules...)); err != nil {
+		return nil, err
+	}
+
+	if rule.Kind == nil {
+		rule.Kind = proto.String("compute#securityPolicyRule")
+	}
+	policy.Rules = append(policy.Rules, rule)
+	c.changed(policy)
+	return c.operation(projectID, "addRule", policy.GetSelfLink(), policy.GetId()), nil
+}
+
+// UpdateRule replaces the rule with the priority of rule.
+func (c *Compute) UpdateRule(ctx context.Context, rule *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error) {
+	if rule.Priority == nil {
+		return nil, priorityRequired()
+	}
+	return c.PatchRule(ctx, rule.GetPriority(), rule, projectID, policyName)
+}
+
+// PatchRule replaces the rule with priority by rule, which keeps the priority unless it has one of its own.
+func (c *Compute) PatchRule(ctx context.Context, priority int32, rule *computepb.SecurityPolicyRule, projectID, policyName string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "patch rule")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	i := ruleIndex(policy, priority)
+	if i < 0 {
+		return nil, noRule(policyName, priority)
+	}
+
+	rule = proto.Clone(rule).(*computepb.SecurityPolicyRule)
+	if rule.Priority == nil {
+		rule.Priority = proto.Int32(priority)
+	}
+	if rule.Kind == nil {
+		rule.Kind = proto.String("compute#securityPolicyRule")
+	}
+	rules := append([]*computepb.SecurityPolicyRule{}, policy.Rules...)
+	rules[i] = rule
+	if err := validateRules(rules); err != nil {
+		return nil, err
+	}
+	policy.Rules = rules
+	c.changed(policy)
+	return c.operation(projectID, "patchRule", policy.GetSelfLink(), policy.GetId()), nil
+}
+
+func (c *Compute) RemoveRule(ctx context.Context, priority *int32, projectID, policyName string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "remove rule")
+	}
+	if priority == nil {
+		return nil, priorityRequired()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy, err := c.policy(projectID, policyName)
+	if err != nil {
+		return nil, err
+	}
+	i := ruleIndex(policy, *priority)
+	if i < 0 {
+		return nil, noRule(policyName, *priority)
+	}
+	if *priority == defaultRulePriority {
+		return nil, invalid(armorerr.KindValidation, "The default rule cannot be removed from the security policy.",
+			"the default rule can not be removed from policy %s", policyName)
+	}
+
+	policy.Rules = append(policy.Rules[:i:i], policy.Rules[i+1:]...)
+	c.changed(policy)
+	return c.operation(projectID, "removeRule", policy.GetSelfLink(), policy.GetId()), nil
+}
+
+func (c *Compute) ListPreConfiguredRules(ctx context.Context, projectID string) ([]*computepb.WafExpressionSet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "get preconfigured")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return clones(c.preconfigured), nil
+}
+
+func (c *Compute) ListBackendServices(ctx context.Context, projectID string) ([]*computepb.BackendService, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "list backend services")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backends := make([]*computepb.BackendService, 0, len(c.project(projectID).backends))
+	for _, backend := range c.project(projectID).backends {
+		backends = append(backends, proto.Clone(backend).(*computepb.BackendService))
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].GetName() < backends[j].GetName()
+	})
+	return backends, nil
+}
+
+func (c *Compute) ListBackendServicesPage(ctx context.Context, projectID string, opts cloudarmor.ListOptions) ([]*computepb.BackendService, string, error) {
+	f, err := filter.Parse(opts.Filter)
+	if err != nil {
+		return nil, "", invalid(armorerr.KindParse, fmt.Sprintf("Invalid list filter expression: %v", err), "invalid filter: %v", err)
+	}
+	backends, err := c.ListBackendServices(ctx, projectID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	matching := backends[:0]
+	for _, backend := range backends {
+		if f.Match(backend) {
+			matching = append(matching, backend)
+		}
+	}
+	start, end, next, err := page(opts, len(matching))
+	if err != nil {
+		return nil, "", err
+	}
+	return matching[start:end], next, nil
+}
+
+func (c *Compute) GetBackendService(ctx context.Context, projectID, backendService string) (*computepb.BackendService, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "get backend service")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backend, err := c.backend(projectID, backendService)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(backend).(*computepb.BackendService), nil
+}
+
+// SetSecurityPolicy attaches the policy with the given self link to the backend service, an empty link detaches it.
+func (c *Compute) SetSecurityPolicy(ctx context.Context, projectID string, policy *string, backendService string) (*computepb.Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, armorerr.Wrap(armorerr.KindUnavailable, err, "insert policy to backend")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backend, err := c.backend(projectID, backendService)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil || *policy == "" {
+		backend.SecurityPolicy = nil
+	} else {
+		attached, err := c.policy(projectID, path.Base(*policy))
+		if err != nil {
+			return nil, err
+		}
+		backend.SecurityPolicy = proto.String(attached.GetSelfLink())
+	}
+	backend.Fingerprint = proto.String(c.fingerprint())
+	return c.operation(projectID, "setSecurityPolicy", backend.GetSelfLink(), backend.GetId()), nil
+}
+
+// Probe always succeeds, unless ctx is done.
+func (c *Compute) Probe(ctx context.Context, projectID string) error {
+	if err := ctx.Err(); err != nil {
+		return armorerr.Wrap(armorerr.KindUnavailable, err, "probe")
+	}
+	return nil
+}
+
+// project returns the state of a project, projects are created on first use.
+func (c *Compute) project(projectID string) *project {
+	p, ok := c.projects[projectID]
+	if !ok {
+		p = &project{
+			policies:   make(map[string]*computepb.SecurityPolicy),
+			backends:   make(map[string]*computepb.BackendService),
+			operations: make(map[string]*operation),
+		}
+		c.projects[projectID] = p
+	}
+	return p
+}
+
+func (c *Compute) policy(projectID, name string) (*computepb.SecurityPolicy, error) {
+	policy, ok := c.project(projectID).policies[name]
+	if !ok {
+		return nil, notFound(projectID, "securityPolicies", name, "policy")
+	}
+	return policy, nil
+}
+
+func (c *Compute) backend(projectID, name string) (*computepb.BackendService, error) {
+	backend, ok := c.project(projectID).backends[name]
+	if !ok {
+		return nil, notFound(projectID, "backendServices", name, "backend service")
+	}
+	return backend, nil
+}
+
+// newPolicy fills in the output only fields of a policy, and the default rule every policy has.
+func (c *Compute) newPolicy(projectID string, policy *computepb.SecurityPolicy) *computepb.SecurityPolicy {
+	if policy.Id == nil {
+		policy.Id = proto.Uint64(c.next())
+	}
+	if policy.CreationTimestamp == nil {
+		policy.CreationTimestamp = proto.String(time.Now().Format(time.RFC3339))
+	}
+	if policy.SelfLink == nil {
+		policy.SelfLink = proto.String(selfLink(projectID, "securityPolicies", policy.GetName()))
+	}
+	if policy.Kind == nil {
+		policy.Kind = proto.String("compute#securityPolicy")
+	}
+	if policy.Type == nil {
+		policy.Type = proto.String(computepb.SecurityPolicy_CLOUD_ARMOR.String())
+	}
+	if policy.Fingerprint == nil {
+		policy.Fingerprint = proto.String(c.fingerprint())
+	}
+	if ruleIndex(policy, defaultRulePriority) < 0 {
+		policy.Rules = append(policy.Rules, &computepb.SecurityPolicyRule{
+			Action:      proto.String("allow"),
+			Description: proto.String("default rule"),
+			Priority:    proto.Int32(defaultRulePriority),
+			Match: &computepb.SecurityPolicyRuleMatcher{
+				VersionedExpr: proto.String(computepb.SecurityPolicyRuleMatcher_SRC_IPS_V1.String()),
+				Config:        &computepb.SecurityPolicyRuleMatcherConfig{SrcIpRanges: []string{"*"}},
+			},
+		})
+	}
+	for _, rule := range policy.Rules {
+		if rule.Kind == nil {
+			rule.Kind = proto.String("compute#securityPolicyRule")
+		}
+	}
+	return policy
+}
+
+// changed gives a changed policy a new fingerprint, and keeps its rules in order of priority.
+func (c *Compute) changed(policy *computepb.SecurityPolicy) {
+	sort.SliceStable(policy.Rules, func(i, j int) bool {
+		return policy.Rules[i].GetPriority() < policy.Rules[j].GetPriority()
+	})
+	policy.Fingerprint = proto.String(c.fingerprint())
+}
+
+// operation records an operation of a change of the target, running until the operation latency has passed.
+func (c *Compute) operation(projectID, operationType, targetLink string, targetID uint64) *computepb.Operation {
+	name := fmt.Sprintf("operation-%d-memory", c.next())
+	now := time.Now()
+	op := &computepb.Operation{
+		Id:            proto.Uint64(c.next()),
+		Kind:          proto.String("compute#operation"),
+		Name:          proto.String(name),
+		OperationType: proto.String(operationType),
+		TargetLink:    proto.String(targetLink),
+		TargetId:      proto.Uint64(targetID),
+		Status:        computepb.Operation_DONE.Enum(),
+		Progress:      proto.Int32(100),
+		InsertTime:    proto.String(now.Format(time.RFC3339)),
+		StartTime:     proto.String(now.Format(time.RFC3339)),
+		EndTime:       proto.String(now.Format(time.RFC3339)),
+		SelfLink:      proto.String(selfLink(projectID, "operations", name)),
+	}
+	if c.operationLatency > 0 {
+		op.Status = computepb.Operation_RUNNING.Enum()
+		op.Progress = proto.Int32(0)
+		op.EndTime = nil
+	}
+	c.project(projectID).operations[name] = &operation{proto: op, done: now.Add(c.operationLatency)}
+	return proto.Clone(op).(*computepb.Operation)
+}
+
+func (c *Compute) next() uint64 {
+	c.sequence++
+	return c.sequence
+}
+
+func (c *Compute) fingerprint() string {
+	return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, c.next()))
+}
+
+func validateRules(rules []*computepb.SecurityPolicyRule) error {
+	seen := make(map[int32]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Priority == nil {
+			return priorityRequired()
+		}
+		if seen[rule.GetPriority()] {
+			return invalid(armorerr.KindConflict, fmt.Sprintf("Cannot have rules with the same priorities: %d", rule.GetPriority()),
+				"a rule with priority %d already exists", rule.GetPriority())
+		}
+		seen[rule.GetPriority()] = true
+	}
+	return nil
+}
+
+func ruleIndex(policy *computepb.SecurityPolicy, priority int32) int {
+	for i, rule := range policy.Rules {
+		if rule.GetPriority() == priority {
+			return i
+		}
+	}
+	return -1
+}
+
+// page returns the bounds of the page of a list of total resources, and the token of the next page.
+func page(opts cloudarmor.ListOptions, total int) (int, int, string, error) {
+	start := 0
+	if opts.PageToken != "" {
+		offset, err := strconv.Atoi(opts.PageToken)
+		if err != nil || offset < 0 || offset > total {
+			return 0, 0, "", invalid(armorerr.KindParse, fmt.Sprintf("Invalid value for field 'pageToken': '%s'", opts.PageToken),
+				"invalid page token: %s", opts.PageToken)
+		}
+		start = offset
+	}
+
+	size := opts.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	end := start + size
+	if end >= total {
+		return start, total, "", nil
+	}
+	return start, end, strconv.Itoa(end), nil
+}
+
+func clones(sets []*computepb.WafExpressionSet) []*computepb.WafExpressionSet {
+	result := make([]*computepb.WafExpressionSet, 0, len(sets))
+	for _, set := range sets {
+		result = append(result, proto.Clone(set).(*computepb.WafExpressionSet))
+	}
+	return result
+}
+
+// apiError returns an armor error of kind, answered by the Compute API with code, reason and message.
+func apiError(kind armorerr.Kind, code int, reason, message, format string, args ...interface{}) error {
+	return &Error{Code: code, Reason: reason, Message: message, err: armorerr.New(kind, format, args...)}
+}
+
+// invalid returns an armor error of kind, answered by the Compute API as an invalid request.
+func invalid(kind armorerr.Kind, message, format string, args ...interface{}) error {
+	return apiError(kind, http.StatusBadRequest, reasonInvalid, message, format, args...)
+}
+
+func notFound(projectID, collection, name, noun string) error {
+	return apiError(armorerr.KindNotFound, http.StatusNotFound, reasonNotFound,
+		fmt.Sprintf("The resource '%s' was not found", resourcePath(projectID, collection, name)),
+		"%s %s not found in project %s", noun, name, projectID)
+}
+
+// noRule is the error of a missing rule, which the Compute API answers as an invalid priority.
+func noRule(policyName string, priority int32) error {
+	return invalid(armorerr.KindNotFound, fmt.Sprintf("Invalid value for field 'priority': '%d'. The priority does not exist in the security policy.", priority),
+		"policy %s has no rule with priority %d", policyName, priority)
+}
+
+func priorityRequired() error {
+	return invalid(armorerr.KindValidation, "Required field 'priority' not specified", "rule priority is required")
+}
+
+func resourcePath(projectID, collection, name string) string {
+	return fmt.Sprintf("projects/%s/global/%s/%s", projectID, collection, name)
+}
+
+func selfLink(projectID, collection, name string) string {
+	return selfLinkPrefix + resourcePath(projectID, collection, name)
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/pkg/armorerr"
+	"github.com/nais/armor/pkg/cloudarmor"
+	"github.com/stretchr/testify/assert"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const testProject = "memory-project"
+
+func Test_policyLifecycle(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+
+	_, err := c.GetPolicy(ctx, testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindNotFound))
+
+	op, err := c.CreatePolicy(ctx, &computepb.SecurityPolicy{Name: proto.String("policy")}, testProject)
+	assert.NoError(t, err)
+	assert.Equal(t, computepb.Operation_DONE, op.GetStatus())
+	_, err = c.CreatePolicy(ctx, &computepb.SecurityPolicy{Name: proto.String("policy")}, testProject)
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict))
+
+	policy, err := c.GetPolicy(ctx, testProject, "policy")
+	assert.NoError(t, err)
+	assert.Len(t, policy.Rules, 1, "a new policy has the default rule")
+	assert.Equal(t, "https://www.googleapis.com/compute/v1/projects/memory-project/global/securityPolicies/policy", policy.GetSelfLink())
+
+	priority := proto.Int32(10)
+	_, err = c.AddRule(ctx, &computepb.SecurityPolicyRule{Priority: priority, Action: proto.String("deny(403)")}, testProject, "policy")
+	assert.NoError(t, err)
+	_, err = c.AddRule(ctx, &computepb.SecurityPolicyRule{Priority: priority, Action: proto.String("allow")}, testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict), "priorities are unique")
+
+	_, err = c.UpdateRule(ctx, &computepb.SecurityPolicyRule{Priority: priority, Action: proto.String("allow")}, testProject, "policy")
+	assert.NoError(t, err)
+	rule, err := c.GetRule(ctx, priority, testProject, "policy")
+	assert.NoError(t, err)
+	assert.Equal(t, "allow", rule.GetAction())
+
+	_, err = c.UpdatePolicy(ctx, &computepb.SecurityPolicy{Description: proto.String("stale"), Fingerprint: policy.Fingerprint}, testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict), "the policy changed since it was read")
+
+	_, err = c.RemoveRule(ctx, proto.Int32(2147483647), testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindValidation), "the default rule stays")
+	_, err = c.RemoveRule(ctx, priority, testProject, "policy")
+	assert.NoError(t, err)
+	_, err = c.GetRule(ctx, priority, testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindNotFound))
+
+	_, err = c.DeletePolicy(ctx, testProject, "policy")
+	assert.NoError(t, err)
+	policies, err := c.ListPolicies(ctx, testProject)
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+}
+
+func Test_backendServices(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	c.AddPolicy(testProject, &computepb.SecurityPolicy{Name: proto.String("policy")})
+	c.AddBackend(testProject, &computepb.BackendService{Name: proto.String("backend")})
+
+	policy, err := c.GetPolicy(ctx, testProject, "policy")
+	assert.NoError(t, err)
+	_, err = c.SetSecurityPolicy(ctx, testProject, policy.SelfLink, "backend")
+	assert.NoError(t, err)
+
+	backends, err := c.ListBackendServices(ctx, testProject)
+	assert.NoError(t, err)
+	if assert.Len(t, backends, 1) {
+		assert.Equal(t, policy.GetSelfLink(), backends[0].GetSecurityPolicy())
+	}
+
+	backends, _, err = c.ListBackendServicesPage(ctx, testProject, cloudarmor.ListOptions{Filter: `securityPolicy = ""`})
+	assert.NoError(t, err)
+	assert.Empty(t, backends, "lists are filtered")
+
+	_, err = c.DeletePolicy(ctx, testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict), "a policy in use can not be deleted")
+
+	_, err = c.SetSecurityPolicy(ctx, testProject, proto.String(""), "backend")
+	assert.NoError(t, err)
+	_, err = c.DeletePolicy(ctx, testProject, "policy")
+	assert.NoError(t, err)
+
+	_, err = c.SetSecurityPolicy(ctx, testProject, policy.SelfLink, "backend")
+	assert.True(t, armorerr.Is(err, armorerr.KindNotFound))
+}
+
+func Test_listPages(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	for _, name := range []string{"policy-c", "policy-a", "other", "policy-b"} {
+		c.AddPolicy(testProject, &computepb.SecurityPolicy{Name: proto.String(name)})
+	}
+
+	opts := cloudarmor.ListOptions{Filter: "name eq policy-.*", PageSize: 2}
+	policies, next, err := c.ListPoliciesPage(ctx, testProject, opts)
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 2) {
+		assert.Equal(t, "policy-a", policies[0].GetName())
+	}
+	assert.NotEmpty(t, next)
+
+	opts.PageToken = next
+	policies, next, err = c.ListPoliciesPage(ctx, testProject, opts)
+	assert.NoError(t, err)
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "policy-c", policies[0].GetName())
+	}
+	assert.Empty(t, next)
+
+	_, _, err = c.ListPoliciesPage(ctx, testProject, cloudarmor.ListOptions{Filter: "name ="})
+	assert.True(t, armorerr.Is(err, armorerr.KindParse))
+}
+
+func Test_returnsCopies(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	c.AddPolicy(testProject, &computepb.SecurityPolicy{Name: proto.String("policy")})
+
+	policy, err := c.GetPolicy(ctx, testProject, "policy")
+	assert.NoError(t, err)
+	policy.Description = proto.String("changed by the caller")
+
+	policy, err = c.GetPolicy(ctx, testProject, "policy")
+	assert.NoError(t, err)
+	assert.Empty(t, policy.GetDescription())
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.GetPolicy(canceled, testProject, "policy")
+	assert.True(t, armorerr.Is(err, armorerr.KindUnavailable))
+}
+
+func Test_operations(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	c.SetOperationLatency(time.Hour)
+
+	op, err := c.CreatePolicy(ctx, &computepb.SecurityPolicy{Name: proto.String("policy")}, testProject)
+	assert.NoError(t, err)
+	assert.Equal(t, computepb.Operation_RUNNING, op.GetStatus())
+	op, err = c.GetOperation(ctx, testProject, op.GetName())
+	assert.NoError(t, err)
+	assert.Equal(t, computepb.Operation_RUNNING, op.GetStatus(), "operations run until the latency has passed")
+
+	_, err = c.GetOperation(ctx, testProject, "missing")
+	assert.True(t, armorerr.Is(err, armorerr.KindNotFound))
+
+	_, err = c.UpdatePolicy(ctx, &computepb.SecurityPolicy{Fingerprint: proto.String("stale")}, testProject, "policy")
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusPreconditionFailed, apiErr.Code, "errors carry the status of the Compute API")
+		assert.Equal(t, "conditionNotMet", apiErr.Reason)
+	}
+	assert.True(t, armorerr.Is(err, armorerr.KindConflict))
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/api/googleapi"
+)
+
+const namespace = "armor"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"route", "method", "status"})
+
+	grpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Number of gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC requests by method and status code.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"method", "code"})
+
+	googleCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "google_api_calls_total",
+		Help:      "Number of Compute API calls by client and method.",
+	}, []string{"client", "method"})
+
+	googleErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "google_api_errors_total",
+		Help:      "Number of failed Compute API calls by client, method and HTTP status code.",
+	}, []string{"client", "method", "code"})
+
+	googleCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "google_api_call_duration_seconds",
+		Help:      "Latency of Compute API calls by client and method.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"client", "method"})
+
+	operationWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "google_operation_wait_duration_seconds",
+		Help:      "Time spent waiting for long-running Compute operations by client, method and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"client", "method", "result"})
+
+	googleRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "google_api_retries_total",
+		Help:      "Number of retried Compute API calls by client and method.",
+	}, []string{"client", "method"})
+
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_open",
+		Help:      "Whether calls to the Compute API for a project currently fail fast.",
+	}, []string{"project"})
+
+	circuitBreakerRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "Number of Compute API calls rejected by an open circuit breaker by client and method.",
+	}, []string{"project", "client", "method"})
+
+	policyRules = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "policy_rules",
+		Help:      "Number of rules in a security policy when it was last read.",
+	}, []string{"project", "policy"})
+
+	protectedRuleRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "protected_rule_rejections_total",
+		Help:      "Number of requests rejected for changing a protected rule.",
+	}, []string{"project", "action"})
+)
+
+func HttpRequest(route, method string, status int, start time.Time) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpRequestDuration.WithLabelValues(route, method, code).Observe(time.Since(start).Seconds())
+}
+
+// GrpcRequest observes a gRPC request started at start, code is the name of its status code.
+func GrpcRequest(method, code string, start time.Time) {
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
+
+// GoogleCall observes a single Compute API call started at start.
+func GoogleCall(client, method string, start time.Time, err error) {
+	googleCalls.WithLabelValues(client, method).Inc()
+	googleCallDuration.WithLabelValues(client, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		googleErrors.WithLabelValues(client, method, errorCode(err)).Inc()
+	}
+}
+
+// OperationWait observes the time spent waiting for a long-running operation started at start.
+func OperationWait(client, method string, start time.Time, err error) {
+	result := "done"
+	if err != nil {
+		result = "error"
+	}
+	operationWaitDuration.WithLabelValues(client, method, result).Observe(time.Since(start).Seconds())
+}
+
+func GoogleRetry(client, method string) {
+	googleRetries.WithLabelValues(client, method).Inc()
+}
+
+func CircuitBreaker(projectID string, open bool) {
+	value := 0.0
+	if open {
+		value = 1
+	}
+	circuitBreakerState.WithLabelValues(projectID).Set(value)
+}
+
+func CircuitBreakerRejection(projectID, client, method string) {
+	circuitBreakerRejections.WithLabelValues(projectID, client, method).Inc()
+}
+
+func PolicyRules(projectID, policy string, rules int) {
+	policyRules.WithLabelValues(projectID, policy).Set(float64(rules))
+}
+
+func ProtectedRuleRejection(projectID, action string) {
+	protectedRuleRejections.WithLabelValues(projectID, action).Inc()
+}
+
+func errorCode(err error) string {
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		return strconv.Itoa(e.Code)
+	}
+	return "unknown"
+}
@@ -0,0 +1,178 @@
+package operation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nais/armor/pkg/google"
+	"github.com/sirupsen/logrus"
+)
+
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+
+	// retention is how long finished operations can be looked up.
+	retention = 1 * time.Hour
+)
+
+// Operation is an armor-level operation wrapping a mutation and the Google operation it started.
+type Operation struct {
+	ID              string      `json:"id"`
+	Project         string      `json:"project"`
+	Action          string      `json:"action"`
+	Status          Status      `json:"status"`
+	GoogleOperation string      `json:"google-operation,omitempty"`
+	Error           string      `json:"error,omitempty"`
+	Resource        interface{} `json:"resource,omitempty"`
+	Created         time.Time   `json:"created"`
+	Finished        *time.Time  `json:"finished,omitempty"`
+
+	// stopped is when armor stopped waiting for a Google operation still running, for the retention.
+	stopped *time.Time
+}
+
+type Mutation func(ctx context.Context) (interface{}, error)
+
+// Tracker runs mutations in the background and keeps their status.
+type Tracker struct {
+	mu         sync.RWMutex
+	ctx        context.Context
+	log        *logrus.Entry
+	operations map[string]*Operation
+	running    sync.WaitGroup
+}
+
+func NewTracker(ctx context.Context, log *logrus.Entry) *Tracker {
+	return &Tracker{
+		ctx:        ctx,
+		log:        log,
+		operations: make(map[string]*Operation),
+	}
+}
+
+// Start runs the mutation in the background and returns a snapshot of the running operation.
+func (t *Tracker) Start(projectID, action string, mutation Mutation) (*Operation, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	op := &Operation{
+		ID:      id,
+		Project: projectID,
+		Action:  action,
+		Status:  StatusRunning,
+		Created: time.Now().UTC(),
+	}
+
+	t.mu.Lock()
+	t.expire()
+	t.operations[id] = op
+	snapshot := *op
+	t.mu.Unlock()
+
+	ctx := google.WithOperationHook(t.ctx, func(event google.OperationEvent) {
+		t.update(id, func(op *Operation) {
+			op.GoogleOperation = event.Name
+		})
+	})
+
+	t.running.Add(1)
+	go func() {
+		defer t.running.Done()
+		resource, err := mutation(ctx)
+		var running *google.OperationRunningError
+		if errors.As(err, &running) {
+			t.update(id, func(op *Operation) {
+				stopped := time.Now().UTC()
+				op.stopped = &stopped
+				op.GoogleOperation = running.Name
+				op.Error = err.Error()
+			})
+			t.log.Warnf("operation %s %s in %s stopped waiting for Google: %v", id, action, projectID, err)
+			return
+		}
+		t.update(id, func(op *Operation) {
+			finished := time.Now().UTC()
+			op.Finished = &finished
+			op.Resource = resource
+			op.Status = StatusDone
+			if err != nil {
+				op.Status = StatusFailed
+				op.Error = err.Error()
+			}
+		})
+		if err != nil {
+			t.log.Warnf("operation %s %s in %s failed: %v", id, action, projectID, err)
+		}
+	}()
+
+	return &snapshot, nil
+}
+
+// Wait blocks until all running operations have finished or ctx is done.
+func (t *Tracker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get returns a snapshot of the operation with the given id.
+func (t *Tracker) Get(id string) (*Operation, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	op, ok := t.operations[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *op
+	return &snapshot, true
+}
+
+func (t *Tracker) update(id string, fn func(op *Operation)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if op, ok := t.operations[id]; ok {
+		fn(op)
+	}
+}
+
+// expire removes operations finished or given up on longer ago than the retention, the lock must be held.
+func (t *Tracker) expire() {
+	for id, op := range t.operations {
+		end := op.Finished
+		if end == nil {
+			end = op.stopped
+		}
+		if end != nil && time.Since(*end) > retention {
+			delete(t.operations, id)
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate operation id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,69 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/pkg/google"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Tracker(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		err    error
+		status Status
+	}{
+		{
+			name:   "Successful mutation is done with the resulting resource",
+			status: StatusDone,
+		},
+		{
+			name:   "Failing mutation is failed with the error",
+			err:    fmt.Errorf("googleapi: Error 409: conflict"),
+			status: StatusFailed,
+		},
+		{
+			name:   "Mutation giving up on a Google operation keeps it running",
+			err:    &google.OperationRunningError{Name: "operation-123", Err: context.DeadlineExceeded},
+			status: StatusRunning,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := NewTracker(context.Background(), log.WithField("component", "test"))
+			release := make(chan struct{})
+
+			started, err := tracker.Start("fake-project", "CreateRule", func(ctx context.Context) (interface{}, error) {
+				google.NotifyOperation(ctx, google.OperationEvent{Project: "fake-project", Method: "AddRule", Name: "operation-123"})
+				<-release
+				return "resource", test.err
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, StatusRunning, started.Status)
+
+			assert.Eventually(t, func() bool {
+				op, _ := tracker.Get(started.ID)
+				return op.GoogleOperation == "operation-123"
+			}, time.Second, 10*time.Millisecond, "google operation is known while running")
+
+			close(release)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			assert.NoError(t, tracker.Wait(ctx))
+
+			op, ok := tracker.Get(started.ID)
+			assert.True(t, ok)
+			assert.Equal(t, test.status, op.Status)
+			assert.Equal(t, "operation-123", op.GoogleOperation)
+			assert.Equal(t, test.status != StatusRunning, op.Finished != nil)
+			if test.err != nil {
+				assert.Equal(t, test.err.Error(), op.Error)
+			} else {
+				assert.Equal(t, "resource", op.Resource)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nais/armor/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Server is the public listener of armor, and the internal listener when probes and metrics are served on their own port.
+type Server struct {
+	Public   *http.Server
+	Internal *http.Server
+
+	certificate *certificate
+}
+
+// New builds the servers from cfg, requests run with ctx as their base context.
+// The internal handler is only served when an internal port is configured.
+func New(ctx context.Context, cfg *config.Config, public, internal http.Handler, log *logrus.Entry) (*Server, error) {
+	s := &Server{
+		Public: newHTTPServer(ctx, cfg, cfg.Port, public),
+	}
+	if cfg.InternalPort != "" {
+		s.Internal = newHTTPServer(ctx, cfg, cfg.InternalPort, internal)
+	}
+
+	tlsConfig, err := s.tlsConfig(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	s.Public.TLSConfig = tlsConfig
+	if tlsConfig == nil {
+		// gRPC requires HTTP/2, which is only negotiated over TLS unless the client speaks it from the start.
+		s.Public.Handler = h2c.NewHandler(s.Public.Handler, &http2.Server{IdleTimeout: cfg.IdleTimeout})
+	}
+
+	return s, nil
+}
+
+// newHTTPServer serves handler on addr with the timeouts and limits of cfg, the public and internal listener alike.
+func newHTTPServer(ctx context.Context, cfg *config.Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           limitBody(handler, cfg.MaxBodyBytes),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+}
+
+// Multiplex serves gRPC requests with grpcHandler and all other requests with next, so both share a listener.
+func Multiplex(grpcHandler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe serves the public listener, over TLS when a certificate is configured.
+func (s *Server) ListenAndServe() error {
+	if s.Public.TLSConfig != nil {
+		// The certificate is served by the TLS config, which also enables HTTP/2.
+		return s.Public.ListenAndServeTLS("", "")
+	}
+	return s.Public.ListenAndServe()
+}
+
+// Shutdown drains the public listener before the internal one, so probes are answered until requests are drained.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Public.Shutdown(ctx)
+	if s.Internal != nil {
+		err = errors.Join(err, s.Internal.Shutdown(ctx))
+	}
+	return err
+}
+
+func (s *Server) tlsConfig(cfg *config.Config, log *logrus.Entry) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("%s requires %s and %s", config.TLSClientCAFile, config.TLSCertFile, config.TLSKeyFile)
+		}
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, fmt.Errorf("both %s and %s are required to serve TLS", config.TLSCertFile, config.TLSKeyFile)
+	}
+
+	certificate, err := loadCertificate(cfg.TLSCertFile, cfg.TLSKeyFile, log)
+	if err != nil {
+		return nil, err
+	}
+	s.certificate = certificate
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certificate.get,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client ca %s", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// limitBody fails reading request bodies larger than limit, a limit of 0 or less disables it.
+func limitBody(next http.Handler, limit int64) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nais/armor/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func Test_limitBody(t *testing.T) {
+	handler := limitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}), 8)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345678")))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("123456789")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func Test_multiplex(t *testing.T) {
+	grpcServer := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(grpcServer, health.NewServer())
+	rest := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("rest"))
+	})
+
+	s, err := New(context.Background(), &config.Config{}, Multiplex(grpcServer, rest), nil, log.WithField("component", "test"))
+	assert.NoError(t, err)
+	server := httptest.NewServer(s.Public.Handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "rest", string(body))
+
+	conn, err := grpc.Dial(server.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	check, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err, "gRPC is served in plain text on the same listener")
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check.GetStatus())
+}
+
+func Test_internalServerLimits(t *testing.T) {
+	cfg := &config.Config{
+		Port:              ":8080",
+		InternalPort:      ":8081",
+		ReadTimeout:       time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		IdleTimeout:       4 * time.Second,
+		MaxHeaderBytes:    1024,
+	}
+	s, err := New(context.Background(), cfg, http.NotFoundHandler(), http.NotFoundHandler(), log.WithField("component", "test"))
+	assert.NoError(t, err)
+
+	for _, server := range []*http.Server{s.Public, s.Internal} {
+		assert.Equal(t, cfg.ReadTimeout, server.ReadTimeout)
+		assert.Equal(t, cfg.ReadHeaderTimeout, server.ReadHeaderTimeout)
+		assert.Equal(t, cfg.WriteTimeout, server.WriteTimeout)
+		assert.Equal(t, cfg.IdleTimeout, server.IdleTimeout)
+		assert.Equal(t, cfg.MaxHeaderBytes, server.MaxHeaderBytes)
+	}
+	assert.Equal(t, ":8081", s.Internal.Addr)
+}
+
+func Test_tlsConfigRequiresCertificateAndKey(t *testing.T) {
+	for _, cfg := range []*config.Config{
+		{TLSCertFile: "tls.crt"},
+		{TLSKeyFile: "tls.key"},
+		{TLSClientCAFile: "ca.crt"},
+	} {
+		_, err := New(context.Background(), cfg, http.NotFoundHandler(), http.NotFoundHandler(), log.WithField("component", "test"))
+		assert.Error(t, err)
+	}
+}
+
+func Test_reloadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		TLSCertFile: filepath.Join(dir, "tls.crt"),
+		TLSKeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	writeCertificate(t, cfg, 1, time.Now().Add(-time.Minute))
+
+	s, err := New(context.Background(), cfg, http.NotFoundHandler(), nil, log.WithField("component", "test"))
+	assert.NoError(t, err)
+	assert.Nil(t, s.Internal)
+	s.certificate.interval = 0
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = s.Public.ServeTLS(listener, "", "") }()
+	defer s.Shutdown(context.Background())
+
+	assert.Equal(t, int64(1), servedSerial(t, listener.Addr().String()))
+
+	writeCertificate(t, cfg, 2, time.Now())
+	assert.Equal(t, int64(2), servedSerial(t, listener.Addr().String()))
+
+	// A broken certificate is not served, the previous one is kept.
+	assert.NoError(t, os.WriteFile(cfg.TLSCertFile, []byte("broken"), 0o600))
+	assert.NoError(t, os.Chtimes(cfg.TLSCertFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	assert.Equal(t, int64(2), servedSerial(t, listener.Addr().String()))
+}
+
+func Test_clientCertificateRequired(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		TLSCertFile:     filepath.Join(dir, "tls.crt"),
+		TLSKeyFile:      filepath.Join(dir, "tls.key"),
+		TLSClientCAFile: filepath.Join(dir, "tls.crt"),
+	}
+	writeCertificate(t, cfg, 1, time.Now())
+
+	s, err := New(context.Background(), cfg, http.NotFoundHandler(), nil, log.WithField("component", "test"))
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, s.Public.TLSConfig.ClientAuth)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = s.Public.ServeTLS(listener, "", "") }()
+	defer s.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	_, err = client.Get("https://" + listener.Addr().String())
+	assert.Error(t, err, "a client without a certificate is rejected")
+
+	certificate, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	assert.NoError(t, err)
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{certificate}}}
+	response, err := client.Get("https://" + listener.Addr().String())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	_ = response.Body.Close()
+}
+
+func servedSerial(t *testing.T, addr string) int64 {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+// writeCertificate writes a self-signed certificate, usable by both servers and clients, with the given serial number.
+func writeCertificate(t *testing.T, cfg *config.Config, serial int64, modified time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "armor"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(cfg.TLSCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(cfg.TLSKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	for _, file := range []string{cfg.TLSCertFile, cfg.TLSKeyFile} {
+		assert.NoError(t, os.Chtimes(file, modified, modified))
+	}
+}
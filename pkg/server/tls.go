@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reloadInterval is the minimum interval between checks for a changed certificate on disk.
+const reloadInterval = 10 * time.Second
+
+// certificate serves a certificate and key from disk, and reloads them when they change, e.g. when renewed.
+type certificate struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	log      *logrus.Entry
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+	checked  time.Time
+}
+
+func loadCertificate(certFile, keyFile string, log *logrus.Entry) (*certificate, error) {
+	c := &certificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: reloadInterval,
+		log:      log,
+	}
+
+	modified, err := c.modTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(modified); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// get returns the current certificate, a certificate failing to reload is logged and the previous one kept.
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checked) < c.interval {
+		return c.cert, nil
+	}
+	c.checked = time.Now()
+
+	modified, err := c.modTime()
+	if err != nil {
+		c.log.WithError(err).Error("checking tls certificate, serving the previous one")
+		return c.cert, nil
+	}
+	if modified.Equal(c.modified) {
+		return c.cert, nil
+	}
+
+	if err := c.load(modified); err != nil {
+		c.log.WithError(err).Error("reloading tls certificate, serving the previous one")
+		return c.cert, nil
+	}
+	c.log.Infof("reloaded tls certificate %s", c.certFile)
+	return c.cert, nil
+}
+
+func (c *certificate) load(modified time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+	c.cert = &cert
+	c.modified = modified
+	c.checked = time.Now()
+	return nil
+}
+
+// modTime returns the latest modification of the certificate and key, either may be replaced first.
+func (c *certificate) modTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat tls certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}